@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -15,6 +21,14 @@ import (
 	"github.com/warriorguo/vocabulary/internal/services"
 )
 
+// defaultKnownWordsTop is how many of the most frequent words the text
+// analyzer assumes a learner already knows
+const defaultKnownWordsTop = 1000
+
+// shutdownTimeout is how long requests in flight get to finish once the
+// server is asked to stop
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Get database URL from environment
 	dbURL := os.Getenv("DATABASE_URL")
@@ -28,6 +42,39 @@ func main() {
 		port = "8080"
 	}
 
+	// Directory the audio proxy stores downloaded recordings in
+	audioDir := os.Getenv("AUDIO_DIR")
+	if audioDir == "" {
+		audioDir = "audio"
+	}
+
+	// Words the text analyzer treats as already known: the most frequent
+	// words of the bundled list plus an optional file of one word per line
+	knownWords, err := loadKnownWords(os.Getenv("KNOWN_WORDS_FILE"), os.Getenv("KNOWN_WORDS_TOP"))
+	if err != nil {
+		log.Fatalf("Failed to load known words: %v", err)
+	}
+
+	// Optional offline dictionaries for glossing entries in the reader's
+	// language, e.g. FreeDict and CC-CEDICT files; see LoadTranslationDir
+	translators, err := services.LoadTranslationDir(os.Getenv("TRANSLATIONS_DIR"))
+	if err != nil {
+		log.Fatalf("Failed to load translation dictionaries: %v", err)
+	}
+
+	// Optional local text-to-speech for words without a recording, e.g.
+	// TTS_COMMAND="espeak-ng -v {lang} -w {output} -- {text}"
+	var synth *services.Synthesizer
+	if command := os.Getenv("TTS_COMMAND"); command != "" {
+		format := os.Getenv("TTS_FORMAT")
+		if format == "" {
+			format = services.TTSFormatWAV
+		}
+		if synth, err = services.NewSynthesizer(command, format); err != nil {
+			log.Fatalf("Failed to configure text-to-speech: %v", err)
+		}
+	}
+
 	// Connect to database
 	ctx := context.Background()
 	pool, err := pgxpool.New(ctx, dbURL)
@@ -50,7 +97,24 @@ func main() {
 	// Initialize layers
 	repo := repository.New(pool)
 	dictSvc := services.NewDictionaryService(repo)
-	handler := handlers.New(repo, dictSvc)
+	if err := dictSvc.IndexCachedWords(ctx); err != nil {
+		log.Printf("Warning: failed to index cached words for suggestions: %v", err)
+	}
+	if err := dictSvc.BackfillWordLevels(ctx); err != nil {
+		log.Printf("Warning: failed to backfill wordbook word levels: %v", err)
+	}
+	studySvc := services.NewStudyService(repo)
+	audioStore, err := services.NewAudioStore(audioDir)
+	if err != nil {
+		log.Fatalf("Failed to open audio store: %v", err)
+	}
+	transferSvc := services.NewTransferService(repo, dictSvc, audioStore)
+	analyzeSvc := services.NewAnalyzeService(repo, services.DefaultFrequencyList(), knownWords)
+	translateSvc := services.NewTranslationService(repo, translators...)
+	audioSvc := services.NewAudioService(repo, dictSvc, audioStore, synth)
+	scheduler := services.NewScheduler(repo, services.MaintenanceJobs(repo, dictSvc)...)
+	warmer := services.NewCacheWarmer(repo, dictSvc)
+	handler := handlers.New(repo, dictSvc, studySvc, transferSvc, analyzeSvc, translateSvc, audioSvc, scheduler, warmer, os.Getenv("ADMIN_TOKEN"), os.Getenv("ADMIN_TOKENS"))
 
 	// Setup Gin
 	r := gin.Default()
@@ -58,7 +122,7 @@ func main() {
 	// CORS configuration
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -73,11 +137,57 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Interrupting stops the scheduler and drains requests before exiting
+	runCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Background maintenance; replicas take turns through advisory locks
+	scheduler.Start(runCtx)
+
 	// Start server
-	log.Printf("Starting server on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		log.Printf("Starting server on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-runCtx.Done()
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: failed to drain requests: %v", err)
+	}
+	// Jobs in flight finish before the database pool is closed
+	scheduler.Wait()
+}
+
+func loadKnownWords(path, top string) ([]string, error) {
+	n := defaultKnownWordsTop
+	if top != "" {
+		var err error
+		if n, err = strconv.Atoi(top); err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KNOWN_WORDS_TOP: %q", top)
+		}
+	}
+	words := append([]string(nil), services.DefaultFrequencyList().Top(n)...)
+
+	if path == "" {
+		return words, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	extra, err := services.LoadWordList(f)
+	if err != nil {
+		return nil, err
 	}
+	return append(words, extra...), nil
 }
 
 func runMigrations(ctx context.Context, pool *pgxpool.Pool) error {
@@ -99,6 +209,143 @@ func runMigrations(ctx context.Context, pool *pgxpool.Pool) error {
 			expires_at TIMESTAMPTZ NOT NULL
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_cache_expires ON dictionary_cache(expires_at)`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS ease DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+			ADD COLUMN IF NOT EXISTS interval_days INTEGER NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS repetitions INTEGER NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ NOT NULL DEFAULT NOW()`,
+		`CREATE INDEX IF NOT EXISTS idx_wordbook_user_due ON wordbook_entries(user_id, due_at)`,
+		`CREATE TABLE IF NOT EXISTS review_logs (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(64) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			grade SMALLINT NOT NULL,
+			interval_days INTEGER NOT NULL,
+			reviewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_review_logs_user_reviewed ON review_logs(user_id, reviewed_at)`,
+		`CREATE TABLE IF NOT EXISTS user_settings (
+			user_id VARCHAR(64) PRIMARY KEY,
+			time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS lookup_history (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(64) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			found BOOLEAN NOT NULL,
+			looked_up_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_lookup_history_user_time ON lookup_history(user_id, looked_up_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_lookup_history_user_word ON lookup_history(user_id, word)`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS snapshot JSONB,
+			ADD COLUMN IF NOT EXISTS selected_meaning INTEGER,
+			ADD COLUMN IF NOT EXISTS selected_definition INTEGER`,
+		`CREATE TABLE IF NOT EXISTS wordbook_contexts (
+			id BIGSERIAL PRIMARY KEY,
+			entry_id BIGINT NOT NULL REFERENCES wordbook_entries(id) ON DELETE CASCADE,
+			sentence TEXT NOT NULL,
+			source_title TEXT NOT NULL DEFAULT '',
+			source_url TEXT NOT NULL DEFAULT '',
+			location VARCHAR(64) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_wordbook_contexts_entry ON wordbook_contexts(entry_id)`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS frequency_rank INTEGER,
+			ADD COLUMN IF NOT EXISTS zipf DOUBLE PRECISION,
+			ADD COLUMN IF NOT EXISTS cefr_level VARCHAR(2) NOT NULL DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS idx_wordbook_entries_cefr ON wordbook_entries(user_id, cefr_level)`,
+		`ALTER TABLE user_settings
+			ADD COLUMN IF NOT EXISTS translation_language VARCHAR(16) NOT NULL DEFAULT ''`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en'`,
+		`ALTER TABLE wordbook_entries DROP CONSTRAINT IF EXISTS wordbook_entries_user_id_word_key`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_wordbook_user_language_word ON wordbook_entries(user_id, language, word)`,
+		`ALTER TABLE dictionary_cache
+			ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en'`,
+		`ALTER TABLE dictionary_cache DROP CONSTRAINT IF EXISTS dictionary_cache_pkey`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_cache_language_word ON dictionary_cache(language, word)`,
+		`ALTER TABLE review_logs
+			ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en'`,
+		`ALTER TABLE lookup_history
+			ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en'`,
+		`DROP INDEX IF EXISTS idx_lookup_history_user_word`,
+		`CREATE INDEX IF NOT EXISTS idx_lookup_history_user_language_word ON lookup_history(user_id, language, word)`,
+		`CREATE TABLE IF NOT EXISTS audio_files (
+			language VARCHAR(16) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			accent VARCHAR(8) NOT NULL DEFAULT '',
+			source_url TEXT NOT NULL,
+			content_type VARCHAR(128) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			size BIGINT NOT NULL,
+			fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (language, word, accent)
+		)`,
+		`ALTER TABLE audio_files
+			ADD COLUMN IF NOT EXISTS synthetic BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE TABLE IF NOT EXISTS job_runs (
+			id BIGSERIAL PRIMARY KEY,
+			job VARCHAR(64) NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			finished_at TIMESTAMPTZ,
+			result TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_job_runs_job_started ON job_runs(job, started_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS review_reminders (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(64) NOT NULL,
+			day DATE NOT NULL,
+			due_count INTEGER NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			dismissed_at TIMESTAMPTZ,
+			UNIQUE(user_id, day)
+		)`,
+		`CREATE TABLE IF NOT EXISTS cache_warm_runs (
+			id BIGSERIAL PRIMARY KEY,
+			language VARCHAR(16) NOT NULL,
+			words TEXT[] NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			fetched INTEGER NOT NULL DEFAULT 0,
+			cached INTEGER NOT NULL DEFAULT 0,
+			not_found TEXT[] NOT NULL DEFAULT '{}',
+			failed INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			status VARCHAR(16) NOT NULL DEFAULT 'running',
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			ended_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS custom_entries (
+			language VARCHAR(16) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			entry JSONB,
+			overrides JSONB NOT NULL DEFAULT '[]',
+			updated_by VARCHAR(64) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (language, word)
+		)`,
+		`CREATE TABLE IF NOT EXISTS custom_entry_changes (
+			id BIGSERIAL PRIMARY KEY,
+			language VARCHAR(16) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			action VARCHAR(16) NOT NULL,
+			actor VARCHAR(64) NOT NULL,
+			before JSONB,
+			after JSONB,
+			changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_custom_entry_changes_word ON custom_entry_changes(language, word, changed_at DESC)`,
+		`ALTER TABLE dictionary_cache ADD COLUMN IF NOT EXISTS schema_version INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE dictionary_cache ADD COLUMN IF NOT EXISTS raw BYTEA`,
+		`ALTER TABLE wordbook_entries ADD COLUMN IF NOT EXISTS selected_homograph INTEGER`,
 	}
 
 	for i, migration := range migrations {
@@ -107,6 +354,15 @@ func runMigrations(ctx context.Context, pool *pgxpool.Pool) error {
 		}
 	}
 
+	// Words saved before keys were normalized may collide once they are
+	removed, err := repository.New(pool).NormalizeWordKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("word key normalization failed: %w", err)
+	}
+	if removed > 0 {
+		log.Printf("Merged %d rows whose words collide once normalized", removed)
+	}
+
 	log.Println("Migrations completed successfully")
 	return nil
 }
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+	"github.com/warriorguo/vocabulary/internal/repository"
+	"github.com/warriorguo/vocabulary/internal/services"
+)
+
+// adminActorKey is the context key under which requireAdmin stores the name
+// of the admin making a request.
+const adminActorKey = "adminActor"
+
+// defaultAdminName names the admin of a token configured without a name.
+const defaultAdminName = "admin"
+
+type adminCredential struct {
+	name, token string
+}
+
+// adminCredentials combines ADMIN_TOKEN, a single opaque token used as is,
+// with the named tokens of ADMIN_TOKENS.
+func adminCredentials(token, named string) []adminCredential {
+	var admins []adminCredential
+	if token != "" {
+		admins = append(admins, adminCredential{name: defaultAdminName, token: token})
+	}
+	return append(admins, parseAdminTokens(named)...)
+}
+
+// parseAdminTokens reads ADMIN_TOKENS: a comma-separated list of name:token
+// pairs, so changes can be attributed to their author. A token may contain
+// colons, but not commas.
+func parseAdminTokens(v string) []adminCredential {
+	var admins []adminCredential
+	for _, field := range strings.Split(v, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(field, ":")
+		if !ok {
+			name, token = defaultAdminName, field
+		}
+		if name = strings.TrimSpace(name); name == "" {
+			name = defaultAdminName
+		}
+		if token = strings.TrimSpace(token); token != "" {
+			admins = append(admins, adminCredential{name: name, token: token})
+		}
+	}
+	return admins
+}
+
+// requireAdmin admits requests bearing an admin token as
+// "Authorization: Bearer <token>". Without a token configured the admin API
+// is disabled.
+func (h *Handler) requireAdmin(c *gin.Context) {
+	if len(h.admins) == 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin API is disabled; set ADMIN_TOKEN or ADMIN_TOKENS to enable it"})
+		return
+	}
+
+	// Every token is compared, so timing doesn't tell which one nearly matched
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	actor := ""
+	for _, admin := range h.admins {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(admin.token)) == 1 {
+			actor = admin.name
+		}
+	}
+	if !ok || actor == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "admin token required"})
+		return
+	}
+	c.Set(adminActorKey, actor)
+	c.Next()
+}
+
+// GetCacheEntries handles GET /api/admin/cache/entries?lang={language}&q={search}&source={source}&older_than={duration}&limit={limit}&offset={offset}
+func (h *Handler) GetCacheEntries(c *gin.Context) {
+	filter, ok := cacheFilter(c)
+	if !ok {
+		return
+	}
+	filter.Search = c.Query("q")
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.repo.GetCacheEntries(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if entries == nil {
+		entries = []models.CacheEntryInfo{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetCacheEntry handles GET /api/admin/cache/entries/:word?lang={language}
+// The entry is returned as stored, expired or not.
+func (h *Handler) GetCacheEntry(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+
+	h.writeCacheEntry(c, lang, word)
+}
+
+// RefreshCacheEntry handles POST /api/admin/cache/entries/:word/refresh?lang={language}
+// The word is fetched again from upstream, replacing its cached entry.
+func (h *Handler) RefreshCacheEntry(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+
+	if err := h.dictSvc.Refresh(c.Request.Context(), lang, word); err != nil {
+		if errors.Is(err, services.ErrWordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.writeCacheEntry(c, lang, word)
+}
+
+// PurgeCache handles DELETE /api/admin/cache/entries?lang={language}&word={word}&source={source}&older_than={duration}
+// At least one of word, source and older_than is required, so the whole
+// cache isn't purged by mistake; older_than is a duration such as "72h".
+func (h *Handler) PurgeCache(c *gin.Context) {
+	filter, ok := cacheFilter(c)
+	if !ok {
+		return
+	}
+	filter.Word = c.Query("word")
+	if filter.Word == "" && filter.Source == "" && filter.FetchedBefore.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "word, source or older_than is required"})
+		return
+	}
+
+	purged, err := h.repo.PurgeCache(c.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, normalize.ErrInvalidWord) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// GetCacheStats handles GET /api/admin/cache/stats
+// The hit ratio covers lookups on this server since it started.
+func (h *Handler) GetCacheStats(c *gin.Context) {
+	stats, err := h.repo.GetCacheStats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats.Hits, stats.Misses = h.dictSvc.CacheCounts()
+	if reads := stats.Hits + stats.Misses; reads > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(reads)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
+
+// writeCacheEntry responds with a cache row, its data and upstream payload
+// as the raw JSON stored.
+func (h *Handler) writeCacheEntry(c *gin.Context, lang, word string) {
+	row, err := h.repo.GetCacheRow(c.Request.Context(), lang, word)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "word is not cached"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := gin.H{
+		"entry": models.CacheEntryInfo{
+			Language:      row.Language,
+			Word:          row.Word,
+			Source:        row.Source,
+			Size:          len(row.Data),
+			SchemaVersion: row.SchemaVersion,
+			FetchedAt:     row.FetchedAt,
+			ExpiresAt:     row.ExpiresAt,
+			Expired:       !row.ExpiresAt.After(time.Now()),
+		},
+		"data": json.RawMessage(row.Data),
+	}
+	// The upstream payload the data was normalized from, for sources with one
+	if json.Valid(row.Raw) {
+		resp["raw"] = json.RawMessage(row.Raw)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// cacheFilter reads the lang, source and older_than filters, responding
+// with 400 and returning false when one is invalid. Without lang every
+// language matches.
+func cacheFilter(c *gin.Context) (repository.CacheFilter, bool) {
+	filter := repository.CacheFilter{
+		Language: normalize.Language(c.Query("lang")),
+		Source:   c.Query("source"),
+	}
+
+	if v := c.Query("older_than"); v != "" {
+		age, err := time.ParseDuration(v)
+		if err != nil || age <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "older_than must be a positive duration, such as 72h"})
+			return filter, false
+		}
+		filter.FetchedBefore = time.Now().Add(-age)
+	}
+	return filter, true
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/services"
+)
+
+const (
+	maxAnalyzeSize = 32 << 20 // 32 MiB
+	maxCandidates  = 500
+)
+
+// AnalyzeText handles POST /api/analyze?limit={n}
+// The body is either JSON ({"text": ..., "format": "text|html|srt"}) or a
+// multipart upload whose "file" field holds a text, HTML, EPUB or SRT file;
+// the format is taken from the "format" query parameter or the file name.
+func (h *Handler) AnalyzeText(c *gin.Context) {
+	limit, ok := queryPositiveInt(c, "limit", 0)
+	if !ok {
+		return
+	}
+
+	var doc *services.Document
+	var err error
+	if c.ContentType() == "multipart/form-data" {
+		body, filename, ok := uploadedFile(c, maxAnalyzeSize)
+		if !ok {
+			return
+		}
+		defer body.Close()
+
+		format := c.Query("format")
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(path.Ext(filename)), ".")
+		}
+		if doc, err = services.ExtractDocument(format, body); err == nil && doc.Title == "" {
+			doc.Title = strings.TrimSuffix(filename, path.Ext(filename))
+		}
+	} else {
+		var req models.AnalyzeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+		if doc, err = services.ExtractDocument(req.Format, strings.NewReader(req.Text)); err == nil && req.Title != "" {
+			doc.Title = req.Title
+		}
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+		case errors.Is(err, services.ErrUnsupportedFormat), errors.Is(err, services.ErrMalformedImport):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	analysis, err := h.analyzeSvc.Analyze(c.Request.Context(), defaultUserID, doc, min(limit, maxCandidates))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"analysis": analysis})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/services"
+)
+
+// audioMaxAge is how long clients may reuse a recording without
+// revalidating it
+const audioMaxAge = "86400"
+
+// GetAudio handles GET /api/audio/:word?lang={language}&accent={accent}
+// The recording is fetched upstream on first request and served from the
+// audio store afterwards, with support for range and conditional requests.
+func (h *Handler) GetAudio(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+	accent := strings.ToLower(strings.TrimSpace(c.Query("accent")))
+
+	f, file, err := h.audioSvc.Open(c.Request.Context(), lang, word, accent)
+	if err != nil {
+		if errors.Is(err, services.ErrWordNotFound) || errors.Is(err, services.ErrNoAudio) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", f.ContentType)
+	c.Header("ETag", `"`+f.Checksum+`"`)
+	c.Header("Cache-Control", "public, max-age="+audioMaxAge)
+	http.ServeContent(c.Writer, c.Request, "", f.FetchedAt, file)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+	"github.com/warriorguo/vocabulary/internal/repository"
+	"github.com/warriorguo/vocabulary/internal/services"
+)
+
+// GetCustomEntries handles GET /api/admin/custom-entries?lang={language}
+// Without lang the entries of every language are listed.
+func (h *Handler) GetCustomEntries(c *gin.Context) {
+	entries, err := h.repo.GetCustomEntries(c.Request.Context(), normalize.Language(c.Query("lang")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if entries == nil {
+		entries = []models.CustomEntry{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// GetCustomEntry handles GET /api/admin/custom-entries/:word?lang={language}
+func (h *Handler) GetCustomEntry(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+
+	entry, err := h.repo.GetCustomEntry(c.Request.Context(), lang, word)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if entry == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "word has no custom entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entry": entry})
+}
+
+// SaveCustomEntry handles PUT /api/admin/custom-entries/:word?lang={language}
+// The body holds a full entry served instead of upstream data, overrides
+// amending upstream data, or both; it replaces any earlier curation.
+func (h *Handler) SaveCustomEntry(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+
+	var req models.CustomEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.dictSvc.SaveCustomEntry(c.Request.Context(), lang, word, req, c.GetString(adminActorKey))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCustomEntry) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entry": entry})
+}
+
+// DeleteCustomEntry handles DELETE /api/admin/custom-entries/:word?lang={language}
+// Lookups of the word return to upstream data.
+func (h *Handler) DeleteCustomEntry(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteCustomEntry(c.Request.Context(), lang, word, c.GetString(adminActorKey)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "word has no custom entry"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "custom entry deleted"})
+}
+
+// GetCustomEntryChanges handles GET /api/admin/custom-entries/:word/changes?lang={language}&limit={limit}
+func (h *Handler) GetCustomEntryChanges(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+
+	h.writeCustomEntryChanges(c, lang, word)
+}
+
+// GetAuditLog handles GET /api/admin/audit?limit={limit}
+// It lists changes to custom entries, newest first.
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	h.writeCustomEntryChanges(c, "", "")
+}
+
+func (h *Handler) writeCustomEntryChanges(c *gin.Context, lang, word string) {
+	limit, ok := queryPositiveInt(c, "limit", defaultPageSize)
+	if !ok {
+		return
+	}
+
+	changes, err := h.repo.GetCustomEntryChanges(c.Request.Context(), lang, word, min(limit, maxPageSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if changes == nil {
+		changes = []models.CustomEntryChange{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
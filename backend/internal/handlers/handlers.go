@@ -1,48 +1,116 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
 	"github.com/warriorguo/vocabulary/internal/repository"
 	"github.com/warriorguo/vocabulary/internal/services"
 )
 
-const defaultUserID = "default"
+const (
+	defaultUserID = "default"
+
+	maxSpellingSuggestions = 5
+)
 
 type Handler struct {
-	repo    *repository.Repository
-	dictSvc *services.DictionaryService
+	repo         *repository.Repository
+	dictSvc      *services.DictionaryService
+	studySvc     *services.StudyService
+	transferSvc  *services.TransferService
+	analyzeSvc   *services.AnalyzeService
+	translateSvc *services.TranslationService
+	audioSvc     *services.AudioService
+	scheduler    *services.Scheduler
+	warmer       *services.CacheWarmer
+	// admins authenticate requests to the admin API, which is disabled
+	// when there are none
+	admins []adminCredential
 }
 
-func New(repo *repository.Repository, dictSvc *services.DictionaryService) *Handler {
+func New(repo *repository.Repository, dictSvc *services.DictionaryService, studySvc *services.StudyService, transferSvc *services.TransferService, analyzeSvc *services.AnalyzeService, translateSvc *services.TranslationService, audioSvc *services.AudioService, scheduler *services.Scheduler, warmer *services.CacheWarmer, adminToken, adminTokens string) *Handler {
 	return &Handler{
-		repo:    repo,
-		dictSvc: dictSvc,
+		repo:         repo,
+		dictSvc:      dictSvc,
+		studySvc:     studySvc,
+		transferSvc:  transferSvc,
+		analyzeSvc:   analyzeSvc,
+		translateSvc: translateSvc,
+		audioSvc:     audioSvc,
+		scheduler:    scheduler,
+		warmer:       warmer,
+		admins:       adminCredentials(adminToken, adminTokens),
 	}
 }
 
-// LookupWord handles GET /api/dict?word={word}
+// LookupWord handles GET /api/dict?word={word}&lang={language}
 func (h *Handler) LookupWord(c *gin.Context) {
 	word := c.Query("word")
 	if word == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "word parameter is required"})
 		return
 	}
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, err := normalize.Word(lang, word)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	entry, err := h.dictSvc.LookupWord(c.Request.Context(), word)
+	entry, err := h.dictSvc.LookupWord(c.Request.Context(), lang, word)
 	if err != nil {
-		if err.Error() == "word not found: "+word {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrWordNotFound) {
+			h.recordLookup(c.Request.Context(), lang, word, false)
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":       err.Error(),
+				"suggestions": h.dictSvc.Suggest(lang, word, maxSpellingSuggestions),
+			})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check if word is in wordbook
-	inWordbook, err := h.repo.WordExistsInWordbook(c.Request.Context(), defaultUserID, word)
+	// An inflected form is recorded as the headword it resolved to, which is
+	// what gets saved and what suggestions are checked against
+	headword := word
+	if entry.QueriedForm != "" {
+		if key, err := normalize.Word(lang, entry.Word); err == nil {
+			headword = key
+		}
+	}
+	h.recordLookup(c.Request.Context(), lang, headword, true)
+
+	// Glosses depend on the reader's language, so they are added per request
+	// rather than cached with the entry
+	c.Header("Vary", "Accept-Language")
+	language, err := h.translateSvc.Language(c.Request.Context(), defaultUserID, c.GetHeader("Accept-Language"))
+	if err != nil {
+		log.Printf("Warning: failed to resolve translation language: %v", err)
+	}
+	h.translateSvc.Annotate(entry, language)
+
+	// Recordings are served through the audio proxy rather than hotlinked
+	h.audioSvc.ProxyAudio(entry)
+
+	// Check if word is in wordbook, where an inflected form may be saved as
+	// typed or, when the user accepted it, by its headword
+	inWordbook, err := h.repo.WordExistsInWordbook(c.Request.Context(), defaultUserID, lang, word)
+	if err == nil && !inWordbook && headword != word {
+		inWordbook, err = h.repo.WordExistsInWordbook(c.Request.Context(), defaultUserID, lang, headword)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -54,9 +122,14 @@ func (h *Handler) LookupWord(c *gin.Context) {
 	})
 }
 
-// GetWordbook handles GET /api/wordbook
+// GetWordbook handles GET /api/wordbook?lang={language}&cefr={levels}&min_zipf={z}&max_zipf={z}&max_rank={n}&sort={order}&order={asc|desc}
 func (h *Handler) GetWordbook(c *gin.Context) {
-	entries, err := h.repo.GetWordbookEntries(c.Request.Context(), defaultUserID)
+	filter, ok := wordbookFilter(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.repo.GetWordbookEntries(c.Request.Context(), defaultUserID, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -69,6 +142,86 @@ func (h *Handler) GetWordbook(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"entries": entries})
 }
 
+// wordParam reads the word path parameter in its normalized form,
+// responding with 400 and returning false when it isn't a valid word.
+func wordParam(c *gin.Context, lang string) (string, bool) {
+	word, err := normalize.Word(lang, c.Param("word"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return word, true
+}
+
+// queryLanguage reads the optional lang query parameter, defaulting to
+// English, and responds with 400 and returns false for a language the
+// dictionary doesn't cover.
+func (h *Handler) queryLanguage(c *gin.Context) (string, bool) {
+	lang, err := h.dictSvc.Language(c.Query("lang"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return lang, true
+}
+
+// wordbookFilter reads the wordbook listing filters from the query string,
+// responding with 400 and returning false when one is invalid.
+func wordbookFilter(c *gin.Context) (repository.WordbookFilter, bool) {
+	filter := repository.WordbookFilter{Language: normalize.Language(c.Query("lang"))}
+
+	if v := c.Query("cefr"); v != "" {
+		for _, level := range strings.Split(v, ",") {
+			level = strings.ToUpper(strings.TrimSpace(level))
+			if !slices.Contains(services.CEFRLevels, level) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "cefr must list levels from A1 to C2"})
+				return filter, false
+			}
+			filter.CEFRLevels = append(filter.CEFRLevels, level)
+		}
+	}
+
+	var ok bool
+	if filter.MinZipf, ok = queryFloat(c, "min_zipf"); !ok {
+		return filter, false
+	}
+	if filter.MaxZipf, ok = queryFloat(c, "max_zipf"); !ok {
+		return filter, false
+	}
+	if filter.MaxRank, ok = queryPositiveInt(c, "max_rank", 0); !ok {
+		return filter, false
+	}
+
+	filter.Sort = c.DefaultQuery("sort", repository.WordbookSortCreated)
+	if !repository.ValidWordbookSort(filter.Sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of created_at, word, frequency, zipf or cefr"})
+		return filter, false
+	}
+	filter.Order = c.Query("order")
+	if filter.Order != "" && filter.Order != "asc" && filter.Order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return filter, false
+	}
+
+	return filter, true
+}
+
+// queryFloat reads an optional number from the query string, responding with
+// 400 and returning false when it isn't one.
+func queryFloat(c *gin.Context, name string) (*float64, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a number"})
+		return nil, false
+	}
+	return &f, true
+}
+
 // AddToWordbook handles POST /api/wordbook
 func (h *Handler) AddToWordbook(c *gin.Context) {
 	var req models.AddWordRequest
@@ -77,7 +230,75 @@ func (h *Handler) AddToWordbook(c *gin.Context) {
 		return
 	}
 
-	entry, err := h.repo.AddWordbookEntry(c.Request.Context(), defaultUserID, req.Word, req.ShortDefinition)
+	if req.SelectedSense != nil && !req.SaveSnapshot {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "selected_sense requires save_snapshot"})
+		return
+	}
+	if req.SelectedHomograph != nil && !req.SaveSnapshot {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "selected_homograph requires save_snapshot"})
+		return
+	}
+
+	lang, err := h.dictSvc.Language(req.Language)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	word, err := normalize.Word(lang, req.Word)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Inflected forms are saved under their lemma only when the user accepts
+	// it, since a form such as "saw" or "found" can be a word of its own
+	if req.AcceptLemma {
+		word = h.dictSvc.Lemma(lang, word)
+	}
+
+	var snapshot *models.DictionaryEntry
+	if req.SaveSnapshot {
+		snapshot, err = h.dictSvc.LookupWord(c.Request.Context(), lang, word)
+		if err != nil {
+			if errors.Is(err, services.ErrWordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if snapshot.QueriedForm != "" && req.AcceptLemma {
+			word = snapshot.Word
+			snapshot.QueriedForm = ""
+		}
+		senses := snapshot
+		if req.SelectedHomograph != nil {
+			if !snapshot.HasHomograph(*req.SelectedHomograph) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "selected_homograph does not match any homograph"})
+				return
+			}
+			// The sense is chosen among the pinned homograph's definitions
+			senses = snapshot.HomographEntry(*req.SelectedHomograph)
+		}
+		if req.SelectedSense != nil && !senses.HasSense(*req.SelectedSense) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "selected_sense does not match any definition"})
+			return
+		}
+	}
+
+	entry, err := h.repo.SaveWordbookEntry(c.Request.Context(), defaultUserID, repository.WordbookEntryInput{
+		Language:          lang,
+		Word:              word,
+		ShortDefinition:   req.ShortDefinition,
+		Snapshot:          snapshot,
+		SelectedSense:     req.SelectedSense,
+		SelectedHomograph: req.SelectedHomograph,
+		Context:           req.Context,
+		Notes:             req.Notes,
+		Tags:              req.Tags,
+		Levels:            h.dictSvc.Levels(lang, word),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -86,15 +307,68 @@ func (h *Handler) AddToWordbook(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"entry": entry})
 }
 
-// RemoveFromWordbook handles DELETE /api/wordbook/:word
+// GetWordbookEntry handles GET /api/wordbook/:word?lang={language}
+// The stored snapshot is returned as saved, with every homograph so another
+// can be pinned; DictionaryService is not consulted.
+func (h *Handler) GetWordbookEntry(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+
+	entry, err := h.repo.GetWordbookEntry(c.Request.Context(), defaultUserID, lang, word)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "word not in wordbook: " + word})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entry": entry})
+}
+
+// GetCloze handles GET /api/wordbook/:word/cloze?lang={language}
+func (h *Handler) GetCloze(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+
+	entry, err := h.repo.GetWordbookEntry(c.Request.Context(), defaultUserID, lang, word)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "word not in wordbook: " + word})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": services.BuildClozeItems(entry)})
+}
+
+// RemoveFromWordbook handles DELETE /api/wordbook/:word?lang={language}
 func (h *Handler) RemoveFromWordbook(c *gin.Context) {
-	word := c.Param("word")
-	if word == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "word parameter is required"})
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
 		return
 	}
 
-	err := h.repo.DeleteWordbookEntry(c.Request.Context(), defaultUserID, word)
+	err := h.repo.DeleteWordbookEntry(c.Request.Context(), defaultUserID, lang, word)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -105,11 +379,54 @@ func (h *Handler) RemoveFromWordbook(c *gin.Context) {
 
 // SetupRoutes configures all API routes
 func (h *Handler) SetupRoutes(r *gin.Engine) {
+	// Route on the escaped path, so a word param can hold an escaped slash
+	// ("and%2For") and still address a single wordbook entry
+	r.UseRawPath = true
+
 	api := r.Group("/api")
 	{
 		api.GET("/dict", h.LookupWord)
+		api.GET("/audio/:word", h.GetAudio)
+		api.GET("/suggest", h.SuggestWords)
 		api.GET("/wordbook", h.GetWordbook)
 		api.POST("/wordbook", h.AddToWordbook)
+		api.GET("/wordbook/export", h.ExportWordbook)
+		api.POST("/wordbook/import", h.ImportWordbook)
+		api.POST("/wordbook/import/kindle", h.ImportKindle)
+		api.POST("/analyze", h.AnalyzeText)
+		api.GET("/wordbook/:word", h.GetWordbookEntry)
+		api.GET("/wordbook/:word/cloze", h.GetCloze)
 		api.DELETE("/wordbook/:word", h.RemoveFromWordbook)
+		api.POST("/wordbook/:word/review", h.ReviewWord)
+		api.GET("/stats", h.GetStats)
+		api.GET("/history", h.GetHistory)
+		api.DELETE("/history", h.ClearHistory)
+		api.GET("/history/suggestions", h.GetHistorySuggestions)
+		api.GET("/settings", h.GetSettings)
+		api.PUT("/settings", h.UpdateSettings)
+		api.GET("/reminders", h.GetReminders)
+		api.DELETE("/reminders", h.DismissReminders)
+	}
+
+	admin := api.Group("/admin", h.requireAdmin)
+	{
+		admin.GET("/jobs", h.GetJobs)
+		admin.GET("/jobs/:name/runs", h.GetJobRuns)
+		admin.POST("/jobs/:name/run", h.RunJob)
+		admin.GET("/cache/warm", h.GetWarmRuns)
+		admin.POST("/cache/warm", h.WarmCache)
+		admin.GET("/cache/warm/:id", h.GetWarmRun)
+		admin.POST("/cache/warm/:id/resume", h.ResumeWarmCache)
+		admin.GET("/cache/stats", h.GetCacheStats)
+		admin.GET("/cache/entries", h.GetCacheEntries)
+		admin.DELETE("/cache/entries", h.PurgeCache)
+		admin.GET("/cache/entries/:word", h.GetCacheEntry)
+		admin.POST("/cache/entries/:word/refresh", h.RefreshCacheEntry)
+		admin.GET("/custom-entries", h.GetCustomEntries)
+		admin.GET("/custom-entries/:word", h.GetCustomEntry)
+		admin.PUT("/custom-entries/:word", h.SaveCustomEntry)
+		admin.DELETE("/custom-entries/:word", h.DeleteCustomEntry)
+		admin.GET("/custom-entries/:word/changes", h.GetCustomEntryChanges)
+		admin.GET("/audit", h.GetAuditLog)
 	}
 }
@@ -6,11 +6,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/services"
 )
 
 // Mock repository for testing
@@ -247,3 +250,197 @@ func TestDeleteFromWordbook(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
 	}
 }
+
+func TestParsePagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantOK     bool
+		wantLimit  int
+		wantOffset int
+	}{
+		{"defaults", "", true, defaultPageSize, 0},
+		{"explicit", "?limit=10&offset=20", true, 10, 20},
+		{"limit capped", "?limit=100000", true, maxPageSize, 0},
+		{"zero limit", "?limit=0", false, 0, 0},
+		{"negative offset", "?offset=-1", false, 0, 0},
+		{"non-numeric", "?limit=ten", false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("GET", "/api/history"+tt.query, nil)
+
+			limit, offset, ok := parsePagination(c)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok=%v, got %v", tt.wantOK, ok)
+			}
+			if !ok {
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+				}
+				return
+			}
+			if limit != tt.wantLimit || offset != tt.wantOffset {
+				t.Errorf("got limit=%d offset=%d, want %d/%d", limit, offset, tt.wantLimit, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestWordParamRouting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := New(nil, services.NewDictionaryService(nil), nil, nil, nil, nil, nil, nil, nil, "", "")
+	h.SetupRoutes(r)
+
+	// An unsupported language is rejected before the repository is used, so
+	// a 400 rather than a 404 shows the route matched
+	for _, path := range []string{"/api/wordbook/and%2For", "/api/wordbook/give%20up", "/api/wordbook/break%20the%20ice/review"} {
+		method := http.MethodDelete
+		if strings.HasSuffix(path, "/review") {
+			method = http.MethodPost
+		}
+		req, _ := http.NewRequest(method, path+"?lang=xx", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status %d, got %d", method, path, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestWarmCacheValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	dictSvc := services.NewDictionaryService(nil)
+	h := New(nil, dictSvc, nil, nil, nil, nil, nil, nil, services.NewCacheWarmer(nil, dictSvc), "secret", "")
+	h.SetupRoutes(r)
+
+	// Each is rejected before a run is recorded
+	for _, query := range []string{"lang=xx", "top=0", "concurrency=0", "concurrency=1000", "rate=-1", "rate=fast", "lang=de"} {
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/cache/warm?"+query, http.NoBody)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/admin/cache/warm/abc", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a malformed id, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dictSvc := services.NewDictionaryService(nil)
+
+	tests := []struct {
+		name          string
+		token, header string
+		want          int
+	}{
+		{"disabled", "", "Bearer ", http.StatusForbidden},
+		{"missing", "secret", "", http.StatusUnauthorized},
+		{"wrong", "secret", "Bearer guess", http.StatusUnauthorized},
+		{"not bearer", "secret", "Basic secret", http.StatusUnauthorized},
+		// Admitted, then rejected by the handler for lacking a filter
+		{"valid", "secret", "Bearer secret", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		New(nil, dictSvc, nil, nil, nil, nil, nil, nil, nil, tt.token, "").SetupRoutes(r)
+
+		req, _ := http.NewRequest(http.MethodDelete, "/api/admin/cache/entries", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
+
+func TestAdminRoutesRequireToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	dictSvc := services.NewDictionaryService(nil)
+	New(nil, dictSvc, nil, nil, nil, nil, nil, nil, services.NewCacheWarmer(nil, dictSvc), "secret", "").SetupRoutes(r)
+
+	// Running jobs and warming the cache included, nothing under /api/admin
+	// is reachable without a token
+	for _, route := range r.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/admin/") {
+			continue
+		}
+		path := strings.NewReplacer(":name", "refresh", ":id", "1", ":word", "word").Replace(route.Path)
+		req, _ := http.NewRequest(route.Method, path, http.NoBody)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: expected status %d, got %d", route.Method, route.Path, http.StatusUnauthorized, w.Code)
+		}
+	}
+}
+
+func TestAdminCredentials(t *testing.T) {
+	// ADMIN_TOKEN is one opaque token, even when it looks like a list of pairs
+	got := adminCredentials("alice:s3cret,x", "bob:hunter2")
+	want := []adminCredential{{"admin", "alice:s3cret,x"}, {"bob", "hunter2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("adminCredentials = %+v, want %+v", got, want)
+	}
+	if got := adminCredentials("", ""); len(got) != 0 {
+		t.Errorf("expected no tokens, got %+v", got)
+	}
+}
+
+func TestParseAdminTokens(t *testing.T) {
+	got := parseAdminTokens(" alice:s3cret, bob:hunter2 ,,:anon")
+	want := []adminCredential{{"alice", "s3cret"}, {"bob", "hunter2"}, {"admin", "anon"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAdminTokens = %+v, want %+v", got, want)
+	}
+
+	if got := parseAdminTokens("secret"); len(got) != 1 || got[0].name != "admin" || got[0].token != "secret" {
+		t.Errorf("expected a single unnamed token, got %+v", got)
+	}
+	if got := parseAdminTokens(""); len(got) != 0 {
+		t.Errorf("expected no tokens, got %+v", got)
+	}
+}
+
+func TestRequireAdminActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := New(nil, services.NewDictionaryService(nil), nil, nil, nil, nil, nil, nil, nil, "", "alice:one,bob:two")
+
+	r := gin.New()
+	r.GET("/whoami", h.requireAdmin, func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(adminActorKey))
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer two")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "bob" {
+		t.Errorf("expected bob admitted, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+const (
+	defaultPageSize       = 50
+	maxPageSize           = 200
+	defaultSuggestMinimum = 2
+	defaultSuggestLimit   = 10
+)
+
+// GetHistory handles GET /api/history?limit={limit}&offset={offset}
+func (h *Handler) GetHistory(c *gin.Context) {
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	events, total, err := h.repo.GetLookupHistory(c.Request.Context(), defaultUserID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if events == nil {
+		events = []models.LookupEvent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ClearHistory handles DELETE /api/history
+func (h *Handler) ClearHistory(c *gin.Context) {
+	if err := h.repo.ClearLookupHistory(c.Request.Context(), defaultUserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "lookup history cleared"})
+}
+
+// GetHistorySuggestions handles GET /api/history/suggestions?min_count={n}&limit={n}
+func (h *Handler) GetHistorySuggestions(c *gin.Context) {
+	minCount, ok := queryPositiveInt(c, "min_count", defaultSuggestMinimum)
+	if !ok {
+		return
+	}
+	limit, ok := queryPositiveInt(c, "limit", defaultSuggestLimit)
+	if !ok {
+		return
+	}
+	limit = min(limit, maxPageSize)
+
+	suggestions, err := h.repo.GetLookupSuggestions(c.Request.Context(), defaultUserID, minCount, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if suggestions == nil {
+		suggestions = []models.LookupSuggestion{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+// recordLookup stores a lookup event for a normalized word. Failures are
+// logged rather than surfaced, since history is a convenience and must not
+// break lookups.
+func (h *Handler) recordLookup(ctx context.Context, language, word string, found bool) {
+	if err := h.repo.AddLookupEvent(ctx, defaultUserID, language, word, found); err != nil {
+		log.Printf("Warning: failed to record lookup of %q: %v", word, err)
+	}
+}
+
+// parsePagination reads limit and offset query parameters, writing a 400
+// response and returning ok=false when either is malformed.
+func parsePagination(c *gin.Context) (limit, offset int, ok bool) {
+	limit, ok = queryPositiveInt(c, "limit", defaultPageSize)
+	if !ok {
+		return 0, 0, false
+	}
+	limit = min(limit, maxPageSize)
+
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+			return 0, 0, false
+		}
+		offset = n
+	}
+
+	return limit, offset, true
+}
+
+// queryPositiveInt reads an optional positive integer query parameter,
+// writing a 400 response and returning ok=false when it is malformed.
+func queryPositiveInt(c *gin.Context, name string, def int) (int, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return def, true
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a positive integer"})
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/services"
+)
+
+const defaultJobRunsLimit = 20
+
+// GetJobs handles GET /api/admin/jobs
+// Each scheduled job is listed with its last run and last failure.
+func (h *Handler) GetJobs(c *gin.Context) {
+	jobs, err := h.scheduler.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetJobRuns handles GET /api/admin/jobs/:name/runs?limit={limit}
+func (h *Handler) GetJobRuns(c *gin.Context) {
+	limit, ok := queryPositiveInt(c, "limit", defaultJobRunsLimit)
+	if !ok {
+		return
+	}
+	limit = min(limit, maxPageSize)
+
+	runs, err := h.scheduler.History(c.Request.Context(), c.Param("name"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownJob) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if runs == nil {
+		runs = []models.JobRun{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// RunJob handles POST /api/admin/jobs/:name/run
+// The job runs before the response is sent, even if it isn't due; a failure
+// of the job is reported in the returned run.
+func (h *Handler) RunJob(c *gin.Context) {
+	run, err := h.scheduler.RunNow(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownJob):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrJobRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
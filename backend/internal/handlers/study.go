@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/repository"
+	"github.com/warriorguo/vocabulary/internal/services"
+)
+
+// ReviewWord handles POST /api/wordbook/:word/review?lang={language}
+func (h *Handler) ReviewWord(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	word, ok := wordParam(c, lang)
+	if !ok {
+		return
+	}
+
+	var req models.ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.studySvc.ReviewWord(c.Request.Context(), defaultUserID, lang, word, *req.Grade)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "word not in wordbook: " + word})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entry": entry})
+}
+
+// GetStats handles GET /api/stats?days={days}
+func (h *Handler) GetStats(c *gin.Context) {
+	days, ok := queryPositiveInt(c, "days", 0)
+	if !ok {
+		return
+	}
+
+	stats, err := h.studySvc.GetStats(c.Request.Context(), defaultUserID, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
+
+// GetSettings handles GET /api/settings
+func (h *Handler) GetSettings(c *gin.Context) {
+	settings, err := h.studySvc.GetSettings(c.Request.Context(), defaultUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": settings})
+}
+
+// UpdateSettings handles PUT /api/settings
+func (h *Handler) UpdateSettings(c *gin.Context) {
+	var req models.UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var settings *models.UserSettings
+	var err error
+	if req.TimeZone != "" {
+		settings, err = h.studySvc.SetTimeZone(c.Request.Context(), defaultUserID, req.TimeZone)
+	}
+	if err == nil && req.TranslationLanguage != nil {
+		settings, err = h.translateSvc.SetLanguage(c.Request.Context(), defaultUserID, *req.TranslationLanguage)
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTimeZone) || errors.Is(err, services.ErrUnsupportedLanguage) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": settings})
+}
+
+// GetReminders handles GET /api/reminders
+func (h *Handler) GetReminders(c *gin.Context) {
+	reminders, err := h.repo.GetReviewReminders(c.Request.Context(), defaultUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if reminders == nil {
+		reminders = []models.ReviewReminder{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reminders": reminders})
+}
+
+// DismissReminders handles DELETE /api/reminders
+func (h *Handler) DismissReminders(c *gin.Context) {
+	if err := h.repo.DismissReviewReminders(c.Request.Context(), defaultUserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "reminders dismissed"})
+}
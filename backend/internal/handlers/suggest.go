@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+	"github.com/warriorguo/vocabulary/internal/services"
+)
+
+const defaultCompletions = 10
+
+// SuggestWords handles GET /api/suggest?prefix={prefix}&lang={language}&limit={n}
+func (h *Handler) SuggestWords(c *gin.Context) {
+	prefix := strings.TrimSpace(c.Query("prefix"))
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prefix parameter is required"})
+		return
+	}
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	prefix, err := normalize.Word(lang, prefix)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, ok := queryPositiveInt(c, "limit", defaultCompletions)
+	if !ok {
+		return
+	}
+
+	completions, err := h.dictSvc.Complete(c.Request.Context(), defaultUserID, lang, prefix, min(limit, services.MaxCompletions))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": completions})
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/services"
+)
+
+const (
+	maxImportSize       = 10 << 20 // 10 MiB
+	maxKindleImportSize = 64 << 20 // 64 MiB
+)
+
+// ExportWordbook handles GET /api/wordbook/export?format={csv|json|apkg}
+func (h *Handler) ExportWordbook(c *gin.Context) {
+	format := c.DefaultQuery("format", services.FormatJSON)
+
+	var contentType string
+	switch format {
+	case services.FormatCSV:
+		contentType = "text/csv; charset=utf-8"
+	case services.FormatJSON:
+		contentType = "application/json; charset=utf-8"
+	case services.FormatAnki:
+		contentType = "application/octet-stream"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, json or apkg"})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="wordbook.`+format+`"`)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent once streaming starts, so a failure part-way
+	// can only be logged; the client sees a truncated file.
+	if err := h.transferSvc.Export(c.Request.Context(), defaultUserID, format, c.Writer); err != nil {
+		log.Printf("Warning: wordbook export failed: %v", err)
+	}
+}
+
+// ImportWordbook handles POST /api/wordbook/import?format={csv|json}&on_duplicate={skip|overwrite|merge}&dry_run={bool}&lemmatize={bool}
+// The file is taken from a multipart "file" field or, failing that, the raw body.
+func (h *Handler) ImportWordbook(c *gin.Context) {
+	dryRun, ok := queryBool(c, "dry_run", false)
+	if !ok {
+		return
+	}
+	lemmatize, ok := queryBool(c, "lemmatize", false)
+	if !ok {
+		return
+	}
+
+	body, filename, ok := uploadedFile(c, maxImportSize)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	format := c.Query("format")
+	if format == "" && filename != "" {
+		format = strings.TrimPrefix(strings.ToLower(path.Ext(filename)), ".")
+	}
+	if format == "" {
+		format = formatFromContentType(c.ContentType())
+	}
+
+	report, err := h.transferSvc.Import(c.Request.Context(), defaultUserID, format, body, c.Query("on_duplicate"), dryRun, lemmatize)
+	if err != nil {
+		writeImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// ImportKindle handles POST /api/wordbook/import/kindle?lang={lang}&enrich={bool}&lemmatize={bool}&on_duplicate={policy}&dry_run={bool}
+// The body is a Kindle Vocabulary Builder vocab.db, uploaded as a multipart
+// "file" field or raw. lang defaults to "en"; "all" imports every supported
+// language.
+func (h *Handler) ImportKindle(c *gin.Context) {
+	dryRun, ok := queryBool(c, "dry_run", false)
+	if !ok {
+		return
+	}
+	enrich, ok := queryBool(c, "enrich", true)
+	if !ok {
+		return
+	}
+	lemmatize, ok := queryBool(c, "lemmatize", false)
+	if !ok {
+		return
+	}
+
+	lang := c.DefaultQuery("lang", "en")
+	if lang == "all" {
+		lang = ""
+	}
+
+	body, _, ok := uploadedFile(c, maxKindleImportSize)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	report, err := h.transferSvc.ImportKindle(c.Request.Context(), defaultUserID, body, services.KindleImportOptions{
+		Language:  lang,
+		Enrich:    enrich,
+		Lemmatize: lemmatize,
+		Policy:    c.Query("on_duplicate"),
+		DryRun:    dryRun,
+	})
+	if err != nil {
+		writeImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// uploadedFile returns the uploaded file from a multipart "file" field or,
+// for other content types, the raw request body, limited to maxSize bytes.
+// On failure it writes a 400 response and returns ok=false.
+func uploadedFile(c *gin.Context, maxSize int64) (body io.ReadCloser, filename string, ok bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+
+	if c.ContentType() != "multipart/form-data" {
+		return c.Request.Body, "", true
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "import file too large"})
+			return nil, "", false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file field is required"})
+		return nil, "", false
+	}
+	return file, header.Filename, true
+}
+
+func writeImportError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "import file too large"})
+	case errors.Is(err, services.ErrUnsupportedFormat),
+		errors.Is(err, services.ErrInvalidPolicy),
+		errors.Is(err, services.ErrMalformedImport),
+		errors.Is(err, services.ErrUnsupportedLanguage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// queryBool reads an optional boolean query parameter, writing a 400
+// response and returning ok=false when it is malformed.
+func queryBool(c *gin.Context, name string, def bool) (bool, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return def, true
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a boolean"})
+		return false, false
+	}
+	return b, true
+}
+
+func formatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return services.FormatCSV
+	case "application/json":
+		return services.FormatJSON
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/repository"
+	"github.com/warriorguo/vocabulary/internal/services"
+)
+
+const (
+	maxWordListSize = 10 << 20 // 10 MiB
+	defaultWarmTop  = 1000
+)
+
+// WarmCache handles POST /api/admin/cache/warm?lang={language}&top={n}&concurrency={n}&rate={n}
+// The word list, one word per line, is taken from a multipart "file" field
+// or the raw body; without one, the top words of the bundled frequency list
+// are used. The run continues in the background after the response, which
+// describes it; poll GET /api/admin/cache/warm/:id for progress.
+func (h *Handler) WarmCache(c *gin.Context) {
+	lang, ok := h.queryLanguage(c)
+	if !ok {
+		return
+	}
+	top, ok := queryPositiveInt(c, "top", defaultWarmTop)
+	if !ok {
+		return
+	}
+	opts, ok := warmOptions(c)
+	if !ok {
+		return
+	}
+
+	body, _, ok := uploadedFile(c, maxWordListSize)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	words, err := services.LoadWordList(body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "word list too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(words) == 0 {
+		if words, err = h.warmer.TopWords(lang, top); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	run, err := h.warmer.Create(c.Request.Context(), lang, words)
+	if err != nil {
+		if errors.Is(err, services.ErrEmptyWordList) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.startWarmRun(run.ID, opts)
+	c.JSON(http.StatusAccepted, gin.H{"run": run})
+}
+
+// ResumeWarmCache handles POST /api/admin/cache/warm/:id/resume?concurrency={n}&rate={n}
+func (h *Handler) ResumeWarmCache(c *gin.Context) {
+	run, ok := h.warmRun(c)
+	if !ok {
+		return
+	}
+	opts, ok := warmOptions(c)
+	if !ok {
+		return
+	}
+
+	if run.Status == models.WarmStatusFinished {
+		c.JSON(http.StatusConflict, gin.H{"error": "cache warming run has finished"})
+		return
+	}
+
+	h.startWarmRun(run.ID, opts)
+	c.JSON(http.StatusAccepted, gin.H{"run": run})
+}
+
+// GetWarmRun handles GET /api/admin/cache/warm/:id
+func (h *Handler) GetWarmRun(c *gin.Context) {
+	run, ok := h.warmRun(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+// GetWarmRuns handles GET /api/admin/cache/warm?limit={limit}
+func (h *Handler) GetWarmRuns(c *gin.Context) {
+	limit, ok := queryPositiveInt(c, "limit", defaultPageSize)
+	if !ok {
+		return
+	}
+	limit = min(limit, maxPageSize)
+
+	runs, err := h.warmer.List(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if runs == nil {
+		runs = []models.WarmRun{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// startWarmRun processes a run in the background, outliving the request
+// that started it.
+func (h *Handler) startWarmRun(id int64, opts services.WarmOptions) {
+	go func() {
+		if _, err := h.warmer.Run(context.Background(), id, opts, nil); err != nil {
+			log.Printf("Warning: cache warming run %d failed: %v", id, err)
+		}
+	}()
+}
+
+// warmRun reads the run named by the :id param, writing an error response
+// and returning ok=false when it is malformed or missing.
+func (h *Handler) warmRun(c *gin.Context) (*models.WarmRun, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be an integer"})
+		return nil, false
+	}
+
+	run, err := h.warmer.Get(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "cache warming run not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return run, true
+}
+
+// warmOptions reads the concurrency and rate query parameters, writing a
+// 400 response and returning ok=false when either is malformed.
+func warmOptions(c *gin.Context) (services.WarmOptions, bool) {
+	concurrency, ok := queryPositiveInt(c, "concurrency", services.DefaultWarmConcurrency)
+	if !ok {
+		return services.WarmOptions{}, false
+	}
+	if concurrency > services.MaxWarmConcurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "concurrency must be at most " + strconv.Itoa(services.MaxWarmConcurrency)})
+		return services.WarmOptions{}, false
+	}
+
+	rate, ok := queryFloat(c, "rate")
+	if !ok {
+		return services.WarmOptions{}, false
+	}
+	opts := services.WarmOptions{Concurrency: concurrency, Rate: services.DefaultWarmRate}
+	if rate != nil {
+		if *rate < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rate must not be negative"})
+			return services.WarmOptions{}, false
+		}
+		opts.Rate = *rate
+	}
+	return opts, true
+}
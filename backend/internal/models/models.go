@@ -8,25 +8,148 @@ import (
 type WordbookEntry struct {
 	ID              int64     `json:"id"`
 	UserID          string    `json:"user_id"`
+	Language        string    `json:"language"`
 	Word            string    `json:"word"`
 	ShortDefinition string    `json:"short_definition"`
+	Notes           string    `json:"notes,omitempty"`
+	Tags            []string  `json:"tags,omitempty"`
 	CreatedAt       time.Time `json:"created_at"`
+	ReviewState
+	WordLevels
+	// SelectedSense indexes the meanings of SelectedSnapshot
+	SelectedSense *SenseRef `json:"selected_sense,omitempty"`
+	// SelectedHomograph pins the homograph of the snapshot the user means,
+	// e.g. "bass" the fish rather than the sound
+	SelectedHomograph *int             `json:"selected_homograph,omitempty"`
+	Snapshot          *DictionaryEntry `json:"snapshot,omitempty"`
+	Contexts          []WordContext    `json:"contexts,omitempty"`
 }
 
+// WordContext records where the user encountered a word
+type WordContext struct {
+	ID          int64     `json:"id"`
+	Sentence    string    `json:"sentence" binding:"required"`
+	SourceTitle string    `json:"source_title,omitempty"`
+	SourceURL   string    `json:"source_url,omitempty" binding:"omitempty,url"`
+	Location    string    `json:"location,omitempty" binding:"max=64"` // page number, chapter or media timestamp
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SenseRef points at the definition the user chose within a dictionary entry
+type SenseRef struct {
+	MeaningIndex    int `json:"meaning_index"`
+	DefinitionIndex int `json:"definition_index"`
+}
+
+// ReviewState holds the spaced-repetition schedule of a wordbook entry
+type ReviewState struct {
+	Ease         float64   `json:"ease"`
+	IntervalDays int       `json:"interval_days"`
+	Repetitions  int       `json:"repetitions"`
+	DueAt        time.Time `json:"due_at"`
+}
+
+// WordLevels tells how common and how advanced a word is. Zero values mean
+// the word isn't in the bundled lists.
+type WordLevels struct {
+	FrequencyRank int     `json:"frequency_rank,omitempty"`
+	Zipf          float64 `json:"zipf,omitempty"`
+	CEFRLevel     string  `json:"cefr_level,omitempty"`
+}
+
+// ReviewLog represents a single recorded review of a wordbook entry
+type ReviewLog struct {
+	ID           int64     `json:"id"`
+	UserID       string    `json:"user_id"`
+	Word         string    `json:"word"`
+	Grade        int       `json:"grade"`
+	IntervalDays int       `json:"interval_days"`
+	ReviewedAt   time.Time `json:"reviewed_at"`
+}
+
+// UserSettings represents per-user preferences
+type UserSettings struct {
+	UserID   string `json:"user_id"`
+	TimeZone string `json:"time_zone"`
+	// TranslationLanguage is the language dictionary entries are glossed in:
+	// empty to follow the browser's Accept-Language, or "off"
+	TranslationLanguage string    `json:"translation_language"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// CacheSchemaVersion is the version of the DictionaryEntry JSON written to
+// the dictionary cache. Bump it with any change to DictionaryEntry that older
+// rows would be misread under, registering an upgrade from the previous
+// version with the dictionary service.
+//
+//   - 1: phonetics and meanings only
+//   - 2: homographs, which phonetics and meanings merge
+const CacheSchemaVersion = 2
+
 // DictionaryCache represents cached dictionary data
 type DictionaryCache struct {
-	Word      string    `json:"word"`
-	Data      []byte    `json:"data"`
+	Language      string `json:"language"`
+	Word          string `json:"word"`
+	Data          []byte `json:"data"`
+	SchemaVersion int    `json:"schema_version"` // of Data
+	// Raw is the upstream payload Data was normalized from, when the source
+	// has one; it is only read where needed
+	Raw       []byte    `json:"-"`
 	Source    string    `json:"source"`
 	FetchedAt time.Time `json:"fetched_at"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
+// CacheEntryInfo describes a dictionary cache row without its data
+type CacheEntryInfo struct {
+	Language      string    `json:"language"`
+	Word          string    `json:"word"`
+	Source        string    `json:"source"`
+	Size          int       `json:"size"`               // bytes of its JSON
+	RawSize       int       `json:"raw_size,omitempty"` // compressed bytes of its upstream payload
+	SchemaVersion int       `json:"schema_version"`
+	FetchedAt     time.Time `json:"fetched_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	Expired       bool      `json:"expired"`
+}
+
+// CacheStats reports the size of the dictionary cache and how well it is
+// serving lookups
+type CacheStats struct {
+	Entries    int64            `json:"entries"`
+	Expired    int64            `json:"expired"`
+	DataBytes  int64            `json:"data_bytes"`
+	TableBytes int64            `json:"table_bytes"` // including indexes and overhead
+	Sources    map[string]int64 `json:"sources"`     // entries per source
+	// Versions counts entries per schema version; those below
+	// CacheSchemaVersion are upgraded when read or by rewritecache
+	Versions map[int]int64 `json:"versions"`
+	// Hits and Misses count cache reads on this server since it started
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// AudioFile describes a pronunciation recording stored by the audio proxy.
+// The file itself is kept in the audio store under its checksum.
+type AudioFile struct {
+	Language    string    `json:"language"`
+	Word        string    `json:"word"`
+	Accent      string    `json:"accent"` // e.g. "us" or "uk"; empty for the best available
+	SourceURL   string    `json:"source_url"`
+	ContentType string    `json:"content_type"`
+	Checksum    string    `json:"checksum"` // hex SHA-256 of the file
+	Size        int64     `json:"size"`
+	Synthetic   bool      `json:"synthetic"` // generated by text-to-speech; SourceURL is empty
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
 // Phonetic represents pronunciation information
 type Phonetic struct {
 	Text      string `json:"text,omitempty"`
 	Audio     string `json:"audio,omitempty"`
 	SourceURL string `json:"sourceUrl,omitempty"`
+	Synthetic bool   `json:"synthetic,omitempty"` // Audio is generated by text-to-speech rather than recorded
 }
 
 // Definition represents a single definition
@@ -47,14 +170,422 @@ type Meaning struct {
 
 // DictionaryEntry represents the normalized dictionary response
 type DictionaryEntry struct {
-	Word      string     `json:"word"`
+	Word     string `json:"word"`
+	Language string `json:"language,omitempty"`
+	// Phonetics and Meanings merge those of the homographs, combining
+	// meanings of the same part of speech
+	Phonetics  []Phonetic  `json:"phonetics"`
+	Meanings   []Meaning   `json:"meanings"`
+	Homographs []Homograph `json:"homographs,omitempty"`
+	SourceURL  string      `json:"sourceUrl,omitempty"`
+	// QueriedForm is the inflected form that was looked up when it differs
+	// from Word, e.g. "mice" for the entry of "mouse"
+	QueriedForm string `json:"queriedForm,omitempty"`
+	// Lemma is the base form of an inflected word that has an entry of its
+	// own, offered as a link, e.g. "run" for the entry of "running"
+	Lemma string `json:"lemma,omitempty"`
+	// FrequencyRank, Zipf and CEFRLevel describe how common and how advanced
+	// the word is; they are zero for words the bundled lists don't have
+	FrequencyRank int     `json:"frequencyRank,omitempty"`
+	Zipf          float64 `json:"zipf,omitempty"`
+	CEFRLevel     string  `json:"cefrLevel,omitempty"`
+	// Translation glosses the headword in the reader's language
+	Translation *Translation `json:"translation,omitempty"`
+	// Curated marks an entry written or amended by an admin
+	Curated bool `json:"curated,omitempty"`
+}
+
+// Homograph groups the senses of a word sharing one origin and
+// pronunciation, such as "bass" the fish apart from "bass" the low sound
+type Homograph struct {
 	Phonetics []Phonetic `json:"phonetics"`
+	Origin    string     `json:"origin,omitempty"` // etymology, when the source gives one
 	Meanings  []Meaning  `json:"meanings"`
-	SourceURL string     `json:"sourceUrl,omitempty"`
 }
 
-// AddWordRequest represents the request body for adding a word
+// Override actions accepted in EntryOverride
+const (
+	OverrideReplaceDefinition = "replace_definition"
+	OverrideAddDefinition     = "add_definition"
+	OverrideRemoveDefinition  = "remove_definition"
+	OverrideRemoveMeaning     = "remove_meaning"
+	OverrideSetPhonetic       = "set_phonetic"
+)
+
+// EntryOverride amends one part of a dictionary entry. Meanings are
+// addressed by part of speech and definitions by their index within it.
+type EntryOverride struct {
+	Action       string `json:"action"`
+	PartOfSpeech string `json:"part_of_speech,omitempty"`
+	Index        int    `json:"index,omitempty"`
+	Definition   string `json:"definition,omitempty"`
+	Example      string `json:"example,omitempty"`
+	Phonetic     string `json:"phonetic,omitempty"`
+}
+
+// CustomEntry is an admin's curation of a word: an entry used instead of the
+// dictionary's, overrides layered on the dictionary's entry, or both, the
+// overrides then applying to the custom entry.
+type CustomEntry struct {
+	Language  string           `json:"language"`
+	Word      string           `json:"word"`
+	Entry     *DictionaryEntry `json:"entry"`
+	Overrides []EntryOverride  `json:"overrides"`
+	UpdatedBy string           `json:"updated_by"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// CustomEntryRequest creates or replaces a custom entry
+type CustomEntryRequest struct {
+	Entry     *DictionaryEntry `json:"entry"`
+	Overrides []EntryOverride  `json:"overrides"`
+}
+
+// Custom entry change actions
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// CustomEntryChange is an audit record of a change to a custom entry
+type CustomEntryChange struct {
+	ID        int64        `json:"id"`
+	Language  string       `json:"language"`
+	Word      string       `json:"word"`
+	Action    string       `json:"action"`
+	Actor     string       `json:"actor"`
+	Before    *CustomEntry `json:"before"`
+	After     *CustomEntry `json:"after"`
+	ChangedAt time.Time    `json:"changed_at"`
+}
+
+// Translation holds the glosses of a headword in another language
+type Translation struct {
+	Language string   `json:"language"`
+	Glosses  []string `json:"glosses"`
+	Source   string   `json:"source,omitempty"`
+}
+
+// HasSense reports whether ref points at an existing definition of the entry
+func (e *DictionaryEntry) HasSense(ref SenseRef) bool {
+	if ref.MeaningIndex < 0 || ref.MeaningIndex >= len(e.Meanings) {
+		return false
+	}
+	defs := e.Meanings[ref.MeaningIndex].Definitions
+	return ref.DefinitionIndex >= 0 && ref.DefinitionIndex < len(defs)
+}
+
+// HasHomograph reports whether e has a homograph at index i
+func (e *DictionaryEntry) HasHomograph(i int) bool {
+	return i >= 0 && i < len(e.Homographs)
+}
+
+// HomographEntry returns a copy of e holding only its homograph at index
+// i, or e itself when it has none there.
+func (e *DictionaryEntry) HomographEntry(i int) *DictionaryEntry {
+	if !e.HasHomograph(i) {
+		return e
+	}
+	h := e.Homographs[i]
+	narrowed := *e
+	narrowed.Phonetics, narrowed.Meanings = h.Phonetics, h.Meanings
+	narrowed.Homographs = []Homograph{h}
+	return &narrowed
+}
+
+// SelectedSnapshot returns the snapshot narrowed to the pinned homograph, or
+// the whole snapshot when none is pinned. It is nil without a snapshot. It is
+// for rendering the entry, as on cards; responses keep the whole snapshot,
+// which SelectedHomograph indexes.
+func (e *WordbookEntry) SelectedSnapshot() *DictionaryEntry {
+	if e.Snapshot == nil || e.SelectedHomograph == nil {
+		return e.Snapshot
+	}
+	return e.Snapshot.HomographEntry(*e.SelectedHomograph)
+}
+
+// MergeFrom folds an incoming copy of the same word into e: tags are unioned,
+// differing notes are appended, unseen contexts are added, word levels are
+// taken from other when it has them, and the existing definition, snapshot
+// and review state are kept unless e lacks them.
+func (e *WordbookEntry) MergeFrom(other *WordbookEntry) {
+	if e.ShortDefinition == "" {
+		e.ShortDefinition = other.ShortDefinition
+	}
+
+	switch {
+	case e.Notes == "":
+		e.Notes = other.Notes
+	case other.Notes != "" && other.Notes != e.Notes:
+		e.Notes += "\n\n" + other.Notes
+	}
+
+	seenTags := make(map[string]bool, len(e.Tags))
+	for _, tag := range e.Tags {
+		seenTags[tag] = true
+	}
+	for _, tag := range other.Tags {
+		if !seenTags[tag] {
+			seenTags[tag] = true
+			e.Tags = append(e.Tags, tag)
+		}
+	}
+
+	seenSentences := make(map[string]bool, len(e.Contexts))
+	for _, wc := range e.Contexts {
+		seenSentences[wc.Sentence] = true
+	}
+	for _, wc := range other.Contexts {
+		if !seenSentences[wc.Sentence] {
+			seenSentences[wc.Sentence] = true
+			wc.ID = 0
+			e.Contexts = append(e.Contexts, wc)
+		}
+	}
+
+	if other.WordLevels != (WordLevels{}) {
+		e.WordLevels = other.WordLevels
+	}
+
+	if e.Snapshot == nil {
+		e.Snapshot = other.Snapshot
+		e.SelectedSense = other.SelectedSense
+		e.SelectedHomograph = other.SelectedHomograph
+	}
+}
+
+// AddWordRequest represents the request body for adding a word.
+// When SaveSnapshot is set, the full dictionary entry is stored with the word
+// so it stays available after the cache expires or the upstream changes.
+// Context is appended to the entry's encounter contexts, also on re-adds.
 type AddWordRequest struct {
 	Word            string `json:"word" binding:"required"`
+	Language        string `json:"language,omitempty"` // defaults to English
 	ShortDefinition string `json:"short_definition" binding:"required"`
+	SaveSnapshot    bool   `json:"save_snapshot,omitempty"`
+	// AcceptLemma saves an inflected word under its lemma, as a lookup
+	// offers it, so "running" and "run" share one entry
+	AcceptLemma bool `json:"accept_lemma,omitempty"`
+	// SelectedSense indexes the meanings of the selected homograph, or of
+	// the whole snapshot when no homograph is selected
+	SelectedSense *SenseRef `json:"selected_sense,omitempty"`
+	// SelectedHomograph indexes the snapshot's homographs
+	SelectedHomograph *int         `json:"selected_homograph,omitempty"`
+	Context           *WordContext `json:"context,omitempty"`
+	Notes             *string      `json:"notes,omitempty"`
+	Tags              []string     `json:"tags,omitempty" binding:"omitempty,dive,required,max=64"`
+}
+
+// ClozeItem is a fill-in-the-blank exercise built from a sentence
+type ClozeItem struct {
+	Word   string `json:"word"`
+	Text   string `json:"text"`
+	Answer string `json:"answer"`
+	Source string `json:"source"`
+}
+
+// ReviewRequest represents the request body for grading a review
+type ReviewRequest struct {
+	Grade *int `json:"grade" binding:"required,min=0,max=5"`
+}
+
+// UpdateSettingsRequest represents the request body for updating user settings
+// Fields left out keep their current values.
+type UpdateSettingsRequest struct {
+	TimeZone            string  `json:"time_zone" binding:"required_without=TranslationLanguage"`
+	TranslationLanguage *string `json:"translation_language"`
+}
+
+// DailyCount is a count bucketed by calendar day in the user's time zone
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// PartOfSpeechCount is the number of wordbook entries for a part of speech
+type PartOfSpeechCount struct {
+	PartOfSpeech string `json:"part_of_speech"`
+	Count        int    `json:"count"`
+}
+
+// LearningStats represents the aggregated learning statistics of a user
+type LearningStats struct {
+	TimeZone      string              `json:"time_zone"`
+	TotalWords    int                 `json:"total_words"`
+	TotalReviews  int                 `json:"total_reviews"`
+	RetentionRate float64             `json:"retention_rate"`
+	CurrentStreak int                 `json:"current_streak"`
+	LongestStreak int                 `json:"longest_streak"`
+	WordsAdded    []DailyCount        `json:"words_added"`
+	Reviews       []DailyCount        `json:"reviews"`
+	DueForecast   []DailyCount        `json:"due_forecast"`
+	PartsOfSpeech []PartOfSpeechCount `json:"parts_of_speech"`
+}
+
+// LookupEvent represents a single dictionary lookup made by a user
+type LookupEvent struct {
+	ID         int64     `json:"id"`
+	UserID     string    `json:"user_id"`
+	Language   string    `json:"language"`
+	Word       string    `json:"word"`
+	Found      bool      `json:"found"`
+	LookedUpAt time.Time `json:"looked_up_at"`
+}
+
+// LookupSuggestion is a frequently looked-up word that is not yet in the wordbook
+type LookupSuggestion struct {
+	Language       string    `json:"language"`
+	Word           string    `json:"word"`
+	LookupCount    int       `json:"lookup_count"`
+	LastLookedUpAt time.Time `json:"last_looked_up_at"`
+}
+
+// JobRun is one run of a scheduled maintenance job
+type JobRun struct {
+	ID         int64      `json:"id"`
+	Job        string     `json:"job"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"` // nil while running, or if the run was interrupted
+	Result     string     `json:"result"`      // what the run did, e.g. "purged 12 expired entries"
+	Error      string     `json:"error"`
+}
+
+// JobStatus summarizes a scheduled job for monitoring
+type JobStatus struct {
+	Name      string  `json:"name"`
+	Interval  string  `json:"interval"`
+	LastRun   *JobRun `json:"last_run"`
+	LastError *JobRun `json:"last_error"` // the most recent failed run
+}
+
+// ReviewReminder tells a user that words are due for review
+type ReviewReminder struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Day       string    `json:"day"` // YYYY-MM-DD in the user's time zone
+	DueCount  int       `json:"due_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Cache warming run statuses
+const (
+	WarmStatusRunning     = "running"
+	WarmStatusInterrupted = "interrupted"
+	WarmStatusFinished    = "finished"
+)
+
+// WarmRun tracks a pass looking up a word list to fill the dictionary cache.
+// Its progress is saved as it goes, so an interrupted run can be resumed.
+type WarmRun struct {
+	ID       int64  `json:"id"`
+	Language string `json:"language"`
+	Total    int    `json:"total"`
+	// Position is the number of words of the list already looked up
+	Position  int        `json:"position"`
+	Fetched   int        `json:"fetched"` // fetched upstream and cached
+	Cached    int        `json:"cached"`  // already in the cache
+	NotFound  []string   `json:"not_found"`
+	Failed    int        `json:"failed"`
+	LastError string     `json:"last_error"`
+	Status    string     `json:"status"`
+	StartedAt time.Time  `json:"started_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+}
+
+// SpellingSuggestion is a known word offered as a correction for a word the
+// dictionary doesn't have
+type SpellingSuggestion struct {
+	Word     string `json:"word"`
+	Distance int    `json:"distance"`
+	Match    string `json:"match"`
+}
+
+// Completion is a word offered to complete a search box prefix
+type Completion struct {
+	Word          string `json:"word"`
+	InWordbook    bool   `json:"in_wordbook"`
+	FrequencyRank int    `json:"frequency_rank,omitempty"`
+}
+
+// Import duplicate-handling policies
+const (
+	ImportSkip      = "skip"
+	ImportOverwrite = "overwrite"
+	ImportMerge     = "merge"
+)
+
+// Import row outcomes
+const (
+	ImportActionCreated     = "created"
+	ImportActionOverwritten = "overwritten"
+	ImportActionMerged      = "merged"
+	ImportActionSkipped     = "skipped"
+	ImportActionFailed      = "failed"
+)
+
+// ImportRowResult reports what happened to a single imported row
+type ImportRowResult struct {
+	Row    int    `json:"row"`
+	Word   string `json:"word"`
+	Action string `json:"action"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport summarizes a wordbook import
+type ImportReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Policy  string            `json:"policy"`
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// Add tallies a row result into the report
+func (r *ImportReport) Add(result ImportRowResult) {
+	r.Total++
+	switch result.Action {
+	case ImportActionCreated:
+		r.Created++
+	case ImportActionOverwritten, ImportActionMerged:
+		r.Updated++
+	case ImportActionSkipped:
+		r.Skipped++
+	case ImportActionFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// AnalyzeRequest is the JSON body of a text analysis request
+type AnalyzeRequest struct {
+	Text   string `json:"text" binding:"required"`
+	Format string `json:"format" binding:"omitempty,oneof=text txt html srt"`
+	Title  string `json:"title"`
+	Limit  int    `json:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// WordCandidate is a word from an analyzed text that the user doesn't know
+// yet, with the first sentence it appeared in ready to save as a context
+type WordCandidate struct {
+	Word          string      `json:"word"`
+	Forms         []string    `json:"forms"`
+	Count         int         `json:"count"`
+	FrequencyRank int         `json:"frequency_rank,omitempty"`
+	Context       WordContext `json:"context"`
+}
+
+// TextAnalysis is the result of harvesting unknown words from a text
+type TextAnalysis struct {
+	Title       string          `json:"title,omitempty"`
+	TotalTokens int             `json:"total_tokens"`
+	UniqueWords int             `json:"unique_words"`
+	KnownWords  int             `json:"known_words"`
+	Candidates  []WordCandidate `json:"candidates"`
 }
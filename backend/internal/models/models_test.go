@@ -103,3 +103,143 @@ func TestAddWordRequestValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestDictionaryEntryHasSense(t *testing.T) {
+	entry := DictionaryEntry{
+		Word: "bank",
+		Meanings: []Meaning{
+			{PartOfSpeech: "noun", Definitions: []Definition{{Definition: "a financial institution"}, {Definition: "the side of a river"}}},
+			{PartOfSpeech: "verb", Definitions: []Definition{{Definition: "to deposit money"}}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		ref  SenseRef
+		want bool
+	}{
+		{"first definition", SenseRef{0, 0}, true},
+		{"second meaning", SenseRef{1, 0}, true},
+		{"definition out of range", SenseRef{1, 1}, false},
+		{"meaning out of range", SenseRef{2, 0}, false},
+		{"negative index", SenseRef{-1, 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entry.HasSense(tt.ref); got != tt.want {
+				t.Errorf("HasSense(%+v) = %v, want %v", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDictionaryEntryHomographEntry(t *testing.T) {
+	entry := &DictionaryEntry{
+		Word: "bass",
+		Homographs: []Homograph{
+			{Phonetics: []Phonetic{{Text: "/beɪs/"}}, Meanings: []Meaning{{PartOfSpeech: "noun", Definitions: []Definition{{Definition: "a low sound"}}}}},
+			{Phonetics: []Phonetic{{Text: "/bæs/"}}, Origin: "Old English bærs", Meanings: []Meaning{{PartOfSpeech: "noun", Definitions: []Definition{{Definition: "a fish"}}}}},
+		},
+	}
+
+	fish := entry.HomographEntry(1)
+	if fish == entry || fish.Word != "bass" || len(fish.Homographs) != 1 || fish.Homographs[0].Origin != "Old English bærs" {
+		t.Fatalf("expected a copy holding the second homograph, got %+v", fish)
+	}
+	if len(fish.Meanings) != 1 || fish.Meanings[0].Definitions[0].Definition != "a fish" || fish.Phonetics[0].Text != "/bæs/" {
+		t.Errorf("expected the homograph's phonetics and meanings, got %+v", fish)
+	}
+	if len(entry.Homographs) != 2 {
+		t.Errorf("expected the entry left unchanged, got %+v", entry.Homographs)
+	}
+
+	for _, i := range []int{-1, 2} {
+		if entry.HasHomograph(i) || entry.HomographEntry(i) != entry {
+			t.Errorf("expected no homograph at %d", i)
+		}
+	}
+}
+
+func TestWordbookEntrySelectedSnapshot(t *testing.T) {
+	snapshot := &DictionaryEntry{
+		Word:     "bass",
+		Meanings: []Meaning{{PartOfSpeech: "noun", Definitions: []Definition{{Definition: "a low sound"}, {Definition: "a fish"}}}},
+		Homographs: []Homograph{
+			{Meanings: []Meaning{{PartOfSpeech: "noun", Definitions: []Definition{{Definition: "a low sound"}}}}},
+			{Meanings: []Meaning{{PartOfSpeech: "noun", Definitions: []Definition{{Definition: "a fish"}}}}},
+		},
+	}
+
+	entry := &WordbookEntry{Snapshot: snapshot}
+	if entry.SelectedSnapshot() != snapshot {
+		t.Error("expected the whole snapshot without a pinned homograph")
+	}
+
+	// The selected sense indexes the pinned homograph's definitions
+	pinned := 1
+	entry.SelectedHomograph = &pinned
+	fish := entry.SelectedSnapshot()
+	if !fish.HasSense(SenseRef{0, 0}) || fish.HasSense(SenseRef{0, 1}) || fish.Meanings[0].Definitions[0].Definition != "a fish" {
+		t.Errorf("expected the fish homograph, got %+v", fish.Meanings)
+	}
+
+	if (&WordbookEntry{SelectedHomograph: &pinned}).SelectedSnapshot() != nil {
+		t.Error("expected no snapshot without one stored")
+	}
+}
+
+func TestWordbookEntryMergeFrom(t *testing.T) {
+	existing := WordbookEntry{
+		Word:            "hello",
+		ShortDefinition: "a greeting",
+		Notes:           "mine",
+		Tags:            []string{"basics"},
+		Contexts:        []WordContext{{ID: 1, Sentence: "Hello there."}},
+		ReviewState:     ReviewState{Ease: 2.7, Repetitions: 3},
+	}
+	incoming := WordbookEntry{
+		Word:            "hello",
+		ShortDefinition: "imported definition",
+		Notes:           "theirs",
+		Tags:            []string{"basics", "greetings"},
+		Contexts:        []WordContext{{ID: 9, Sentence: "Hello there."}, {ID: 10, Sentence: "Hello again."}},
+		Snapshot:        &DictionaryEntry{Word: "hello"},
+		ReviewState:     ReviewState{Ease: 1.3},
+	}
+
+	existing.MergeFrom(&incoming)
+
+	if existing.ShortDefinition != "a greeting" {
+		t.Errorf("definition should be kept, got %q", existing.ShortDefinition)
+	}
+	if existing.Notes != "mine\n\ntheirs" {
+		t.Errorf("notes not appended: %q", existing.Notes)
+	}
+	if len(existing.Tags) != 2 || existing.Tags[1] != "greetings" {
+		t.Errorf("tags not unioned: %v", existing.Tags)
+	}
+	if len(existing.Contexts) != 2 || existing.Contexts[1].ID != 0 {
+		t.Errorf("expected one new context without ID: %+v", existing.Contexts)
+	}
+	if existing.Snapshot == nil {
+		t.Error("missing snapshot should be taken from incoming")
+	}
+	if existing.Ease != 2.7 || existing.Repetitions != 3 {
+		t.Errorf("review state should be kept: %+v", existing.ReviewState)
+	}
+}
+
+func TestImportReportAdd(t *testing.T) {
+	var report ImportReport
+	for _, action := range []string{ImportActionCreated, ImportActionMerged, ImportActionOverwritten, ImportActionSkipped, ImportActionFailed} {
+		report.Add(ImportRowResult{Action: action})
+	}
+
+	if report.Total != 5 || report.Created != 1 || report.Updated != 2 || report.Skipped != 1 || report.Failed != 1 {
+		t.Errorf("unexpected tallies: %+v", report)
+	}
+	if len(report.Rows) != 5 {
+		t.Errorf("expected 5 rows, got %d", len(report.Rows))
+	}
+}
@@ -0,0 +1,107 @@
+// Package normalize canonicalizes words and language codes, so the same word
+// typed in different ways maps to one dictionary cache and wordbook key.
+package normalize
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxWordLength is the longest word, in characters, the database stores
+const MaxWordLength = 128
+
+// ErrInvalidWord is returned for a word that is empty or too long to store
+var ErrInvalidWord = errors.New("invalid word")
+
+// apostrophes maps the typographic apostrophes that word processors and
+// phone keyboards substitute to the ASCII one, so "don’t" matches "don't"
+var apostrophes = strings.NewReplacer(
+	"‘", "'", // left single quotation mark
+	"’", "'", // right single quotation mark
+	"ʼ", "'", // modifier letter apostrophe
+	"′", "'", // prime
+	"＇", "'", // fullwidth apostrophe
+)
+
+// Language reduces a language tag to its lowercase primary subtag, so
+// "pt-BR", "PT_br" and "pt" all name Portuguese.
+func Language(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// Form returns word as dictionaries should be asked for it: typographic
+// apostrophes made ASCII, composed to Unicode NFC and with runs of whitespace
+// collapsed, like Word, but in its own case. Sources such as Wiktionary have
+// case-sensitive titles ("Haus", not "haus"), so keys never go upstream.
+func Form(word string) string {
+	word = norm.NFC.String(apostrophes.Replace(word))
+	return strings.Join(strings.Fields(word), " ")
+}
+
+// Word returns the key of word in the given language: typographic
+// apostrophes made ASCII, case folded, composed to Unicode NFC, and with runs
+// of whitespace collapsed to single spaces. Folding is full ("ﬁ" becomes
+// "fi", "ς" becomes "σ") with one exception: German "ß" is kept rather than
+// folded to "ss", since it tells words apart ("Maße" and "Masse"), and
+// capital "ẞ" folds to it. Turkic languages are lowercased by their own rules
+// instead, so "I" becomes dotless "ı". Diacritics are kept, since they tell
+// words apart in most languages ("año" and "ano").
+//
+// Every layer that reads or writes a word by key goes through Word, so the
+// result is the same whether it is applied once or several times. It returns
+// ErrInvalidWord for a word that is empty or longer than MaxWordLength.
+func Word(lang, word string) (string, error) {
+	word = norm.NFC.String(fold(lang, Form(word)))
+
+	switch {
+	case word == "":
+		return "", fmt.Errorf("%w: word cannot be empty", ErrInvalidWord)
+	case utf8.RuneCountInString(word) > MaxWordLength:
+		return "", fmt.Errorf("%w: word exceeds %d characters", ErrInvalidWord, MaxWordLength)
+	}
+	return word, nil
+}
+
+// fold applies the case mapping of Word for a language
+func fold(lang, word string) string {
+	if tag, err := language.Parse(lang); err == nil {
+		if base, _ := tag.Base(); base.String() == "tr" || base.String() == "az" {
+			return cases.Lower(tag).String(word)
+		}
+	}
+
+	// Folding maps each character on its own, so the text around every "ß"
+	// can be folded apart from it
+	parts := strings.Split(strings.ReplaceAll(word, "ẞ", "ß"), "ß")
+	caser := cases.Fold()
+	for i, part := range parts {
+		parts[i] = caser.String(part)
+	}
+	return strings.Join(parts, "ß")
+}
+
+// StripDiacritics removes combining marks from s, turning "café" into
+// "cafe". Letters that aren't composed with a mark, such as "ß" or "ø", are
+// left alone.
+func StripDiacritics(s string) string {
+	decomposed := norm.NFD.String(s)
+	var b strings.Builder
+	b.Grow(len(decomposed))
+	for _, r := range decomposed {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(b.String())
+}
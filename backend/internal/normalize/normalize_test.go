@@ -0,0 +1,73 @@
+package normalize
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLanguage(t *testing.T) {
+	for in, want := range map[string]string{"en": "en", "pt-BR": "pt", " DE_at ": "de", "": ""} {
+		if got := Language(in); got != want {
+			t.Errorf("Language(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWord(t *testing.T) {
+	tests := []struct {
+		lang, word, want string
+	}{
+		{"en", "  Apple ", "apple"},
+		{"es", "Año", "año"},
+		// "e" followed by a combining acute accent composes to "é"
+		{"fr", "Cafe\u0301", "café"},
+		{"tr", "Istanbul", "ıstanbul"},
+		{"de", "STRASSE", "strasse"},
+		// Folding is full, except that "ß" is kept apart from "ss"
+		{"de", "Straße", "straße"},
+		{"de", "STRA\u1e9eE", "straße"},
+		{"en", "\ufb01ne", "fine"},
+		{"el", "ΟΔΟΣ οδος", "οδοσ οδοσ"},
+		{"xx-invalid!", "Word", "word"},
+		{"en", "Don\u2019t", "don't"},
+		{"en", " look\t\u00a0 UP\n", "look up"},
+	}
+	for _, tt := range tests {
+		got, err := Word(tt.lang, tt.word)
+		if err != nil || got != tt.want {
+			t.Errorf("Word(%q, %q) = %q, %v, want %q", tt.lang, tt.word, got, err, tt.want)
+		}
+		if again, _ := Word(tt.lang, got); again != got {
+			t.Errorf("Word(%q, %q) = %q, not stable under renormalization", tt.lang, got, again)
+		}
+	}
+}
+
+func TestForm(t *testing.T) {
+	for in, want := range map[string]string{"  Haus ": "Haus", "Cafe\u0301": "Café", "Don\u2019t  Stop": "Don't Stop"} {
+		if got := Form(in); got != want {
+			t.Errorf("Form(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWordInvalid(t *testing.T) {
+	for _, word := range []string{"", " \t ", strings.Repeat("é", MaxWordLength+1)} {
+		if _, err := Word("en", word); !errors.Is(err, ErrInvalidWord) {
+			t.Errorf("Word(%q) error = %v, want ErrInvalidWord", word, err)
+		}
+	}
+	// The limit counts characters rather than bytes
+	if _, err := Word("en", strings.Repeat("é", MaxWordLength)); err != nil {
+		t.Errorf("unexpected error for a word at the limit: %v", err)
+	}
+}
+
+func TestStripDiacritics(t *testing.T) {
+	for in, want := range map[string]string{"café": "cafe", "naïve": "naive", "año": "ano", "straße": "straße", "plain": "plain"} {
+		if got := StripDiacritics(in); got != want {
+			t.Errorf("StripDiacritics(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
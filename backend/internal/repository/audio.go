@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+)
+
+// Audio file operations
+
+// GetAudioFile returns the stored recording of a word in an accent, or nil
+// if none has been fetched yet.
+func (r *Repository) GetAudioFile(ctx context.Context, language, word, accent string) (*models.AudioFile, error) {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT language, word, accent, source_url, content_type, checksum, size, synthetic, fetched_at
+		FROM audio_files
+		WHERE language = $1 AND word = $2 AND accent = $3`
+
+	var f models.AudioFile
+	err = r.db.QueryRow(ctx, query, language, word, accent).Scan(
+		&f.Language, &f.Word, &f.Accent, &f.SourceURL, &f.ContentType, &f.Checksum, &f.Size, &f.Synthetic, &f.FetchedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// SaveAudioFile records a fetched recording, replacing any earlier one for
+// the same word and accent.
+func (r *Repository) SaveAudioFile(ctx context.Context, f *models.AudioFile) error {
+	word, err := normalize.Word(f.Language, f.Word)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audio_files (language, word, accent, source_url, content_type, checksum, size, synthetic, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		ON CONFLICT (language, word, accent) DO UPDATE SET
+			source_url = EXCLUDED.source_url,
+			content_type = EXCLUDED.content_type,
+			checksum = EXCLUDED.checksum,
+			size = EXCLUDED.size,
+			synthetic = EXCLUDED.synthetic,
+			fetched_at = EXCLUDED.fetched_at
+		RETURNING word, fetched_at`
+
+	return r.db.QueryRow(ctx, query, f.Language, word, f.Accent, f.SourceURL, f.ContentType, f.Checksum, f.Size, f.Synthetic).
+		Scan(&f.Word, &f.FetchedAt)
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+)
+
+// Cache administration
+
+// CacheFilter narrows a dictionary cache listing or purge. The zero value
+// matches every row.
+type CacheFilter struct {
+	Language string
+	// Word matches a single headword exactly
+	Word string
+	// Search matches headwords containing it
+	Search string
+	Source string
+	// FetchedBefore matches rows fetched before it, when not zero
+	FetchedBefore time.Time
+}
+
+// where returns the SQL condition and arguments selecting the rows matched
+// by the filter.
+func (f CacheFilter) where() (string, []any, error) {
+	conditions := []string{"TRUE"}
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.Language != "" {
+		add("language = $%d", f.Language)
+	}
+	if f.Word != "" {
+		word, err := normalize.Word(f.Language, f.Word)
+		if err != nil {
+			return "", nil, err
+		}
+		add("word = $%d", word)
+	}
+	if f.Search != "" {
+		add("strpos(word, $%d) > 0", strings.ToLower(f.Search))
+	}
+	if f.Source != "" {
+		add("source = $%d", f.Source)
+	}
+	if !f.FetchedBefore.IsZero() {
+		add("fetched_at < $%d", f.FetchedBefore)
+	}
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// GetCacheEntries returns a page of the cache rows matching filter, sorted
+// by word, together with the number of rows matched.
+func (r *Repository) GetCacheEntries(ctx context.Context, filter CacheFilter, limit, offset int) ([]models.CacheEntryInfo, int, error) {
+	where, args, err := filter.where()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM dictionary_cache WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT language, word, source, octet_length(data::text), COALESCE(octet_length(raw), 0), schema_version, fetched_at, expires_at, expires_at <= NOW()
+		FROM dictionary_cache
+		WHERE %s
+		ORDER BY word, language
+		LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2)
+
+	rows, err := r.db.Query(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []models.CacheEntryInfo
+	for rows.Next() {
+		var e models.CacheEntryInfo
+		if err := rows.Scan(&e.Language, &e.Word, &e.Source, &e.Size, &e.RawSize, &e.SchemaVersion, &e.FetchedAt, &e.ExpiresAt, &e.Expired); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, total, rows.Err()
+}
+
+// GetCacheRow returns the cache row of word, expired or not, or ErrNotFound.
+func (r *Repository) GetCacheRow(ctx context.Context, language, word string) (*models.DictionaryCache, error) {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT language, word, data, schema_version, raw, source, fetched_at, expires_at
+		FROM dictionary_cache
+		WHERE language = $1 AND word = $2`
+
+	cache, err := scanCacheRow(r.db.QueryRow(ctx, query, language, word))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return cache, err
+}
+
+// PurgeCache deletes the cache rows matching filter and returns the number
+// deleted. Words purged are fetched again when next looked up.
+func (r *Repository) PurgeCache(ctx context.Context, filter CacheFilter) (int64, error) {
+	where, args, err := filter.where()
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := r.db.Exec(ctx, `DELETE FROM dictionary_cache WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetCacheStats returns the size of the dictionary cache. Hit counts are
+// kept by the dictionary service rather than stored.
+func (r *Repository) GetCacheStats(ctx context.Context) (*models.CacheStats, error) {
+	query := `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE expires_at <= NOW()),
+			COALESCE(SUM(pg_column_size(data)), 0), pg_total_relation_size('dictionary_cache')
+		FROM dictionary_cache`
+
+	stats := models.CacheStats{Sources: make(map[string]int64), Versions: make(map[int]int64)}
+	if err := r.db.QueryRow(ctx, query).Scan(&stats.Entries, &stats.Expired, &stats.DataBytes, &stats.TableBytes); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT source, COUNT(*) FROM dictionary_cache GROUP BY source`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var source string
+		var n int64
+		if err := rows.Scan(&source, &n); err != nil {
+			return nil, err
+		}
+		stats.Sources[source] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(ctx, `SELECT schema_version, COUNT(*) FROM dictionary_cache GROUP BY schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var n int64
+		if err := rows.Scan(&version, &n); err != nil {
+			return nil, err
+		}
+		stats.Versions[version] = n
+	}
+
+	return &stats, rows.Err()
+}
+
+// GetOutdatedCacheRows returns up to limit cache rows, expired or not,
+// written in an older schema version than models.CacheSchemaVersion, in
+// order of language and word starting after the given key. Their upstream
+// payloads are decompressed.
+func (r *Repository) GetOutdatedCacheRows(ctx context.Context, after WordKey, limit int) ([]models.DictionaryCache, error) {
+	query := `
+		SELECT language, word, data, schema_version, raw, source, fetched_at, expires_at
+		FROM dictionary_cache
+		WHERE schema_version < $1 AND (language, word) > ($2, $3)
+		ORDER BY language, word
+		LIMIT $4`
+
+	rows, err := r.db.Query(ctx, query, models.CacheSchemaVersion, after.Language, after.Word, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var caches []models.DictionaryCache
+	for rows.Next() {
+		cache, err := scanCacheRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, *cache)
+	}
+
+	return caches, rows.Err()
+}
+
+// GetRawCacheRows returns up to limit cache rows matching filter, expired or
+// not, that have an upstream payload, in order of language and word starting
+// after the given key. Their payloads are decompressed.
+func (r *Repository) GetRawCacheRows(ctx context.Context, filter CacheFilter, after WordKey, limit int) ([]models.DictionaryCache, error) {
+	where, args, err := filter.where()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT language, word, data, schema_version, raw, source, fetched_at, expires_at
+		FROM dictionary_cache
+		WHERE %s AND raw IS NOT NULL AND (language, word) > ($%d, $%d)
+		ORDER BY language, word
+		LIMIT $%d`, where, len(args)+1, len(args)+2, len(args)+3)
+
+	rows, err := r.db.Query(ctx, query, append(args, after.Language, after.Word, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var caches []models.DictionaryCache
+	for rows.Next() {
+		cache, err := scanCacheRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, *cache)
+	}
+
+	return caches, rows.Err()
+}
+
+// UpgradeCachedDictionary replaces the data of a cache row written in schema
+// version from with data in models.CacheSchemaVersion, keeping its expiry.
+// It returns false if the row is gone or was rewritten meanwhile.
+func (r *Repository) UpgradeCachedDictionary(ctx context.Context, language, word string, from int, data []byte) (bool, error) {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE dictionary_cache SET data = $4, schema_version = $5
+		WHERE language = $1 AND word = $2 AND schema_version = $3`
+
+	tag, err := r.db.Exec(ctx, query, language, word, from, data, models.CacheSchemaVersion)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// scanCacheRow scans a cache row selected with its raw payload, which it
+// decompresses.
+func scanCacheRow(row pgx.Row) (*models.DictionaryCache, error) {
+	var cache models.DictionaryCache
+	var raw []byte
+	if err := row.Scan(&cache.Language, &cache.Word, &cache.Data, &cache.SchemaVersion, &raw, &cache.Source, &cache.FetchedAt, &cache.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if cache.Raw, err = decompressPayload(raw); err != nil {
+		return nil, fmt.Errorf("invalid upstream payload of %q: %w", cache.Word, err)
+	}
+	return &cache, nil
+}
+
+// compressPayload gzips an upstream payload for storage, keeping nil as nil.
+func compressPayload(payload []byte) ([]byte, error) {
+	if payload == nil {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressPayload(compressed []byte) ([]byte, error) {
+	if compressed == nil {
+		return nil, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
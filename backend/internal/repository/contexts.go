@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+// querier is satisfied by both the pool and a transaction, so helpers can
+// run either standalone or as part of a larger unit of work.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+const wordContextColumns = `id, sentence, source_title, source_url, location, created_at`
+
+// Encounter context operations
+
+func addWordContext(ctx context.Context, q querier, entryID int64, wc *models.WordContext) error {
+	query := `
+		INSERT INTO wordbook_contexts (entry_id, sentence, source_title, source_url, location)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	return q.QueryRow(ctx, query, entryID, wc.Sentence, wc.SourceTitle, wc.SourceURL, wc.Location).
+		Scan(&wc.ID, &wc.CreatedAt)
+}
+
+func getWordContexts(ctx context.Context, q querier, entryID int64) ([]models.WordContext, error) {
+	query := `
+		SELECT ` + wordContextColumns + `
+		FROM wordbook_contexts
+		WHERE entry_id = $1
+		ORDER BY created_at, id`
+
+	rows, err := q.Query(ctx, query, entryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contexts []models.WordContext
+	for rows.Next() {
+		wc, err := scanWordContext(rows)
+		if err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, wc)
+	}
+
+	return contexts, rows.Err()
+}
+
+// attachWordContexts loads the contexts of all of a user's entries in one
+// query and assigns them to the matching entries.
+func (r *Repository) attachWordContexts(ctx context.Context, userID string, entries []models.WordbookEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT c.entry_id, c.id, c.sentence, c.source_title, c.source_url, c.location, c.created_at
+		FROM wordbook_contexts c
+		JOIN wordbook_entries w ON w.id = c.entry_id
+		WHERE w.user_id = $1
+		ORDER BY c.created_at, c.id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byEntry := make(map[int64][]models.WordContext)
+	for rows.Next() {
+		var entryID int64
+		var wc models.WordContext
+		if err := rows.Scan(&entryID, &wc.ID, &wc.Sentence, &wc.SourceTitle, &wc.SourceURL, &wc.Location, &wc.CreatedAt); err != nil {
+			return err
+		}
+		byEntry[entryID] = append(byEntry[entryID], wc)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range entries {
+		entries[i].Contexts = byEntry[entries[i].ID]
+	}
+	return nil
+}
+
+func scanWordContext(row pgx.Row) (models.WordContext, error) {
+	var wc models.WordContext
+	err := row.Scan(&wc.ID, &wc.Sentence, &wc.SourceTitle, &wc.SourceURL, &wc.Location, &wc.CreatedAt)
+	return wc, err
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+)
+
+const customEntryColumns = `language, word, entry, overrides, updated_by, created_at, updated_at`
+
+// Custom entry operations
+
+// GetCustomEntry returns the custom entry of word, or nil if it has none.
+func (r *Repository) GetCustomEntry(ctx context.Context, language, word string) (*models.CustomEntry, error) {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + customEntryColumns + ` FROM custom_entries WHERE language = $1 AND word = $2`
+	entry, err := scanCustomEntry(r.db.QueryRow(ctx, query, language, word))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return entry, err
+}
+
+// GetCustomEntries returns the custom entries of a language, or of every
+// language when it is empty, sorted by word.
+func (r *Repository) GetCustomEntries(ctx context.Context, language string) ([]models.CustomEntry, error) {
+	query := `SELECT ` + customEntryColumns + ` FROM custom_entries WHERE $1 = '' OR language = $1 ORDER BY word, language`
+	rows, err := r.db.Query(ctx, query, language)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.CustomEntry
+	for rows.Next() {
+		entry, err := scanCustomEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// SaveCustomEntry creates or replaces the custom entry of e.Word on behalf
+// of actor, recording the change in the audit trail. It fills in the
+// normalized word and timestamps.
+func (r *Repository) SaveCustomEntry(ctx context.Context, e *models.CustomEntry, actor string) error {
+	word, err := normalize.Word(e.Language, e.Word)
+	if err != nil {
+		return err
+	}
+	e.Word, e.UpdatedBy = word, actor
+
+	entry, overrides, err := marshalCustomEntry(e)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockCustomEntry(ctx, tx, e.Language, e.Word)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO custom_entries (language, word, entry, overrides, updated_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (language, word) DO UPDATE SET
+			entry = EXCLUDED.entry,
+			overrides = EXCLUDED.overrides,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()
+		RETURNING created_at, updated_at`
+	if err := tx.QueryRow(ctx, query, e.Language, e.Word, entry, overrides, actor).Scan(&e.CreatedAt, &e.UpdatedAt); err != nil {
+		return err
+	}
+
+	action := models.ChangeUpdated
+	if before == nil {
+		action = models.ChangeCreated
+	}
+	if err := recordCustomEntryChange(ctx, tx, e.Language, e.Word, action, actor, before, e); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteCustomEntry deletes the custom entry of word on behalf of actor,
+// recording the change in the audit trail. It returns ErrNotFound if the
+// word has none.
+func (r *Repository) DeleteCustomEntry(ctx context.Context, language, word, actor string) error {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockCustomEntry(ctx, tx, language, word)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM custom_entries WHERE language = $1 AND word = $2`, language, word); err != nil {
+		return err
+	}
+	if err := recordCustomEntryChange(ctx, tx, language, word, models.ChangeDeleted, actor, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetCustomEntryChanges returns up to limit changes to custom entries,
+// newest first: those of one word, or all of them when word is empty.
+func (r *Repository) GetCustomEntryChanges(ctx context.Context, language, word string, limit int) ([]models.CustomEntryChange, error) {
+	if word != "" {
+		var err error
+		if word, err = normalize.Word(language, word); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		SELECT id, language, word, action, actor, before, after, changed_at
+		FROM custom_entry_changes
+		WHERE $2 = '' OR (language = $1 AND word = $2)
+		ORDER BY changed_at DESC, id DESC
+		LIMIT $3`
+
+	rows, err := r.db.Query(ctx, query, language, word, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.CustomEntryChange
+	for rows.Next() {
+		var change models.CustomEntryChange
+		var before, after []byte
+		if err := rows.Scan(&change.ID, &change.Language, &change.Word, &change.Action, &change.Actor, &before, &after, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		if before != nil {
+			if err := json.Unmarshal(before, &change.Before); err != nil {
+				return nil, fmt.Errorf("invalid audit record %d: %w", change.ID, err)
+			}
+		}
+		if after != nil {
+			if err := json.Unmarshal(after, &change.After); err != nil {
+				return nil, fmt.Errorf("invalid audit record %d: %w", change.ID, err)
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+// lockCustomEntry returns the custom entry of a normalized word, locked for
+// the rest of the transaction, or nil if it has none.
+func lockCustomEntry(ctx context.Context, tx pgx.Tx, language, word string) (*models.CustomEntry, error) {
+	query := `SELECT ` + customEntryColumns + ` FROM custom_entries WHERE language = $1 AND word = $2 FOR UPDATE`
+	entry, err := scanCustomEntry(tx.QueryRow(ctx, query, language, word))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return entry, err
+}
+
+func recordCustomEntryChange(ctx context.Context, tx pgx.Tx, language, word, action, actor string, before, after *models.CustomEntry) error {
+	var beforeData, afterData []byte
+	var err error
+	if before != nil {
+		if beforeData, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if afterData, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO custom_entry_changes (language, word, action, actor, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = tx.Exec(ctx, query, language, word, action, actor, beforeData, afterData)
+	return err
+}
+
+func marshalCustomEntry(e *models.CustomEntry) (entry, overrides []byte, err error) {
+	if e.Entry != nil {
+		if entry, err = json.Marshal(e.Entry); err != nil {
+			return nil, nil, err
+		}
+	}
+	if e.Overrides == nil {
+		e.Overrides = []models.EntryOverride{}
+	}
+	if overrides, err = json.Marshal(e.Overrides); err != nil {
+		return nil, nil, err
+	}
+	return entry, overrides, nil
+}
+
+func scanCustomEntry(row pgx.Row) (*models.CustomEntry, error) {
+	var e models.CustomEntry
+	var entry, overrides []byte
+	if err := row.Scan(&e.Language, &e.Word, &entry, &overrides, &e.UpdatedBy, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		if err := json.Unmarshal(entry, &e.Entry); err != nil {
+			return nil, fmt.Errorf("invalid custom entry for %q: %w", e.Word, err)
+		}
+	}
+	if err := json.Unmarshal(overrides, &e.Overrides); err != nil {
+		return nil, fmt.Errorf("invalid overrides for %q: %w", e.Word, err)
+	}
+	return &e, nil
+}
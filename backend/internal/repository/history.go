@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+// Lookup history operations
+
+func (r *Repository) AddLookupEvent(ctx context.Context, userID, language, word string, found bool) error {
+	query := `INSERT INTO lookup_history (user_id, language, word, found) VALUES ($1, $2, $3, $4)`
+	_, err := r.db.Exec(ctx, query, userID, language, word, found)
+	return err
+}
+
+// GetLookupHistory returns a page of lookup events, newest first, together
+// with the total number of events recorded for the user.
+func (r *Repository) GetLookupHistory(ctx context.Context, userID string, limit, offset int) ([]models.LookupEvent, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM lookup_history WHERE user_id = $1`
+	if err := r.db.QueryRow(ctx, countQuery, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, user_id, language, word, found, looked_up_at
+		FROM lookup_history
+		WHERE user_id = $1
+		ORDER BY looked_up_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []models.LookupEvent
+	for rows.Next() {
+		var event models.LookupEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Language, &event.Word, &event.Found, &event.LookedUpAt); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+
+	return events, total, rows.Err()
+}
+
+func (r *Repository) ClearLookupHistory(ctx context.Context, userID string) error {
+	query := `DELETE FROM lookup_history WHERE user_id = $1`
+	_, err := r.db.Exec(ctx, query, userID)
+	return err
+}
+
+// GetLookupSuggestions returns words the user found in the dictionary at
+// least minCount times but never saved, most frequently looked up first.
+func (r *Repository) GetLookupSuggestions(ctx context.Context, userID string, minCount, limit int) ([]models.LookupSuggestion, error) {
+	query := `
+		SELECT h.language, h.word, COUNT(*) AS lookups, MAX(h.looked_up_at) AS last_lookup
+		FROM lookup_history h
+		WHERE h.user_id = $1 AND h.found
+			AND NOT EXISTS (
+				SELECT 1 FROM wordbook_entries w
+				WHERE w.user_id = h.user_id AND w.language = h.language AND w.word = h.word
+			)
+		GROUP BY h.language, h.word
+		HAVING COUNT(*) >= $2
+		ORDER BY lookups DESC, last_lookup DESC
+		LIMIT $3`
+
+	rows, err := r.db.Query(ctx, query, userID, minCount, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []models.LookupSuggestion
+	for rows.Next() {
+		var s models.LookupSuggestion
+		if err := rows.Scan(&s.Language, &s.Word, &s.LookupCount, &s.LastLookedUpAt); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	return suggestions, rows.Err()
+}
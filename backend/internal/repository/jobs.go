@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+// jobLockNamespace is the first key of the advisory locks taken by
+// RunExclusive, keeping them apart from any other advisory locks in the
+// database
+const jobLockNamespace = 0x766f63 // "voc"
+
+const jobRunColumns = `id, job, started_at, finished_at, result, error`
+
+// Scheduled job operations
+
+// RunExclusive runs fn while holding a session-level Postgres advisory lock
+// named by key, so that processes sharing the database never run it at the
+// same time. It returns false without running fn when another session holds
+// the lock.
+func (r *Repository) RunExclusive(ctx context.Context, key string, fn func(context.Context) error) (bool, error) {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1, hashtext($2))`, jobLockNamespace, key).Scan(&locked); err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		// The lock belongs to the session, so a connection that can't
+		// release it must not go back to the pool holding it
+		unlockCtx := context.WithoutCancel(ctx)
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1, hashtext($2))`, jobLockNamespace, key); err != nil {
+			conn.Conn().Close(unlockCtx)
+		}
+	}()
+
+	return true, fn(ctx)
+}
+
+// StartJobRun records the start of a run of job.
+func (r *Repository) StartJobRun(ctx context.Context, job string) (*models.JobRun, error) {
+	query := `INSERT INTO job_runs (job) VALUES ($1) RETURNING ` + jobRunColumns
+	return scanJobRun(r.db.QueryRow(ctx, query, job))
+}
+
+// FinishJobRun records the outcome of a run started by StartJobRun.
+func (r *Repository) FinishJobRun(ctx context.Context, run *models.JobRun, result string, runErr error) error {
+	run.Result = result
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+
+	query := `
+		UPDATE job_runs SET finished_at = NOW(), result = $2, error = $3
+		WHERE id = $1
+		RETURNING finished_at`
+	return r.db.QueryRow(ctx, query, run.ID, run.Result, run.Error).Scan(&run.FinishedAt)
+}
+
+// GetLastJobRun returns the most recently started run of job, or nil if it
+// never ran.
+func (r *Repository) GetLastJobRun(ctx context.Context, job string) (*models.JobRun, error) {
+	query := `SELECT ` + jobRunColumns + ` FROM job_runs WHERE job = $1 ORDER BY started_at DESC, id DESC LIMIT 1`
+	run, err := scanJobRun(r.db.QueryRow(ctx, query, job))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+// GetLastFailedJobRun returns the most recent run of job that failed, or nil
+// if none did.
+func (r *Repository) GetLastFailedJobRun(ctx context.Context, job string) (*models.JobRun, error) {
+	query := `SELECT ` + jobRunColumns + ` FROM job_runs WHERE job = $1 AND error <> '' ORDER BY started_at DESC, id DESC LIMIT 1`
+	run, err := scanJobRun(r.db.QueryRow(ctx, query, job))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+// GetJobRuns returns up to limit runs of job, newest first.
+func (r *Repository) GetJobRuns(ctx context.Context, job string, limit int) ([]models.JobRun, error) {
+	query := `SELECT ` + jobRunColumns + ` FROM job_runs WHERE job = $1 ORDER BY started_at DESC, id DESC LIMIT $2`
+	rows, err := r.db.Query(ctx, query, job, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.JobRun
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+
+	return runs, rows.Err()
+}
+
+// PruneJobRuns deletes the history of runs started before the given
+// instant and returns the number of runs deleted.
+func (r *Repository) PruneJobRuns(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM job_runs WHERE started_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanJobRun(row pgx.Row) (*models.JobRun, error) {
+	var run models.JobRun
+	if err := row.Scan(&run.ID, &run.Job, &run.StartedAt, &run.FinishedAt, &run.Result, &run.Error); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+)
+
+// Word key migration
+
+// keyedRow is a stored word as seen by NormalizeWordKeys
+type keyedRow struct {
+	id          int64
+	userID      string
+	language    string
+	word        string
+	key         string
+	repetitions int
+	at          time.Time
+}
+
+// NormalizeWordKeys rewrites every stored word to its normalize.Word form,
+// de-duplicating rows that collide once normalized. Of colliding wordbook
+// entries the one with the most reviews, then the oldest, is kept, and the
+// others' contexts move to it; of colliding cache rows the most recently
+// fetched is kept. Words too malformed to normalize are left alone. Keys
+// follow normalize.Word, which keeps "ß"; words stored when it was folded to
+// "ss" can't be told from words spelled with "ss" and keep that spelling. It
+// returns the number of rows removed and is cheap to run again.
+func (r *Repository) NormalizeWordKeys(ctx context.Context) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	removed, err := normalizeWordbookKeys(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	n, err := normalizeCacheKeys(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return removed + n, nil
+}
+
+func normalizeWordbookKeys(ctx context.Context, tx pgx.Tx) (int, error) {
+	rows, err := tx.Query(ctx, `SELECT id, user_id, language, word, repetitions, created_at FROM wordbook_entries ORDER BY id`)
+	if err != nil {
+		return 0, err
+	}
+	stored, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (keyedRow, error) {
+		var k keyedRow
+		err := row.Scan(&k.id, &k.userID, &k.language, &k.word, &k.repetitions, &k.at)
+		return k, err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, group := range groupByKey(stored, func(k keyedRow) string { return k.userID + "\x00" + k.language }) {
+		keep := group[0]
+		for _, k := range group[1:] {
+			if k.repetitions > keep.repetitions || (k.repetitions == keep.repetitions && k.at.Before(keep.at)) {
+				keep = k
+			}
+		}
+
+		for _, k := range group {
+			if k.word != k.key {
+				// Review logs name the word rather than referencing the entry
+				if _, err := tx.Exec(ctx, `UPDATE review_logs SET word = $4 WHERE user_id = $1 AND language = $2 AND word = $3`,
+					k.userID, k.language, k.word, k.key); err != nil {
+					return 0, err
+				}
+			}
+			if k.id == keep.id {
+				continue
+			}
+			if _, err := tx.Exec(ctx, `UPDATE wordbook_contexts SET entry_id = $1 WHERE entry_id = $2`, keep.id, k.id); err != nil {
+				return 0, err
+			}
+			if _, err := tx.Exec(ctx, `DELETE FROM wordbook_entries WHERE id = $1`, k.id); err != nil {
+				return 0, err
+			}
+			removed++
+		}
+
+		if keep.word != keep.key {
+			if _, err := tx.Exec(ctx, `UPDATE wordbook_entries SET word = $1 WHERE id = $2`, keep.key, keep.id); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+func normalizeCacheKeys(ctx context.Context, tx pgx.Tx) (int, error) {
+	rows, err := tx.Query(ctx, `SELECT language, word, fetched_at FROM dictionary_cache`)
+	if err != nil {
+		return 0, err
+	}
+	stored, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (keyedRow, error) {
+		var k keyedRow
+		err := row.Scan(&k.language, &k.word, &k.at)
+		return k, err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, group := range groupByKey(stored, func(k keyedRow) string { return k.language }) {
+		keep := group[0]
+		for _, k := range group[1:] {
+			if k.at.After(keep.at) {
+				keep = k
+			}
+		}
+
+		for _, k := range group {
+			if k.word == keep.word {
+				continue
+			}
+			if _, err := tx.Exec(ctx, `DELETE FROM dictionary_cache WHERE language = $1 AND word = $2`, k.language, k.word); err != nil {
+				return 0, err
+			}
+			removed++
+		}
+
+		if keep.word != keep.key {
+			if _, err := tx.Exec(ctx, `UPDATE dictionary_cache SET word = $3 WHERE language = $1 AND word = $2`,
+				keep.language, keep.word, keep.key); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// groupByKey normalizes each row's word and groups the rows by scope and
+// normalized word, returning only the groups that need rewriting: those
+// with a collision or a word not yet in normalized form.
+func groupByKey(stored []keyedRow, scope func(keyedRow) string) [][]keyedRow {
+	index := make(map[string]int)
+	var groups [][]keyedRow
+	for _, k := range stored {
+		var err error
+		if k.key, err = normalize.Word(k.language, k.word); err != nil {
+			continue
+		}
+		id := scope(k) + "\x00" + k.key
+		i, ok := index[id]
+		if !ok {
+			i = len(groups)
+			index[id] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], k)
+	}
+
+	var changed [][]keyedRow
+	for _, group := range groups {
+		if len(group) > 1 || group[0].word != group[0].key {
+			changed = append(changed, group)
+		}
+	}
+	return changed
+}
@@ -2,13 +2,26 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
 )
 
+// ErrNotFound is returned when a requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// wordbookColumns lists the columns scanned by scanWordbookEntry, in order
+const wordbookColumns = `id, user_id, language, word, short_definition, created_at,
+	ease, interval_days, repetitions, due_at, selected_meaning, selected_definition,
+	notes, tags, COALESCE(frequency_rank, 0), COALESCE(zipf, 0), cefr_level, selected_homograph`
+
 type Repository struct {
 	db *pgxpool.Pool
 }
@@ -19,14 +32,89 @@ func New(db *pgxpool.Pool) *Repository {
 
 // Wordbook operations
 
-func (r *Repository) GetWordbookEntries(ctx context.Context, userID string) ([]models.WordbookEntry, error) {
+// Wordbook sort orders accepted by WordbookFilter
+const (
+	WordbookSortCreated   = "created_at"
+	WordbookSortWord      = "word"
+	WordbookSortFrequency = "frequency"
+	WordbookSortZipf      = "zipf"
+	WordbookSortCEFR      = "cefr"
+)
+
+// wordbookSortColumns maps each sort order to its ORDER BY expression and
+// whether it runs descending by default. Words missing from the frequency
+// or CEFR lists sort last either way.
+var wordbookSortColumns = map[string]struct {
+	expr string
+	desc bool
+}{
+	WordbookSortCreated:   {"created_at", true},
+	WordbookSortWord:      {"word", false},
+	WordbookSortFrequency: {"NULLIF(frequency_rank, 0)", false},
+	WordbookSortZipf:      {"NULLIF(zipf, 0)", true},
+	WordbookSortCEFR:      {"NULLIF(cefr_level, '')", false},
+}
+
+// ValidWordbookSort reports whether sort is a known wordbook sort order
+func ValidWordbookSort(sort string) bool {
+	_, ok := wordbookSortColumns[sort]
+	return ok
+}
+
+// WordbookFilter narrows and orders a wordbook listing. The zero value lists
+// every entry, newest first.
+type WordbookFilter struct {
+	Language   string
+	CEFRLevels []string
+	MinZipf    *float64
+	MaxZipf    *float64
+	// MaxRank keeps words ranked 1 through MaxRank in the frequency list
+	MaxRank int
+	// Sort is one of the WordbookSort orders and Order is "asc" or "desc";
+	// an empty Order keeps the sort's default direction
+	Sort  string
+	Order string
+}
+
+func (r *Repository) GetWordbookEntries(ctx context.Context, userID string, filter WordbookFilter) ([]models.WordbookEntry, error) {
+	conditions := []string{"user_id = $1"}
+	args := []any{userID}
+	addCondition := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Language != "" {
+		addCondition("language = $%d", filter.Language)
+	}
+	if len(filter.CEFRLevels) > 0 {
+		addCondition("cefr_level = ANY($%d)", filter.CEFRLevels)
+	}
+	if filter.MinZipf != nil {
+		addCondition("zipf >= $%d", *filter.MinZipf)
+	}
+	if filter.MaxZipf != nil {
+		addCondition("COALESCE(zipf, 0) <= $%d", *filter.MaxZipf)
+	}
+	if filter.MaxRank > 0 {
+		addCondition("frequency_rank BETWEEN 1 AND $%d", filter.MaxRank)
+	}
+
+	sort, ok := wordbookSortColumns[filter.Sort]
+	if !ok {
+		sort = wordbookSortColumns[WordbookSortCreated]
+	}
+	direction := "ASC"
+	if filter.Order == "desc" || (filter.Order == "" && sort.desc) {
+		direction = "DESC"
+	}
+
 	query := `
-		SELECT id, user_id, word, short_definition, created_at
+		SELECT ` + wordbookColumns + `
 		FROM wordbook_entries
-		WHERE user_id = $1
-		ORDER BY created_at DESC`
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + sort.expr + ` ` + direction + ` NULLS LAST, id DESC`
 
-	rows, err := r.db.Query(ctx, query, userID)
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -34,58 +122,313 @@ func (r *Repository) GetWordbookEntries(ctx context.Context, userID string) ([]m
 
 	var entries []models.WordbookEntry
 	for rows.Next() {
-		var entry models.WordbookEntry
-		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Word, &entry.ShortDefinition, &entry.CreatedAt); err != nil {
+		entry, err := scanWordbookEntry(rows)
+		if err != nil {
 			return nil, err
 		}
-		entries = append(entries, entry)
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.attachWordContexts(ctx, userID, entries); err != nil {
+		return nil, err
 	}
+	return entries, nil
+}
 
-	return entries, rows.Err()
+// WordbookEntryInput describes a wordbook entry to create or update.
+// All fields but Language, Word and ShortDefinition are optional.
+type WordbookEntryInput struct {
+	Language          string
+	Word              string
+	ShortDefinition   string
+	Snapshot          *models.DictionaryEntry
+	SelectedSense     *models.SenseRef
+	SelectedHomograph *int
+	Context           *models.WordContext
+	Notes             *string
+	Tags              []string
+	Levels            models.WordLevels
 }
 
-func (r *Repository) AddWordbookEntry(ctx context.Context, userID, word, shortDef string) (*models.WordbookEntry, error) {
+func (r *Repository) AddWordbookEntry(ctx context.Context, userID, language, word, shortDef string) (*models.WordbookEntry, error) {
+	return r.SaveWordbookEntry(ctx, userID, WordbookEntryInput{Language: language, Word: word, ShortDefinition: shortDef})
+}
+
+// SaveWordbookEntry upserts a wordbook entry under the normalized form of
+// its word. A nil snapshot, sense, notes or tags keeps whatever is already
+// stored for the word, and a context is always appended rather than
+// replacing earlier ones. A new snapshot clears the sense and homograph
+// chosen in the old one unless others are given.
+func (r *Repository) SaveWordbookEntry(ctx context.Context, userID string, in WordbookEntryInput) (*models.WordbookEntry, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	entry, err := saveWordbookEntry(ctx, tx, userID, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func saveWordbookEntry(ctx context.Context, tx pgx.Tx, userID string, in WordbookEntryInput) (*models.WordbookEntry, error) {
+	word, err := normalize.Word(in.Language, in.Word)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if in.Snapshot != nil {
+		if data, err = json.Marshal(in.Snapshot); err != nil {
+			return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+		}
+	}
+
+	var meaning, definition *int
+	if in.SelectedSense != nil {
+		meaning, definition = &in.SelectedSense.MeaningIndex, &in.SelectedSense.DefinitionIndex
+	}
+
 	query := `
-		INSERT INTO wordbook_entries (user_id, word, short_definition)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (user_id, word) DO UPDATE SET short_definition = EXCLUDED.short_definition
-		RETURNING id, user_id, word, short_definition, created_at`
+		INSERT INTO wordbook_entries (user_id, word, short_definition, snapshot, selected_meaning, selected_definition, notes, tags,
+			frequency_rank, zipf, cefr_level, language, selected_homograph)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, ''), COALESCE($8, '{}'::text[]), $9, $10, $11, $12, $13)
+		ON CONFLICT (user_id, language, word) DO UPDATE SET
+			short_definition = EXCLUDED.short_definition,
+			frequency_rank = EXCLUDED.frequency_rank,
+			zipf = EXCLUDED.zipf,
+			cefr_level = EXCLUDED.cefr_level,
+			snapshot = COALESCE($4, wordbook_entries.snapshot),
+			-- A new snapshot may number its senses and homographs differently
+			selected_meaning = CASE WHEN $4::jsonb IS NULL THEN COALESCE($5, wordbook_entries.selected_meaning) ELSE $5 END,
+			selected_definition = CASE WHEN $4::jsonb IS NULL THEN COALESCE($6, wordbook_entries.selected_definition) ELSE $6 END,
+			notes = COALESCE($7, wordbook_entries.notes),
+			tags = COALESCE($8, wordbook_entries.tags),
+			selected_homograph = CASE WHEN $4::jsonb IS NULL THEN COALESCE($13, wordbook_entries.selected_homograph) ELSE $13 END
+		RETURNING ` + wordbookColumns
 
-	var entry models.WordbookEntry
-	err := r.db.QueryRow(ctx, query, userID, word, shortDef).Scan(
-		&entry.ID, &entry.UserID, &entry.Word, &entry.ShortDefinition, &entry.CreatedAt,
-	)
+	entry, err := scanWordbookEntry(tx.QueryRow(ctx, query, userID, word, in.ShortDefinition, data, meaning, definition, in.Notes, in.Tags,
+		in.Levels.FrequencyRank, in.Levels.Zipf, in.Levels.CEFRLevel, in.Language, in.SelectedHomograph))
 	if err != nil {
 		return nil, err
 	}
+	entry.Snapshot = in.Snapshot
 
-	return &entry, nil
+	if in.Context != nil {
+		if err := addWordContext(ctx, tx, entry.ID, in.Context); err != nil {
+			return nil, err
+		}
+	}
+	if entry.Contexts, err = getWordContexts(ctx, tx, entry.ID); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
 }
 
-func (r *Repository) DeleteWordbookEntry(ctx context.Context, userID, word string) error {
-	query := `DELETE FROM wordbook_entries WHERE user_id = $1 AND word = $2`
-	_, err := r.db.Exec(ctx, query, userID, word)
+func (r *Repository) GetWordbookEntry(ctx context.Context, userID, language, word string) (*models.WordbookEntry, error) {
+	return getWordbookEntry(ctx, r.db, userID, language, word, false)
+}
+
+// getWordbookEntry loads a single entry with its snapshot and contexts. With
+// forUpdate set, the row stays locked until the surrounding transaction ends.
+func getWordbookEntry(ctx context.Context, q querier, userID, language, word string, forUpdate bool) (*models.WordbookEntry, error) {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + wordbookColumns + `, snapshot
+		FROM wordbook_entries
+		WHERE user_id = $1 AND language = $2 AND word = $3`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	var snapshot []byte
+	entry, err := scanWordbookEntry(q.QueryRow(ctx, query, userID, language, word), &snapshot)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshot != nil {
+		entry.Snapshot = &models.DictionaryEntry{}
+		if err := json.Unmarshal(snapshot, entry.Snapshot); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+		}
+	}
+
+	if entry.Contexts, err = getWordContexts(ctx, q, entry.ID); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *Repository) DeleteWordbookEntry(ctx context.Context, userID, language, word string) error {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM wordbook_entries WHERE user_id = $1 AND language = $2 AND word = $3`
+	_, err = r.db.Exec(ctx, query, userID, language, word)
 	return err
 }
 
-func (r *Repository) WordExistsInWordbook(ctx context.Context, userID, word string) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM wordbook_entries WHERE user_id = $1 AND word = $2)`
+func (r *Repository) WordExistsInWordbook(ctx context.Context, userID, language, word string) (bool, error) {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return false, err
+	}
+
+	query := `SELECT EXISTS(SELECT 1 FROM wordbook_entries WHERE user_id = $1 AND language = $2 AND word = $3)`
 	var exists bool
-	err := r.db.QueryRow(ctx, query, userID, word).Scan(&exists)
+	err = r.db.QueryRow(ctx, query, userID, language, word).Scan(&exists)
 	return exists, err
 }
 
+// GetWordbookWords returns every word in a user's wordbook.
+func (r *Repository) GetWordbookWords(ctx context.Context, userID string) ([]string, error) {
+	rows, err := r.db.Query(ctx, `SELECT word FROM wordbook_entries WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var words []string
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+
+	return words, rows.Err()
+}
+
+// WordKey identifies a word of a language
+type WordKey struct {
+	Language string
+	Word     string
+}
+
+// GetUnleveledWordbookWords returns the distinct wordbook words, across all
+// users, whose word levels haven't been recorded yet.
+func (r *Repository) GetUnleveledWordbookWords(ctx context.Context) ([]WordKey, error) {
+	rows, err := r.db.Query(ctx, `SELECT DISTINCT language, word FROM wordbook_entries WHERE frequency_rank IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []WordKey
+	for rows.Next() {
+		var key WordKey
+		if err := rows.Scan(&key.Language, &key.Word); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// SetWordbookLevels records the word levels of word in every user's wordbook.
+func (r *Repository) SetWordbookLevels(ctx context.Context, language, word string, levels models.WordLevels) error {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE wordbook_entries
+		SET frequency_rank = $3, zipf = $4, cefr_level = $5
+		WHERE language = $1 AND word = $2`
+	_, err = r.db.Exec(ctx, query, language, word, levels.FrequencyRank, levels.Zipf, levels.CEFRLevel)
+	return err
+}
+
+// SearchWordbookWords returns up to limit words in language of a user's
+// wordbook that start with prefix, shortest first.
+func (r *Repository) SearchWordbookWords(ctx context.Context, userID, language, prefix string, limit int) ([]string, error) {
+	query := `
+		SELECT word FROM wordbook_entries
+		WHERE user_id = $1 AND language = $2 AND word LIKE $3 ESCAPE '\'
+		ORDER BY length(word), word
+		LIMIT $4`
+
+	pattern := likeEscaper.Replace(prefix) + "%"
+	rows, err := r.db.Query(ctx, query, userID, language, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var words []string
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+
+	return words, rows.Err()
+}
+
+// likeEscaper escapes the LIKE wildcards of a literal pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// scanWordbookEntry scans the wordbookColumns of a row, followed by any
+// extra destinations for columns the caller appended to the select list.
+func scanWordbookEntry(row pgx.Row, extra ...any) (*models.WordbookEntry, error) {
+	var entry models.WordbookEntry
+	var meaning, definition *int
+	dest := []any{
+		&entry.ID, &entry.UserID, &entry.Language, &entry.Word, &entry.ShortDefinition, &entry.CreatedAt,
+		&entry.Ease, &entry.IntervalDays, &entry.Repetitions, &entry.DueAt, &meaning, &definition,
+		&entry.Notes, &entry.Tags, &entry.FrequencyRank, &entry.Zipf, &entry.CEFRLevel, &entry.SelectedHomograph,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	if meaning != nil && definition != nil {
+		entry.SelectedSense = &models.SenseRef{MeaningIndex: *meaning, DefinitionIndex: *definition}
+	}
+	return &entry, nil
+}
+
 // Cache operations
 
-func (r *Repository) GetCachedDictionary(ctx context.Context, word string) (*models.DictionaryCache, error) {
+func (r *Repository) GetCachedDictionary(ctx context.Context, language, word string) (*models.DictionaryCache, error) {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT word, data, source, fetched_at, expires_at
+		SELECT language, word, data, schema_version, source, fetched_at, expires_at
 		FROM dictionary_cache
-		WHERE word = $1 AND expires_at > NOW()`
+		WHERE language = $1 AND word = $2 AND expires_at > NOW()`
 
 	var cache models.DictionaryCache
-	err := r.db.QueryRow(ctx, query, word).Scan(
-		&cache.Word, &cache.Data, &cache.Source, &cache.FetchedAt, &cache.ExpiresAt,
+	err = r.db.QueryRow(ctx, query, language, word).Scan(
+		&cache.Language, &cache.Word, &cache.Data, &cache.SchemaVersion, &cache.Source, &cache.FetchedAt, &cache.ExpiresAt,
 	)
 	if err == pgx.ErrNoRows {
 		return nil, nil
@@ -97,22 +440,98 @@ func (r *Repository) GetCachedDictionary(ctx context.Context, word string) (*mod
 	return &cache, nil
 }
 
-func (r *Repository) SetCachedDictionary(ctx context.Context, word string, data []byte, source string, ttl time.Duration) error {
+// SetCachedDictionary caches the entry of word, together with the upstream
+// payload it was normalized from, if any, which is stored compressed.
+func (r *Repository) SetCachedDictionary(ctx context.Context, language, word string, data, raw []byte, source string, ttl time.Duration) error {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return err
+	}
+	compressed, err := compressPayload(raw)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO dictionary_cache (word, data, source, fetched_at, expires_at)
-		VALUES ($1, $2, $3, NOW(), NOW() + $4::interval)
-		ON CONFLICT (word) DO UPDATE SET
+		INSERT INTO dictionary_cache (language, word, data, schema_version, raw, source, fetched_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW() + $7::interval)
+		ON CONFLICT (language, word) DO UPDATE SET
 			data = EXCLUDED.data,
+			schema_version = EXCLUDED.schema_version,
+			raw = EXCLUDED.raw,
 			source = EXCLUDED.source,
 			fetched_at = EXCLUDED.fetched_at,
 			expires_at = EXCLUDED.expires_at`
 
-	_, err := r.db.Exec(ctx, query, word, data, source, ttl.String())
+	_, err = r.db.Exec(ctx, query, language, word, data, models.CacheSchemaVersion, compressed, source, ttl.String())
 	return err
 }
 
-func (r *Repository) CleanExpiredCache(ctx context.Context) error {
+// GetCachedWords returns every word of a language in the dictionary cache,
+// expired or not.
+func (r *Repository) GetCachedWords(ctx context.Context, language string) ([]string, error) {
+	rows, err := r.db.Query(ctx, `SELECT word FROM dictionary_cache WHERE language = $1`, language)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var words []string
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+
+	return words, rows.Err()
+}
+
+// CleanExpiredCache deletes expired dictionary entries and returns the
+// number deleted.
+func (r *Repository) CleanExpiredCache(ctx context.Context) (int64, error) {
 	query := `DELETE FROM dictionary_cache WHERE expires_at < NOW()`
-	_, err := r.db.Exec(ctx, query)
-	return err
+	tag, err := r.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetExpiringPopularWords returns up to limit cached words expiring within
+// the given duration that were looked up or saved to wordbooks at least
+// minUses times in total, most used first. Lookups count over the last
+// lookupWindow.
+func (r *Repository) GetExpiringPopularWords(ctx context.Context, within, lookupWindow time.Duration, minUses, limit int) ([]WordKey, error) {
+	query := `
+		SELECT c.language, c.word
+		FROM dictionary_cache c
+		CROSS JOIN LATERAL (
+			SELECT
+				(SELECT COUNT(*) FROM lookup_history h
+					WHERE h.language = c.language AND h.word = c.word AND h.found AND h.looked_up_at > NOW() - $2::interval)
+				+ (SELECT COUNT(*) FROM wordbook_entries w
+					WHERE w.language = c.language AND w.word = c.word) AS uses
+		) u
+		WHERE c.expires_at < NOW() + $1::interval AND u.uses >= $3
+		ORDER BY u.uses DESC, c.expires_at
+		LIMIT $4`
+
+	rows, err := r.db.Query(ctx, query, within.String(), lookupWindow.String(), minUses, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []WordKey
+	for rows.Next() {
+		var key WordKey
+		if err := rows.Scan(&key.Language, &key.Word); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
 }
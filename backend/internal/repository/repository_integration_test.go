@@ -3,7 +3,11 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +15,7 @@ import (
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/warriorguo/vocabulary/internal/models"
 )
 
 func setupTestDB(t *testing.T) (*pgxpool.Pool, func()) {
@@ -57,6 +62,141 @@ func setupTestDB(t *testing.T) (*pgxpool.Pool, func()) {
 			fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
 			expires_at TIMESTAMPTZ NOT NULL
 		)`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS ease DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+			ADD COLUMN IF NOT EXISTS interval_days INTEGER NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS repetitions INTEGER NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ NOT NULL DEFAULT NOW()`,
+		`CREATE TABLE IF NOT EXISTS review_logs (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(64) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			grade SMALLINT NOT NULL,
+			interval_days INTEGER NOT NULL,
+			reviewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_settings (
+			user_id VARCHAR(64) PRIMARY KEY,
+			time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS lookup_history (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(64) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			found BOOLEAN NOT NULL,
+			looked_up_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_lookup_history_user_time ON lookup_history(user_id, looked_up_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_lookup_history_user_word ON lookup_history(user_id, word)`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS snapshot JSONB,
+			ADD COLUMN IF NOT EXISTS selected_meaning INTEGER,
+			ADD COLUMN IF NOT EXISTS selected_definition INTEGER`,
+		`CREATE TABLE IF NOT EXISTS wordbook_contexts (
+			id BIGSERIAL PRIMARY KEY,
+			entry_id BIGINT NOT NULL REFERENCES wordbook_entries(id) ON DELETE CASCADE,
+			sentence TEXT NOT NULL,
+			source_title TEXT NOT NULL DEFAULT '',
+			source_url TEXT NOT NULL DEFAULT '',
+			location VARCHAR(64) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_wordbook_contexts_entry ON wordbook_contexts(entry_id)`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS frequency_rank INTEGER,
+			ADD COLUMN IF NOT EXISTS zipf DOUBLE PRECISION,
+			ADD COLUMN IF NOT EXISTS cefr_level VARCHAR(2) NOT NULL DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS idx_wordbook_entries_cefr ON wordbook_entries(user_id, cefr_level)`,
+		`ALTER TABLE user_settings
+			ADD COLUMN IF NOT EXISTS translation_language VARCHAR(16) NOT NULL DEFAULT ''`,
+		`ALTER TABLE wordbook_entries
+			ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en'`,
+		`ALTER TABLE wordbook_entries DROP CONSTRAINT IF EXISTS wordbook_entries_user_id_word_key`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_wordbook_user_language_word ON wordbook_entries(user_id, language, word)`,
+		`ALTER TABLE dictionary_cache
+			ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en'`,
+		`ALTER TABLE dictionary_cache DROP CONSTRAINT IF EXISTS dictionary_cache_pkey`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_cache_language_word ON dictionary_cache(language, word)`,
+		`ALTER TABLE review_logs
+			ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en'`,
+		`ALTER TABLE lookup_history
+			ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en'`,
+		`DROP INDEX IF EXISTS idx_lookup_history_user_word`,
+		`CREATE INDEX IF NOT EXISTS idx_lookup_history_user_language_word ON lookup_history(user_id, language, word)`,
+		`CREATE TABLE IF NOT EXISTS audio_files (
+			language VARCHAR(16) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			accent VARCHAR(8) NOT NULL DEFAULT '',
+			source_url TEXT NOT NULL,
+			content_type VARCHAR(128) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			size BIGINT NOT NULL,
+			fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (language, word, accent)
+		)`,
+		`ALTER TABLE audio_files
+			ADD COLUMN IF NOT EXISTS synthetic BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE TABLE IF NOT EXISTS job_runs (
+			id BIGSERIAL PRIMARY KEY,
+			job VARCHAR(64) NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			finished_at TIMESTAMPTZ,
+			result TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_job_runs_job_started ON job_runs(job, started_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS review_reminders (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(64) NOT NULL,
+			day DATE NOT NULL,
+			due_count INTEGER NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			dismissed_at TIMESTAMPTZ,
+			UNIQUE(user_id, day)
+		)`,
+		`CREATE TABLE IF NOT EXISTS cache_warm_runs (
+			id BIGSERIAL PRIMARY KEY,
+			language VARCHAR(16) NOT NULL,
+			words TEXT[] NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			fetched INTEGER NOT NULL DEFAULT 0,
+			cached INTEGER NOT NULL DEFAULT 0,
+			not_found TEXT[] NOT NULL DEFAULT '{}',
+			failed INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			status VARCHAR(16) NOT NULL DEFAULT 'running',
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			ended_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS custom_entries (
+			language VARCHAR(16) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			entry JSONB,
+			overrides JSONB NOT NULL DEFAULT '[]',
+			updated_by VARCHAR(64) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (language, word)
+		)`,
+		`CREATE TABLE IF NOT EXISTS custom_entry_changes (
+			id BIGSERIAL PRIMARY KEY,
+			language VARCHAR(16) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			action VARCHAR(16) NOT NULL,
+			actor VARCHAR(64) NOT NULL,
+			before JSONB,
+			after JSONB,
+			changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_custom_entry_changes_word ON custom_entry_changes(language, word, changed_at DESC)`,
+		`ALTER TABLE dictionary_cache ADD COLUMN IF NOT EXISTS schema_version INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE dictionary_cache ADD COLUMN IF NOT EXISTS raw BYTEA`,
+		`ALTER TABLE wordbook_entries ADD COLUMN IF NOT EXISTS selected_homograph INTEGER`,
 	}
 
 	for _, m := range migrations {
@@ -84,7 +224,7 @@ func TestRepositoryIntegration_WordbookCRUD(t *testing.T) {
 	userID := "test-user"
 
 	// Test empty wordbook
-	entries, err := repo.GetWordbookEntries(ctx, userID)
+	entries, err := repo.GetWordbookEntries(ctx, userID, WordbookFilter{})
 	if err != nil {
 		t.Fatalf("GetWordbookEntries failed: %v", err)
 	}
@@ -93,7 +233,7 @@ func TestRepositoryIntegration_WordbookCRUD(t *testing.T) {
 	}
 
 	// Test add entry
-	entry, err := repo.AddWordbookEntry(ctx, userID, "hello", "a greeting")
+	entry, err := repo.AddWordbookEntry(ctx, userID, "en", "hello", "a greeting")
 	if err != nil {
 		t.Fatalf("AddWordbookEntry failed: %v", err)
 	}
@@ -105,7 +245,7 @@ func TestRepositoryIntegration_WordbookCRUD(t *testing.T) {
 	}
 
 	// Test get entries
-	entries, err = repo.GetWordbookEntries(ctx, userID)
+	entries, err = repo.GetWordbookEntries(ctx, userID, WordbookFilter{})
 	if err != nil {
 		t.Fatalf("GetWordbookEntries failed: %v", err)
 	}
@@ -114,7 +254,7 @@ func TestRepositoryIntegration_WordbookCRUD(t *testing.T) {
 	}
 
 	// Test word exists
-	exists, err := repo.WordExistsInWordbook(ctx, userID, "hello")
+	exists, err := repo.WordExistsInWordbook(ctx, userID, "en", "hello")
 	if err != nil {
 		t.Fatalf("WordExistsInWordbook failed: %v", err)
 	}
@@ -123,7 +263,7 @@ func TestRepositoryIntegration_WordbookCRUD(t *testing.T) {
 	}
 
 	// Test word doesn't exist
-	exists, err = repo.WordExistsInWordbook(ctx, userID, "nonexistent")
+	exists, err = repo.WordExistsInWordbook(ctx, userID, "en", "nonexistent")
 	if err != nil {
 		t.Fatalf("WordExistsInWordbook failed: %v", err)
 	}
@@ -132,13 +272,13 @@ func TestRepositoryIntegration_WordbookCRUD(t *testing.T) {
 	}
 
 	// Test delete entry
-	err = repo.DeleteWordbookEntry(ctx, userID, "hello")
+	err = repo.DeleteWordbookEntry(ctx, userID, "en", "hello")
 	if err != nil {
 		t.Fatalf("DeleteWordbookEntry failed: %v", err)
 	}
 
 	// Verify deletion
-	entries, err = repo.GetWordbookEntries(ctx, userID)
+	entries, err = repo.GetWordbookEntries(ctx, userID, WordbookFilter{})
 	if err != nil {
 		t.Fatalf("GetWordbookEntries failed: %v", err)
 	}
@@ -155,7 +295,7 @@ func TestRepositoryIntegration_DictionaryCache(t *testing.T) {
 	ctx := context.Background()
 
 	// Test cache miss
-	cache, err := repo.GetCachedDictionary(ctx, "hello")
+	cache, err := repo.GetCachedDictionary(ctx, "en", "hello")
 	if err != nil {
 		t.Fatalf("GetCachedDictionary failed: %v", err)
 	}
@@ -165,13 +305,13 @@ func TestRepositoryIntegration_DictionaryCache(t *testing.T) {
 
 	// Test set cache
 	testData := []byte(`{"word":"hello","meanings":[]}`)
-	err = repo.SetCachedDictionary(ctx, "hello", testData, "test", 1*time.Hour)
+	err = repo.SetCachedDictionary(ctx, "en", "hello", testData, nil, "test", 1*time.Hour)
 	if err != nil {
 		t.Fatalf("SetCachedDictionary failed: %v", err)
 	}
 
 	// Test cache hit
-	cache, err = repo.GetCachedDictionary(ctx, "hello")
+	cache, err = repo.GetCachedDictionary(ctx, "en", "hello")
 	if err != nil {
 		t.Fatalf("GetCachedDictionary failed: %v", err)
 	}
@@ -184,12 +324,12 @@ func TestRepositoryIntegration_DictionaryCache(t *testing.T) {
 
 	// Test cache update (upsert)
 	newData := []byte(`{"word":"hello","meanings":[{"partOfSpeech":"noun"}]}`)
-	err = repo.SetCachedDictionary(ctx, "hello", newData, "test", 1*time.Hour)
+	err = repo.SetCachedDictionary(ctx, "en", "hello", newData, nil, "test", 1*time.Hour)
 	if err != nil {
 		t.Fatalf("SetCachedDictionary update failed: %v", err)
 	}
 
-	cache, err = repo.GetCachedDictionary(ctx, "hello")
+	cache, err = repo.GetCachedDictionary(ctx, "en", "hello")
 	if err != nil {
 		t.Fatalf("GetCachedDictionary failed: %v", err)
 	}
@@ -198,6 +338,111 @@ func TestRepositoryIntegration_DictionaryCache(t *testing.T) {
 	}
 }
 
+func TestRepositoryIntegration_LanguageKeys(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+	userID := "test-user"
+
+	// "gift" is a present in English and poison in German
+	if err := repo.SetCachedDictionary(ctx, "en", "gift", []byte(`{"word":"gift"}`), nil, "test", time.Hour); err != nil {
+		t.Fatalf("SetCachedDictionary failed: %v", err)
+	}
+	if err := repo.SetCachedDictionary(ctx, "de", "gift", []byte(`{"word":"Gift"}`), nil, "test", time.Hour); err != nil {
+		t.Fatalf("SetCachedDictionary failed: %v", err)
+	}
+	cache, err := repo.GetCachedDictionary(ctx, "de", "gift")
+	if err != nil || cache == nil || !strings.Contains(string(cache.Data), "Gift") || cache.Language != "de" {
+		t.Fatalf("unexpected German cache row %+v, %v", cache, err)
+	}
+	if words, _ := repo.GetCachedWords(ctx, "en"); !reflect.DeepEqual(words, []string{"gift"}) {
+		t.Errorf("expected only English cached words, got %v", words)
+	}
+
+	if _, err := repo.AddWordbookEntry(ctx, userID, "en", "gift", "a present"); err != nil {
+		t.Fatalf("AddWordbookEntry failed: %v", err)
+	}
+	if _, err := repo.AddWordbookEntry(ctx, userID, "de", "gift", "poison"); err != nil {
+		t.Fatalf("AddWordbookEntry failed: %v", err)
+	}
+	entries, err := repo.GetWordbookEntries(ctx, userID, WordbookFilter{Language: "de"})
+	if err != nil {
+		t.Fatalf("GetWordbookEntries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ShortDefinition != "poison" {
+		t.Errorf("expected only the German entry, got %+v", entries)
+	}
+	if _, err := repo.AddWordbookEntry(ctx, userID, "de", "giftig", "poisonous"); err != nil {
+		t.Fatalf("AddWordbookEntry failed: %v", err)
+	}
+	if words, _ := repo.SearchWordbookWords(ctx, userID, "en", "gif", 10); !reflect.DeepEqual(words, []string{"gift"}) {
+		t.Errorf("expected only English completions, got %v", words)
+	}
+
+	if err := repo.DeleteWordbookEntry(ctx, userID, "de", "gift"); err != nil {
+		t.Fatalf("DeleteWordbookEntry failed: %v", err)
+	}
+	if exists, _ := repo.WordExistsInWordbook(ctx, userID, "en", "gift"); !exists {
+		t.Error("deleting the German entry should keep the English one")
+	}
+}
+
+func TestRepositoryIntegration_NormalizeWordKeys(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+	userID := "test-user"
+
+	// Rows saved before words were normalized
+	for _, stmt := range []string{
+		`INSERT INTO wordbook_entries (user_id, language, word, short_definition, repetitions) VALUES
+			('test-user', 'en', 'Apple', 'a fruit', 0),
+			('test-user', 'en', 'apple', 'the fruit', 3),
+			('test-user', 'en', 'don’t', 'do not', 0)`,
+		`INSERT INTO wordbook_contexts (entry_id, sentence)
+			SELECT id, 'An Apple a day.' FROM wordbook_entries WHERE word = 'Apple'`,
+		`INSERT INTO dictionary_cache (language, word, data, source, fetched_at, expires_at) VALUES
+			('en', 'Apple', '{"word":"old"}', 'test', NOW() - INTERVAL '1 day', NOW() + INTERVAL '1 day'),
+			('en', 'APPLE', '{"word":"new"}', 'test', NOW(), NOW() + INTERVAL '1 day')`,
+	} {
+		if _, err := pool.Exec(ctx, stmt); err != nil {
+			t.Fatalf("failed to seed rows: %v", err)
+		}
+	}
+
+	removed, err := repo.NormalizeWordKeys(ctx)
+	if err != nil {
+		t.Fatalf("NormalizeWordKeys failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 rows removed, got %d", removed)
+	}
+
+	entry, err := repo.GetWordbookEntry(ctx, userID, "en", "APPLE")
+	if err != nil {
+		t.Fatalf("GetWordbookEntry failed: %v", err)
+	}
+	if entry.ShortDefinition != "the fruit" || len(entry.Contexts) != 1 {
+		t.Errorf("expected the reviewed entry with the merged context, got %+v", entry)
+	}
+	if exists, _ := repo.WordExistsInWordbook(ctx, userID, "en", "don't"); !exists {
+		t.Error("expected the curly apostrophe to be normalized")
+	}
+
+	cache, err := repo.GetCachedDictionary(ctx, "en", "apple")
+	if err != nil || cache == nil || !strings.Contains(string(cache.Data), "new") {
+		t.Errorf("expected the newest cache row to be kept, got %+v, %v", cache, err)
+	}
+
+	if removed, err := repo.NormalizeWordKeys(ctx); err != nil || removed != 0 {
+		t.Errorf("second run removed %d rows, %v", removed, err)
+	}
+}
+
 func TestRepositoryIntegration_UpsertWordbook(t *testing.T) {
 	pool, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -207,13 +452,13 @@ func TestRepositoryIntegration_UpsertWordbook(t *testing.T) {
 	userID := "test-user"
 
 	// Add entry
-	_, err := repo.AddWordbookEntry(ctx, userID, "test", "original definition")
+	_, err := repo.AddWordbookEntry(ctx, userID, "en", "test", "original definition")
 	if err != nil {
 		t.Fatalf("AddWordbookEntry failed: %v", err)
 	}
 
 	// Update entry (same word)
-	entry, err := repo.AddWordbookEntry(ctx, userID, "test", "updated definition")
+	entry, err := repo.AddWordbookEntry(ctx, userID, "en", "test", "updated definition")
 	if err != nil {
 		t.Fatalf("AddWordbookEntry update failed: %v", err)
 	}
@@ -222,7 +467,7 @@ func TestRepositoryIntegration_UpsertWordbook(t *testing.T) {
 	}
 
 	// Verify only one entry exists
-	entries, err := repo.GetWordbookEntries(ctx, userID)
+	entries, err := repo.GetWordbookEntries(ctx, userID, WordbookFilter{})
 	if err != nil {
 		t.Fatalf("GetWordbookEntries failed: %v", err)
 	}
@@ -230,3 +475,888 @@ func TestRepositoryIntegration_UpsertWordbook(t *testing.T) {
 		t.Errorf("expected 1 entry, got %d", len(entries))
 	}
 }
+
+func TestRepositoryIntegration_ReviewStats(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+	userID := "test-user"
+
+	if _, err := repo.AddWordbookEntry(ctx, userID, "en", "hello", "a greeting"); err != nil {
+		t.Fatalf("AddWordbookEntry failed: %v", err)
+	}
+
+	state := models.ReviewState{Ease: 2.6, IntervalDays: 1, Repetitions: 1, DueAt: time.Now().Add(24 * time.Hour)}
+	entry, err := repo.RecordReview(ctx, userID, "en", "hello", 4, state)
+	if err != nil {
+		t.Fatalf("RecordReview failed: %v", err)
+	}
+	if entry.Repetitions != 1 || entry.IntervalDays != 1 {
+		t.Errorf("review state not stored: %+v", entry.ReviewState)
+	}
+
+	if _, err := repo.RecordReview(ctx, userID, "en", "missing", 4, state); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for missing word, got %v", err)
+	}
+
+	total, recalled, err := repo.GetReviewTotals(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetReviewTotals failed: %v", err)
+	}
+	if total != 1 || recalled != 1 {
+		t.Errorf("expected 1/1 reviews, got %d/%d", recalled, total)
+	}
+
+	reviews, err := repo.CountReviewsPerDay(ctx, userID, "Asia/Shanghai", time.Now().Add(-48*time.Hour))
+	if err != nil {
+		t.Fatalf("CountReviewsPerDay failed: %v", err)
+	}
+	if len(reviews) != 1 || reviews[0].Count != 1 {
+		t.Errorf("unexpected review counts: %+v", reviews)
+	}
+
+	settings, err := repo.GetUserSettings(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUserSettings failed: %v", err)
+	}
+	if settings.TimeZone != "UTC" {
+		t.Errorf("expected default time zone UTC, got %s", settings.TimeZone)
+	}
+
+	// The part of speech comes from the snapshot when nothing is cached
+	snapshot := &models.DictionaryEntry{Word: "run", Meanings: []models.Meaning{
+		{PartOfSpeech: "verb", Definitions: []models.Definition{{Definition: "to move quickly"}}},
+	}}
+	if _, err := repo.SaveWordbookEntry(ctx, userID, WordbookEntryInput{Language: "en", Word: "run", ShortDefinition: "to move quickly", Snapshot: snapshot}); err != nil {
+		t.Fatalf("SaveWordbookEntry failed: %v", err)
+	}
+	counts, err := repo.CountPartsOfSpeech(ctx, userID)
+	if err != nil {
+		t.Fatalf("CountPartsOfSpeech failed: %v", err)
+	}
+	want := []models.PartOfSpeechCount{{PartOfSpeech: "unknown", Count: 1}, {PartOfSpeech: "verb", Count: 1}}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("expected %+v, got %+v", want, counts)
+	}
+}
+
+func TestRepositoryIntegration_LookupHistory(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+	userID := "test-user"
+
+	for _, e := range []struct {
+		word  string
+		found bool
+	}{{"ephemeral", true}, {"ephemeral", true}, {"hello", true}, {"hello", true}, {"xyzzy", false}, {"xyzzy", false}} {
+		if err := repo.AddLookupEvent(ctx, userID, "en", e.word, e.found); err != nil {
+			t.Fatalf("AddLookupEvent failed: %v", err)
+		}
+	}
+	if _, err := repo.AddWordbookEntry(ctx, userID, "en", "hello", "a greeting"); err != nil {
+		t.Fatalf("AddWordbookEntry failed: %v", err)
+	}
+
+	events, total, err := repo.GetLookupHistory(ctx, userID, 4, 0)
+	if err != nil {
+		t.Fatalf("GetLookupHistory failed: %v", err)
+	}
+	if total != 6 || len(events) != 4 || events[0].Language != "en" {
+		t.Errorf("expected 4 of 6 English events, got %+v of %d", events, total)
+	}
+
+	suggestions, err := repo.GetLookupSuggestions(ctx, userID, 2, 10)
+	if err != nil {
+		t.Fatalf("GetLookupSuggestions failed: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Word != "ephemeral" || suggestions[0].LookupCount != 2 {
+		t.Errorf("expected only 'ephemeral' x2, got %+v", suggestions)
+	}
+
+	// Saving a word in one language doesn't hide lookups of it in another
+	for range 3 {
+		if err := repo.AddLookupEvent(ctx, userID, "de", "hello", true); err != nil {
+			t.Fatalf("AddLookupEvent failed: %v", err)
+		}
+	}
+	suggestions, err = repo.GetLookupSuggestions(ctx, userID, 3, 10)
+	if err != nil {
+		t.Fatalf("GetLookupSuggestions failed: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Language != "de" || suggestions[0].Word != "hello" {
+		t.Errorf("expected only German 'hello', got %+v", suggestions)
+	}
+
+	if err := repo.ClearLookupHistory(ctx, userID); err != nil {
+		t.Fatalf("ClearLookupHistory failed: %v", err)
+	}
+	if _, total, _ = repo.GetLookupHistory(ctx, userID, 10, 0); total != 0 {
+		t.Errorf("expected empty history after clear, got %d", total)
+	}
+}
+
+func TestRepositoryIntegration_WordbookSnapshot(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+	userID := "test-user"
+
+	snapshot := &models.DictionaryEntry{
+		Word:      "bank",
+		Phonetics: []models.Phonetic{{Text: "/bæŋk/"}},
+		Meanings: []models.Meaning{
+			{PartOfSpeech: "noun", Definitions: []models.Definition{{Definition: "a financial institution"}, {Definition: "the side of a river"}}},
+		},
+	}
+	sense := &models.SenseRef{MeaningIndex: 0, DefinitionIndex: 1}
+
+	in := WordbookEntryInput{Language: "en", Word: "bank", ShortDefinition: "river side", Snapshot: snapshot, SelectedSense: sense}
+	if _, err := repo.SaveWordbookEntry(ctx, userID, in); err != nil {
+		t.Fatalf("SaveWordbookEntry failed: %v", err)
+	}
+
+	// Re-adding without a snapshot keeps the stored one
+	if _, err := repo.AddWordbookEntry(ctx, userID, "en", "bank", "updated"); err != nil {
+		t.Fatalf("AddWordbookEntry failed: %v", err)
+	}
+
+	entry, err := repo.GetWordbookEntry(ctx, userID, "en", "bank")
+	if err != nil {
+		t.Fatalf("GetWordbookEntry failed: %v", err)
+	}
+	if entry.Snapshot == nil || len(entry.Snapshot.Meanings[0].Definitions) != 2 {
+		t.Fatalf("snapshot not preserved: %+v", entry.Snapshot)
+	}
+	if entry.SelectedSense == nil || *entry.SelectedSense != *sense {
+		t.Errorf("selected sense mismatch: got %+v", entry.SelectedSense)
+	}
+	if entry.SelectedHomograph != nil {
+		t.Errorf("expected no pinned homograph, got %d", *entry.SelectedHomograph)
+	}
+
+	// A pinned homograph is kept on re-adding and cleared by a new snapshot
+	homograph := 0
+	in.SelectedHomograph = &homograph
+	if _, err := repo.SaveWordbookEntry(ctx, userID, in); err != nil {
+		t.Fatalf("SaveWordbookEntry failed: %v", err)
+	}
+	if _, err := repo.AddWordbookEntry(ctx, userID, "en", "bank", "updated"); err != nil {
+		t.Fatalf("AddWordbookEntry failed: %v", err)
+	}
+	entry, err = repo.GetWordbookEntry(ctx, userID, "en", "bank")
+	if err != nil {
+		t.Fatalf("GetWordbookEntry failed: %v", err)
+	}
+	if entry.SelectedHomograph == nil || *entry.SelectedHomograph != 0 {
+		t.Errorf("expected homograph 0 pinned, got %v", entry.SelectedHomograph)
+	}
+
+	in.SelectedHomograph = nil
+	entry, err = repo.SaveWordbookEntry(ctx, userID, in)
+	if err != nil {
+		t.Fatalf("SaveWordbookEntry failed: %v", err)
+	}
+	if entry.SelectedHomograph != nil {
+		t.Errorf("expected the pin cleared with a new snapshot, got %d", *entry.SelectedHomograph)
+	}
+
+	in.SelectedSense = nil
+	entry, err = repo.SaveWordbookEntry(ctx, userID, in)
+	if err != nil {
+		t.Fatalf("SaveWordbookEntry failed: %v", err)
+	}
+	if entry.SelectedSense != nil {
+		t.Errorf("expected the sense cleared with a new snapshot, got %+v", entry.SelectedSense)
+	}
+
+	if _, err := repo.GetWordbookEntry(ctx, userID, "en", "missing"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRepositoryIntegration_WordContexts(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+	userID := "test-user"
+
+	first := WordbookEntryInput{
+		Language:        "en",
+		Word:            "serendipity",
+		ShortDefinition: "happy accident",
+		Context:         &models.WordContext{Sentence: "It was pure serendipity.", SourceTitle: "A Novel", Location: "p. 12"},
+	}
+	if _, err := repo.SaveWordbookEntry(ctx, userID, first); err != nil {
+		t.Fatalf("SaveWordbookEntry failed: %v", err)
+	}
+
+	// Re-adding appends a second context instead of replacing the first
+	second := first
+	second.Context = &models.WordContext{Sentence: "Serendipity struck again.", SourceURL: "https://example.com/post"}
+	entry, err := repo.SaveWordbookEntry(ctx, userID, second)
+	if err != nil {
+		t.Fatalf("SaveWordbookEntry failed: %v", err)
+	}
+	if len(entry.Contexts) != 2 {
+		t.Fatalf("expected 2 contexts, got %d", len(entry.Contexts))
+	}
+	if entry.Contexts[0].Location != "p. 12" {
+		t.Errorf("first context not preserved: %+v", entry.Contexts[0])
+	}
+
+	entries, err := repo.GetWordbookEntries(ctx, userID, WordbookFilter{})
+	if err != nil {
+		t.Fatalf("GetWordbookEntries failed: %v", err)
+	}
+	if len(entries) != 1 || len(entries[0].Contexts) != 2 {
+		t.Errorf("expected contexts in wordbook listing, got %+v", entries)
+	}
+
+	// Contexts are removed with their entry
+	if err := repo.DeleteWordbookEntry(ctx, userID, "en", "serendipity"); err != nil {
+		t.Fatalf("DeleteWordbookEntry failed: %v", err)
+	}
+	var remaining int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM wordbook_contexts`).Scan(&remaining); err != nil {
+		t.Fatalf("count contexts failed: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("expected contexts to cascade on delete, %d left", remaining)
+	}
+}
+
+func TestRepositoryIntegration_ImportExport(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+	userID := "test-user"
+
+	notes := "original notes"
+	existing := WordbookEntryInput{
+		Language:        "en",
+		Word:            "hello",
+		ShortDefinition: "a greeting",
+		Notes:           &notes,
+		Tags:            []string{"basics"},
+		Context:         &models.WordContext{Sentence: "Hello there."},
+	}
+	if _, err := repo.SaveWordbookEntry(ctx, userID, existing); err != nil {
+		t.Fatalf("SaveWordbookEntry failed: %v", err)
+	}
+
+	incoming := []models.WordbookEntry{
+		{Language: "en", Word: "hello", ShortDefinition: "imported", Tags: []string{"greetings"}, Contexts: []models.WordContext{{Sentence: "Hello again."}}},
+		{Language: "en", Word: "world", ShortDefinition: "the earth", ReviewState: models.ReviewState{Ease: 2.2, IntervalDays: 4, Repetitions: 2}},
+	}
+
+	// Dry run reports actions without writing
+	actions, err := repo.ImportWordbookEntries(ctx, userID, incoming, models.ImportMerge, true)
+	if err != nil {
+		t.Fatalf("dry-run import failed: %v", err)
+	}
+	if actions[0] != models.ImportActionMerged || actions[1] != models.ImportActionCreated {
+		t.Errorf("unexpected dry-run actions: %v", actions)
+	}
+	if count, _ := repo.CountWordbookEntries(ctx, userID); count != 1 {
+		t.Errorf("dry run should not write, found %d entries", count)
+	}
+
+	if _, err := repo.ImportWordbookEntries(ctx, userID, incoming, models.ImportMerge, false); err != nil {
+		t.Fatalf("merge import failed: %v", err)
+	}
+
+	var exported []*models.WordbookEntry
+	err = repo.StreamWordbookEntries(ctx, userID, func(e *models.WordbookEntry) error {
+		exported = append(exported, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamWordbookEntries failed: %v", err)
+	}
+	if len(exported) != 2 {
+		t.Fatalf("expected 2 exported entries, got %d", len(exported))
+	}
+
+	hello := exported[0]
+	if hello.ShortDefinition != "a greeting" || len(hello.Tags) != 2 || len(hello.Contexts) != 2 {
+		t.Errorf("merge result unexpected: %+v", hello)
+	}
+	if world := exported[1]; world.IntervalDays != 4 || world.Repetitions != 2 {
+		t.Errorf("review state not imported: %+v", world.ReviewState)
+	}
+
+	// Overwrite replaces fields and contexts
+	overwrite := []models.WordbookEntry{{Language: "en", Word: "hello", ShortDefinition: "replaced"}}
+	if _, err := repo.ImportWordbookEntries(ctx, userID, overwrite, models.ImportOverwrite, false); err != nil {
+		t.Fatalf("overwrite import failed: %v", err)
+	}
+	entry, err := repo.GetWordbookEntry(ctx, userID, "en", "hello")
+	if err != nil {
+		t.Fatalf("GetWordbookEntry failed: %v", err)
+	}
+	if entry.ShortDefinition != "replaced" || len(entry.Contexts) != 0 || len(entry.Tags) != 0 {
+		t.Errorf("overwrite result unexpected: %+v", entry)
+	}
+}
+
+func TestRepositoryIntegration_WordbookLevels(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+	userID := "test-user"
+
+	inputs := []WordbookEntryInput{
+		{Language: "en", Word: "house", ShortDefinition: "a building", Levels: models.WordLevels{FrequencyRank: 300, Zipf: 5.22, CEFRLevel: "A1"}},
+		{Language: "en", Word: "abandon", ShortDefinition: "to leave", Levels: models.WordLevels{FrequencyRank: 2900, Zipf: 4.24, CEFRLevel: "B2"}},
+		{Language: "en", Word: "zyzzyva", ShortDefinition: "a weevil"},
+	}
+	for _, in := range inputs {
+		if _, err := repo.SaveWordbookEntry(ctx, userID, in); err != nil {
+			t.Fatalf("SaveWordbookEntry failed: %v", err)
+		}
+	}
+
+	words := func(filter WordbookFilter) []string {
+		entries, err := repo.GetWordbookEntries(ctx, userID, filter)
+		if err != nil {
+			t.Fatalf("GetWordbookEntries failed: %v", err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Word)
+		}
+		return got
+	}
+
+	if got := words(WordbookFilter{CEFRLevels: []string{"B1", "B2"}}); !reflect.DeepEqual(got, []string{"abandon"}) {
+		t.Errorf("unexpected CEFR filter result %v", got)
+	}
+	minZipf := 5.0
+	if got := words(WordbookFilter{MinZipf: &minZipf}); !reflect.DeepEqual(got, []string{"house"}) {
+		t.Errorf("unexpected zipf filter result %v", got)
+	}
+	if got := words(WordbookFilter{Sort: WordbookSortFrequency}); !reflect.DeepEqual(got, []string{"house", "abandon", "zyzzyva"}) {
+		t.Errorf("expected unranked words last, got %v", got)
+	}
+	if got := words(WordbookFilter{Sort: WordbookSortFrequency, Order: "desc"}); !reflect.DeepEqual(got, []string{"abandon", "house", "zyzzyva"}) {
+		t.Errorf("expected unranked words last in descending order, got %v", got)
+	}
+
+	if _, err := pool.Exec(ctx, `UPDATE wordbook_entries SET frequency_rank = NULL WHERE word = 'house'`); err != nil {
+		t.Fatalf("failed to clear levels: %v", err)
+	}
+	unleveled, err := repo.GetUnleveledWordbookWords(ctx)
+	if err != nil {
+		t.Fatalf("GetUnleveledWordbookWords failed: %v", err)
+	}
+	if !reflect.DeepEqual(unleveled, []WordKey{{Language: "en", Word: "house"}}) {
+		t.Errorf("expected house to need levels, got %v", unleveled)
+	}
+	if err := repo.SetWordbookLevels(ctx, "en", "house", models.WordLevels{FrequencyRank: 300, Zipf: 5.22, CEFRLevel: "A1"}); err != nil {
+		t.Fatalf("SetWordbookLevels failed: %v", err)
+	}
+	entry, err := repo.GetWordbookEntry(ctx, userID, "en", "house")
+	if err != nil {
+		t.Fatalf("GetWordbookEntry failed: %v", err)
+	}
+	if entry.FrequencyRank != 300 || entry.CEFRLevel != "A1" {
+		t.Errorf("unexpected levels %+v", entry.WordLevels)
+	}
+}
+
+func TestRepositoryIntegration_AudioFiles(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+
+	if f, err := repo.GetAudioFile(ctx, "en", "hello", ""); err != nil || f != nil {
+		t.Fatalf("expected no audio file, got %+v, %v", f, err)
+	}
+
+	f := &models.AudioFile{
+		Language:    "en",
+		Word:        "Hello",
+		Accent:      "uk",
+		SourceURL:   "https://example.com/hello-uk.mp3",
+		ContentType: "audio/mpeg",
+		Checksum:    strings.Repeat("ab", 32),
+		Size:        1234,
+	}
+	if err := repo.SaveAudioFile(ctx, f); err != nil {
+		t.Fatalf("SaveAudioFile failed: %v", err)
+	}
+	if f.Word != "hello" || f.FetchedAt.IsZero() {
+		t.Errorf("expected the normalized word and fetch time, got %+v", f)
+	}
+
+	got, err := repo.GetAudioFile(ctx, "en", "HELLO", "uk")
+	if err != nil || got == nil {
+		t.Fatalf("GetAudioFile failed: %+v, %v", got, err)
+	}
+	if got.Checksum != f.Checksum || got.ContentType != "audio/mpeg" || got.Size != 1234 {
+		t.Errorf("unexpected audio file %+v", got)
+	}
+	if other, _ := repo.GetAudioFile(ctx, "en", "hello", "us"); other != nil {
+		t.Errorf("expected accents to be stored separately, got %+v", other)
+	}
+	if got.Synthetic {
+		t.Error("expected a recording not to be synthetic")
+	}
+
+	synthetic := &models.AudioFile{
+		Language:    "en",
+		Word:        "hello",
+		ContentType: "audio/wav",
+		Checksum:    strings.Repeat("cd", 32),
+		Size:        42,
+		Synthetic:   true,
+	}
+	if err := repo.SaveAudioFile(ctx, synthetic); err != nil {
+		t.Fatalf("SaveAudioFile failed: %v", err)
+	}
+	if got, _ := repo.GetAudioFile(ctx, "en", "hello", ""); got == nil || !got.Synthetic || got.SourceURL != "" {
+		t.Errorf("expected synthetic audio, got %+v", got)
+	}
+}
+
+func TestRepositoryIntegration_Jobs(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+
+	// A second session can't take a lock held by the first
+	ran, err := repo.RunExclusive(ctx, "job:test", func(ctx context.Context) error {
+		inner, err := repo.RunExclusive(ctx, "job:test", func(context.Context) error {
+			t.Error("ran while the lock was held")
+			return nil
+		})
+		if err != nil || inner {
+			t.Errorf("expected the nested run to be skipped, got %v, %v", inner, err)
+		}
+		return nil
+	})
+	if err != nil || !ran {
+		t.Fatalf("RunExclusive failed: %v, %v", ran, err)
+	}
+	if ran, err := repo.RunExclusive(ctx, "job:test", func(context.Context) error { return nil }); err != nil || !ran {
+		t.Errorf("expected the lock to be released, got %v, %v", ran, err)
+	}
+
+	if last, err := repo.GetLastJobRun(ctx, "purge"); err != nil || last != nil {
+		t.Fatalf("expected no runs, got %+v, %v", last, err)
+	}
+	failed, err := repo.StartJobRun(ctx, "purge")
+	if err != nil {
+		t.Fatalf("StartJobRun failed: %v", err)
+	}
+	if err := repo.FinishJobRun(ctx, failed, "", errors.New("boom")); err != nil {
+		t.Fatalf("FinishJobRun failed: %v", err)
+	}
+	ok, _ := repo.StartJobRun(ctx, "purge")
+	if err := repo.FinishJobRun(ctx, ok, "purged 3", nil); err != nil {
+		t.Fatalf("FinishJobRun failed: %v", err)
+	}
+
+	last, err := repo.GetLastJobRun(ctx, "purge")
+	if err != nil || last == nil || last.ID != ok.ID || last.Result != "purged 3" || last.FinishedAt == nil {
+		t.Errorf("unexpected last run %+v, %v", last, err)
+	}
+	lastErr, err := repo.GetLastFailedJobRun(ctx, "purge")
+	if err != nil || lastErr == nil || lastErr.Error != "boom" {
+		t.Errorf("unexpected last failure %+v, %v", lastErr, err)
+	}
+	if runs, _ := repo.GetJobRuns(ctx, "purge", 10); len(runs) != 2 || runs[0].ID != ok.ID {
+		t.Errorf("expected both runs newest first, got %+v", runs)
+	}
+	if n, err := repo.PruneJobRuns(ctx, time.Now().Add(time.Hour)); err != nil || n != 2 {
+		t.Errorf("expected 2 runs pruned, got %d, %v", n, err)
+	}
+}
+
+func TestRepositoryIntegration_CacheMaintenance(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+
+	for _, word := range []string{"stale", "popular", "obscure", "fresh"} {
+		if err := repo.SetCachedDictionary(ctx, "en", word, []byte(`{}`), nil, "test", time.Hour); err != nil {
+			t.Fatalf("SetCachedDictionary failed: %v", err)
+		}
+	}
+	for _, stmt := range []string{
+		`UPDATE dictionary_cache SET expires_at = NOW() - INTERVAL '1 hour' WHERE word = 'stale'`,
+		`UPDATE dictionary_cache SET expires_at = NOW() + INTERVAL '30 days' WHERE word = 'fresh'`,
+	} {
+		if _, err := pool.Exec(ctx, stmt); err != nil {
+			t.Fatalf("failed to age cache: %v", err)
+		}
+	}
+	for _, word := range []string{"popular", "popular", "fresh", "fresh", "obscure"} {
+		if err := repo.AddLookupEvent(ctx, "default", "en", word, true); err != nil {
+			t.Fatalf("AddLookupEvent failed: %v", err)
+		}
+	}
+
+	keys, err := repo.GetExpiringPopularWords(ctx, 24*time.Hour, 30*24*time.Hour, 2, 10)
+	if err != nil {
+		t.Fatalf("GetExpiringPopularWords failed: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []WordKey{{Language: "en", Word: "popular"}}) {
+		t.Errorf("expected only the popular expiring word, got %+v", keys)
+	}
+
+	purged, err := repo.CleanExpiredCache(ctx)
+	if err != nil || purged != 1 {
+		t.Errorf("expected 1 expired entry purged, got %d, %v", purged, err)
+	}
+}
+
+func TestRepositoryIntegration_ReviewReminders(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+
+	for _, word := range []string{"apple", "banana"} {
+		if _, err := repo.AddWordbookEntry(ctx, "default", "en", word, "a fruit"); err != nil {
+			t.Fatalf("AddWordbookEntry failed: %v", err)
+		}
+	}
+	if _, err := pool.Exec(ctx, `UPDATE wordbook_entries SET due_at = NOW() + INTERVAL '1 day' WHERE word = 'banana'`); err != nil {
+		t.Fatalf("failed to schedule entry: %v", err)
+	}
+
+	if n, err := repo.CreateReviewReminders(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 reminder, got %d, %v", n, err)
+	}
+	// Running again updates the day's reminder rather than adding one
+	if _, err := repo.CreateReviewReminders(ctx); err != nil {
+		t.Fatalf("CreateReviewReminders failed: %v", err)
+	}
+
+	reminders, err := repo.GetReviewReminders(ctx, "default")
+	if err != nil || len(reminders) != 1 || reminders[0].DueCount != 1 || len(reminders[0].Day) != len("2006-01-02") {
+		t.Fatalf("unexpected reminders %+v, %v", reminders, err)
+	}
+
+	if err := repo.DismissReviewReminders(ctx, "default"); err != nil {
+		t.Fatalf("DismissReviewReminders failed: %v", err)
+	}
+	if reminders, _ := repo.GetReviewReminders(ctx, "default"); len(reminders) != 0 {
+		t.Errorf("expected dismissed reminders to be hidden, got %+v", reminders)
+	}
+	if n, _ := repo.CreateReviewReminders(ctx); n != 0 {
+		t.Errorf("expected a dismissed reminder to stay dismissed, got %d updated", n)
+	}
+}
+
+func TestRepositoryIntegration_WarmRuns(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+
+	if _, err := repo.GetWarmRun(ctx, 1); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	run, err := repo.CreateWarmRun(ctx, "en", []string{"the", "of", "and"})
+	if err != nil {
+		t.Fatalf("CreateWarmRun failed: %v", err)
+	}
+	if run.Total != 3 || run.Position != 0 || run.Status != models.WarmStatusRunning || run.EndedAt != nil {
+		t.Errorf("unexpected new run %+v", run)
+	}
+
+	run.Position, run.Fetched, run.NotFound = 2, 1, []string{"of"}
+	run.Status = models.WarmStatusInterrupted
+	if err := repo.SaveWarmRunProgress(ctx, run); err != nil {
+		t.Fatalf("SaveWarmRunProgress failed: %v", err)
+	}
+	if run.EndedAt == nil {
+		t.Error("expected an interrupted run to be marked ended")
+	}
+
+	got, err := repo.GetWarmRun(ctx, run.ID)
+	if err != nil || got.Position != 2 || got.Fetched != 1 || !reflect.DeepEqual(got.NotFound, []string{"of"}) {
+		t.Errorf("unexpected saved run %+v, %v", got, err)
+	}
+	words, err := repo.GetWarmRunWords(ctx, run.ID, got.Position)
+	if err != nil || !reflect.DeepEqual(words, []string{"and"}) {
+		t.Errorf("expected the words left to look up, got %q, %v", words, err)
+	}
+	if words, _ := repo.GetWarmRunWords(ctx, run.ID, 3); len(words) != 0 {
+		t.Errorf("expected no words past the end, got %q", words)
+	}
+
+	if runs, _ := repo.GetWarmRuns(ctx, 10); len(runs) != 1 || runs[0].ID != run.ID {
+		t.Errorf("unexpected runs %+v", runs)
+	}
+}
+
+func TestRepositoryIntegration_CacheAdmin(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+
+	for _, c := range []struct{ language, word, source string }{
+		{"en", "hello", "freedictionaryapi"},
+		{"en", "help", "freedictionaryapi"},
+		{"en", "give up", "phrases"},
+		{"de", "hallo", "wiktionary"},
+	} {
+		if err := repo.SetCachedDictionary(ctx, c.language, c.word, []byte(`{"word":"`+c.word+`"}`), nil, c.source, time.Hour); err != nil {
+			t.Fatalf("SetCachedDictionary failed: %v", err)
+		}
+	}
+	if _, err := pool.Exec(ctx, `UPDATE dictionary_cache SET fetched_at = NOW() - INTERVAL '10 days', expires_at = NOW() - INTERVAL '3 days' WHERE word = 'help'`); err != nil {
+		t.Fatalf("failed to age cache: %v", err)
+	}
+
+	entries, total, err := repo.GetCacheEntries(ctx, CacheFilter{Search: "HEL"}, 10, 0)
+	if err != nil {
+		t.Fatalf("GetCacheEntries failed: %v", err)
+	}
+	if total != 2 || len(entries) != 2 || entries[0].Word != "hello" || !entries[1].Expired || entries[0].Size == 0 {
+		t.Errorf("unexpected search results %+v (total %d)", entries, total)
+	}
+	if _, total, _ := repo.GetCacheEntries(ctx, CacheFilter{Language: "de"}, 10, 0); total != 1 {
+		t.Errorf("expected 1 German entry, got %d", total)
+	}
+
+	// Expired rows are still shown to admins
+	row, err := repo.GetCacheRow(ctx, "en", "Help")
+	if err != nil || row.Source != "freedictionaryapi" || !strings.Contains(string(row.Data), "help") {
+		t.Errorf("unexpected cache row %+v, %v", row, err)
+	}
+	if _, err := repo.GetCacheRow(ctx, "en", "missing"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	stats, err := repo.GetCacheStats(ctx)
+	if err != nil || stats.Entries != 4 || stats.Expired != 1 || stats.Sources["phrases"] != 1 || stats.TableBytes == 0 {
+		t.Errorf("unexpected stats %+v, %v", stats, err)
+	}
+
+	if n, err := repo.PurgeCache(ctx, CacheFilter{FetchedBefore: time.Now().Add(-72 * time.Hour)}); err != nil || n != 1 {
+		t.Errorf("expected the old entry purged, got %d, %v", n, err)
+	}
+	if n, err := repo.PurgeCache(ctx, CacheFilter{Source: "phrases"}); err != nil || n != 1 {
+		t.Errorf("expected the phrase purged, got %d, %v", n, err)
+	}
+	if n, err := repo.PurgeCache(ctx, CacheFilter{Language: "en", Word: "HELLO"}); err != nil || n != 1 {
+		t.Errorf("expected hello purged, got %d, %v", n, err)
+	}
+	if _, total, _ := repo.GetCacheEntries(ctx, CacheFilter{}, 10, 0); total != 1 {
+		t.Errorf("expected only the German entry left, got %d", total)
+	}
+}
+
+func TestRepositoryIntegration_CustomEntries(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+
+	if entry, err := repo.GetCustomEntry(ctx, "en", "hello"); err != nil || entry != nil {
+		t.Fatalf("expected no custom entry, got %+v, %v", entry, err)
+	}
+
+	overrides := &models.CustomEntry{
+		Language:  "en",
+		Word:      "Hello",
+		Overrides: []models.EntryOverride{{Action: models.OverrideReplaceDefinition, PartOfSpeech: "noun", Definition: "A greeting."}},
+	}
+	if err := repo.SaveCustomEntry(ctx, overrides, "alice"); err != nil {
+		t.Fatalf("SaveCustomEntry failed: %v", err)
+	}
+	if overrides.Word != "hello" || overrides.CreatedAt.IsZero() {
+		t.Errorf("expected normalized word and timestamps, got %+v", overrides)
+	}
+
+	full := &models.CustomEntry{
+		Language: "en",
+		Word:     "hello",
+		Entry: &models.DictionaryEntry{
+			Word:     "hello",
+			Meanings: []models.Meaning{{PartOfSpeech: "interjection", Definitions: []models.Definition{{Definition: "Used as a greeting."}}}},
+		},
+	}
+	if err := repo.SaveCustomEntry(ctx, full, "bob"); err != nil {
+		t.Fatalf("SaveCustomEntry failed: %v", err)
+	}
+
+	got, err := repo.GetCustomEntry(ctx, "en", "HELLO")
+	if err != nil || got == nil {
+		t.Fatalf("GetCustomEntry failed: %+v, %v", got, err)
+	}
+	if got.Entry == nil || got.Entry.Meanings[0].PartOfSpeech != "interjection" || len(got.Overrides) != 0 || got.UpdatedBy != "bob" {
+		t.Errorf("unexpected custom entry %+v", got)
+	}
+
+	if err := repo.SaveCustomEntry(ctx, &models.CustomEntry{Language: "de", Word: "hallo", Overrides: overrides.Overrides}, "alice"); err != nil {
+		t.Fatalf("SaveCustomEntry failed: %v", err)
+	}
+	if entries, err := repo.GetCustomEntries(ctx, "de"); err != nil || len(entries) != 1 || entries[0].Word != "hallo" {
+		t.Errorf("expected the German entry, got %+v, %v", entries, err)
+	}
+	if entries, _ := repo.GetCustomEntries(ctx, ""); len(entries) != 2 {
+		t.Errorf("expected 2 entries across languages, got %d", len(entries))
+	}
+
+	if err := repo.DeleteCustomEntry(ctx, "en", "hello", "carol"); err != nil {
+		t.Fatalf("DeleteCustomEntry failed: %v", err)
+	}
+	if err := repo.DeleteCustomEntry(ctx, "en", "hello", "carol"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+
+	changes, err := repo.GetCustomEntryChanges(ctx, "en", "hello", 10)
+	if err != nil {
+		t.Fatalf("GetCustomEntryChanges failed: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+	deleted, updated, created := changes[0], changes[1], changes[2]
+	if deleted.Action != models.ChangeDeleted || deleted.Actor != "carol" || deleted.Before == nil || deleted.After != nil {
+		t.Errorf("unexpected deletion %+v", deleted)
+	}
+	if updated.Action != models.ChangeUpdated || updated.Actor != "bob" || len(updated.Before.Overrides) != 1 || updated.After.Entry == nil {
+		t.Errorf("unexpected update %+v", updated)
+	}
+	if created.Action != models.ChangeCreated || created.Actor != "alice" || created.Before != nil {
+		t.Errorf("unexpected creation %+v", created)
+	}
+
+	if all, _ := repo.GetCustomEntryChanges(ctx, "", "", 10); len(all) != 4 {
+		t.Errorf("expected 4 changes in the audit log, got %d", len(all))
+	}
+}
+
+func TestRepositoryIntegration_CacheSchemaVersions(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+
+	for _, word := range []string{"alpha", "beta", "gamma"} {
+		if err := repo.SetCachedDictionary(ctx, "en", word, []byte(`{"word":"`+word+`"}`), nil, "freedictionaryapi", time.Hour); err != nil {
+			t.Fatalf("SetCachedDictionary failed: %v", err)
+		}
+	}
+	if cached, err := repo.GetCachedDictionary(ctx, "en", "alpha"); err != nil || cached.SchemaVersion != models.CacheSchemaVersion {
+		t.Fatalf("expected rows written in the current version, got %+v, %v", cached, err)
+	}
+
+	// Rows from before a schema change, one of them expired
+	if _, err := pool.Exec(ctx, `UPDATE dictionary_cache SET schema_version = 0 WHERE word IN ('alpha', 'gamma')`); err != nil {
+		t.Fatalf("failed to age schema: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE dictionary_cache SET expires_at = NOW() - INTERVAL '1 hour' WHERE word = 'gamma'`); err != nil {
+		t.Fatalf("failed to expire row: %v", err)
+	}
+
+	rows, err := repo.GetOutdatedCacheRows(ctx, WordKey{}, 1)
+	if err != nil || len(rows) != 1 || rows[0].Word != "alpha" || rows[0].SchemaVersion != 0 {
+		t.Fatalf("expected alpha first, got %+v, %v", rows, err)
+	}
+	rows, err = repo.GetOutdatedCacheRows(ctx, WordKey{Language: "en", Word: "alpha"}, 10)
+	if err != nil || len(rows) != 1 || rows[0].Word != "gamma" {
+		t.Fatalf("expected the expired gamma next, got %+v, %v", rows, err)
+	}
+
+	if ok, err := repo.UpgradeCachedDictionary(ctx, "en", "alpha", 0, []byte(`{"word":"alpha","phonetics":[]}`)); err != nil || !ok {
+		t.Fatalf("UpgradeCachedDictionary failed: %v, %v", ok, err)
+	}
+	// A row rewritten meanwhile isn't overwritten
+	if ok, err := repo.UpgradeCachedDictionary(ctx, "en", "alpha", 0, []byte(`{}`)); err != nil || ok {
+		t.Errorf("expected no upgrade of a current row, got %v, %v", ok, err)
+	}
+	cached, err := repo.GetCachedDictionary(ctx, "en", "alpha")
+	if err != nil || cached.SchemaVersion != models.CacheSchemaVersion || !strings.Contains(string(cached.Data), "phonetics") {
+		t.Errorf("unexpected upgraded row %+v, %v", cached, err)
+	}
+
+	stats, err := repo.GetCacheStats(ctx)
+	if err != nil || stats.Versions[models.CacheSchemaVersion] != 2 || stats.Versions[0] != 1 {
+		t.Errorf("unexpected versions %+v, %v", stats, err)
+	}
+}
+
+func TestRepositoryIntegration_CacheRawPayload(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := New(pool)
+	ctx := context.Background()
+
+	raw := []byte(`[{"word":"bass","meanings":[]},{"word":"bass","meanings":[]}]`)
+	if err := repo.SetCachedDictionary(ctx, "en", "bass", []byte(`{"word":"bass"}`), raw, "freedictionaryapi", time.Hour); err != nil {
+		t.Fatalf("SetCachedDictionary failed: %v", err)
+	}
+	if err := repo.SetCachedDictionary(ctx, "en", "give up", []byte(`{"word":"give up"}`), nil, "phrases", time.Hour); err != nil {
+		t.Fatalf("SetCachedDictionary failed: %v", err)
+	}
+	if err := repo.SetCachedDictionary(ctx, "de", "haus", []byte(`{"word":"haus"}`), []byte(`{"de":[]}`), "wiktionary", time.Hour); err != nil {
+		t.Fatalf("SetCachedDictionary failed: %v", err)
+	}
+
+	// Stored compressed, read back as fetched
+	var stored []byte
+	if err := pool.QueryRow(ctx, `SELECT raw FROM dictionary_cache WHERE word = 'bass'`).Scan(&stored); err != nil {
+		t.Fatalf("failed to read raw column: %v", err)
+	}
+	if bytes.Equal(stored, raw) || len(stored) < 2 || stored[0] != 0x1f || stored[1] != 0x8b {
+		t.Errorf("expected a gzipped payload, got %q", stored)
+	}
+	row, err := repo.GetCacheRow(ctx, "en", "bass")
+	if err != nil || !bytes.Equal(row.Raw, raw) {
+		t.Errorf("expected the payload back, got %q, %v", row.Raw, err)
+	}
+	if row, err := repo.GetCacheRow(ctx, "en", "give up"); err != nil || row.Raw != nil {
+		t.Errorf("expected no payload for a local source, got %q, %v", row.Raw, err)
+	}
+
+	rows, err := repo.GetRawCacheRows(ctx, CacheFilter{}, WordKey{}, 10)
+	if err != nil || len(rows) != 2 || rows[0].Word != "haus" || rows[1].Word != "bass" {
+		t.Fatalf("expected the rows with payloads, got %+v, %v", rows, err)
+	}
+	rows, err = repo.GetRawCacheRows(ctx, CacheFilter{Source: "freedictionaryapi"}, WordKey{}, 10)
+	if err != nil || len(rows) != 1 || !bytes.Equal(rows[0].Raw, raw) {
+		t.Errorf("expected bass for its source, got %+v, %v", rows, err)
+	}
+	if rows, _ := repo.GetRawCacheRows(ctx, CacheFilter{}, WordKey{Language: "de", Word: "haus"}, 10); len(rows) != 1 || rows[0].Word != "bass" {
+		t.Errorf("expected bass after haus, got %+v", rows)
+	}
+
+	entries, _, err := repo.GetCacheEntries(ctx, CacheFilter{Word: "bass", Language: "en"}, 10, 0)
+	if err != nil || len(entries) != 1 || entries[0].RawSize != len(stored) {
+		t.Errorf("expected the compressed payload size, got %+v, %v", entries, err)
+	}
+}
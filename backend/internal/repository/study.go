@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+)
+
+const defaultTimeZone = "UTC"
+
+// Review operations
+
+// RecordReview stores the new schedule of a wordbook entry and appends the
+// review to the review log in a single transaction.
+func (r *Repository) RecordReview(ctx context.Context, userID, language, word string, grade int, state models.ReviewState) (*models.WordbookEntry, error) {
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE wordbook_entries
+		SET ease = $4, interval_days = $5, repetitions = $6, due_at = $7
+		WHERE user_id = $1 AND language = $2 AND word = $3
+		RETURNING ` + wordbookColumns
+
+	entry, err := scanWordbookEntry(tx.QueryRow(ctx, query, userID, language, word,
+		state.Ease, state.IntervalDays, state.Repetitions, state.DueAt))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logQuery := `
+		INSERT INTO review_logs (user_id, language, word, grade, interval_days)
+		VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(ctx, logQuery, userID, language, word, grade, state.IntervalDays); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Settings operations
+
+// GetUserSettings returns the settings of a user, falling back to defaults
+// when the user has never saved any.
+func (r *Repository) GetUserSettings(ctx context.Context, userID string) (*models.UserSettings, error) {
+	query := `
+		SELECT user_id, time_zone, translation_language, updated_at
+		FROM user_settings
+		WHERE user_id = $1`
+
+	var settings models.UserSettings
+	err := r.db.QueryRow(ctx, query, userID).Scan(&settings.UserID, &settings.TimeZone, &settings.TranslationLanguage, &settings.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return &models.UserSettings{UserID: userID, TimeZone: defaultTimeZone}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (r *Repository) SetUserTimeZone(ctx context.Context, userID, timeZone string) (*models.UserSettings, error) {
+	query := `
+		INSERT INTO user_settings (user_id, time_zone, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			time_zone = EXCLUDED.time_zone,
+			updated_at = EXCLUDED.updated_at
+		RETURNING user_id, time_zone, translation_language, updated_at`
+
+	var settings models.UserSettings
+	err := r.db.QueryRow(ctx, query, userID, timeZone).Scan(&settings.UserID, &settings.TimeZone, &settings.TranslationLanguage, &settings.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (r *Repository) SetUserTranslationLanguage(ctx context.Context, userID, language string) (*models.UserSettings, error) {
+	query := `
+		INSERT INTO user_settings (user_id, time_zone, translation_language, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			translation_language = EXCLUDED.translation_language,
+			updated_at = EXCLUDED.updated_at
+		RETURNING user_id, time_zone, translation_language, updated_at`
+
+	var settings models.UserSettings
+	err := r.db.QueryRow(ctx, query, userID, defaultTimeZone, language).Scan(&settings.UserID, &settings.TimeZone, &settings.TranslationLanguage, &settings.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// Reminder operations
+
+// CreateReviewReminders records, for each user with reviews due, a reminder
+// for the current day in their time zone, and returns the number of
+// reminders created or updated. A day's reminder keeps its count up to date
+// until the user dismisses it.
+func (r *Repository) CreateReviewReminders(ctx context.Context) (int64, error) {
+	query := `
+		INSERT INTO review_reminders (user_id, day, due_count)
+		SELECT w.user_id, (NOW() AT TIME ZONE COALESCE(s.time_zone, $1))::date, COUNT(*)
+		FROM wordbook_entries w
+		LEFT JOIN user_settings s ON s.user_id = w.user_id
+		WHERE w.due_at <= NOW()
+		GROUP BY w.user_id, s.time_zone
+		ON CONFLICT (user_id, day) DO UPDATE SET due_count = EXCLUDED.due_count
+		WHERE review_reminders.dismissed_at IS NULL`
+
+	tag, err := r.db.Exec(ctx, query, defaultTimeZone)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetReviewReminders returns the reminders the user hasn't dismissed, newest
+// first.
+func (r *Repository) GetReviewReminders(ctx context.Context, userID string) ([]models.ReviewReminder, error) {
+	query := `
+		SELECT id, user_id, day::text, due_count, created_at
+		FROM review_reminders
+		WHERE user_id = $1 AND dismissed_at IS NULL
+		ORDER BY day DESC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []models.ReviewReminder
+	for rows.Next() {
+		var reminder models.ReviewReminder
+		if err := rows.Scan(&reminder.ID, &reminder.UserID, &reminder.Day, &reminder.DueCount, &reminder.CreatedAt); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
+
+// DismissReviewReminders dismisses all of the user's reminders.
+func (r *Repository) DismissReviewReminders(ctx context.Context, userID string) error {
+	query := `UPDATE review_reminders SET dismissed_at = NOW() WHERE user_id = $1 AND dismissed_at IS NULL`
+	_, err := r.db.Exec(ctx, query, userID)
+	return err
+}
+
+// Statistics operations
+//
+// Day buckets are computed by Postgres in the given IANA time zone, so a
+// review at 23:30 local time counts towards that local day regardless of UTC.
+
+// CountWordsAddedPerDay returns the number of wordbook entries created on each
+// local day since the given instant. Days without entries are omitted.
+func (r *Repository) CountWordsAddedPerDay(ctx context.Context, userID, timeZone string, since time.Time) ([]models.DailyCount, error) {
+	query := `
+		SELECT (created_at AT TIME ZONE $2)::date AS day, COUNT(*)
+		FROM wordbook_entries
+		WHERE user_id = $1 AND created_at >= $3
+		GROUP BY day
+		ORDER BY day`
+
+	return r.queryDailyCounts(ctx, query, userID, timeZone, since)
+}
+
+// CountReviewsPerDay returns the number of reviews logged on each local day
+// since the given instant. Days without reviews are omitted.
+func (r *Repository) CountReviewsPerDay(ctx context.Context, userID, timeZone string, since time.Time) ([]models.DailyCount, error) {
+	query := `
+		SELECT (reviewed_at AT TIME ZONE $2)::date AS day, COUNT(*)
+		FROM review_logs
+		WHERE user_id = $1 AND reviewed_at >= $3
+		GROUP BY day
+		ORDER BY day`
+
+	return r.queryDailyCounts(ctx, query, userID, timeZone, since)
+}
+
+// CountDueForecast returns the number of entries falling due on each local day
+// up to (but excluding) the given instant. Overdue entries are counted on the
+// first day of the forecast.
+func (r *Repository) CountDueForecast(ctx context.Context, userID, timeZone string, today, until time.Time) ([]models.DailyCount, error) {
+	query := `
+		SELECT GREATEST((due_at AT TIME ZONE $2)::date, $3::date) AS day, COUNT(*)
+		FROM wordbook_entries
+		WHERE user_id = $1 AND due_at < $4
+		GROUP BY day
+		ORDER BY day`
+
+	return r.queryDailyCounts(ctx, query, userID, timeZone, today, until)
+}
+
+// GetActivityDays returns every distinct local day on which the user added a
+// word or reviewed one, in ascending order.
+func (r *Repository) GetActivityDays(ctx context.Context, userID, timeZone string) ([]time.Time, error) {
+	query := `
+		SELECT (created_at AT TIME ZONE $2)::date AS day FROM wordbook_entries WHERE user_id = $1
+		UNION
+		SELECT (reviewed_at AT TIME ZONE $2)::date AS day FROM review_logs WHERE user_id = $1
+		ORDER BY day`
+
+	rows, err := r.db.Query(ctx, query, userID, timeZone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
+
+// GetReviewTotals returns the total number of reviews and how many of them
+// were recalled successfully (grade 3 or higher).
+func (r *Repository) GetReviewTotals(ctx context.Context, userID string) (total, recalled int, err error) {
+	query := `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE grade >= 3)
+		FROM review_logs
+		WHERE user_id = $1`
+
+	err = r.db.QueryRow(ctx, query, userID).Scan(&total, &recalled)
+	return total, recalled, err
+}
+
+// CountWordbookEntries returns the number of entries in a user's wordbook.
+func (r *Repository) CountWordbookEntries(ctx context.Context, userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM wordbook_entries WHERE user_id = $1`
+	var count int
+	err := r.db.QueryRow(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// CountPartsOfSpeech groups a user's wordbook by the primary part of speech of
+// each word, as recorded in the entry's snapshot or, for entries saved without
+// one, the dictionary cache.
+func (r *Repository) CountPartsOfSpeech(ctx context.Context, userID string) ([]models.PartOfSpeechCount, error) {
+	query := `
+		SELECT COALESCE(
+			NULLIF(w.snapshot->'meanings'->0->>'partOfSpeech', ''),
+			NULLIF(c.data->'meanings'->0->>'partOfSpeech', ''),
+			'unknown') AS pos, COUNT(*)
+		FROM wordbook_entries w
+		LEFT JOIN dictionary_cache c ON c.language = w.language AND c.word = w.word
+		WHERE w.user_id = $1
+		GROUP BY pos
+		ORDER BY COUNT(*) DESC, pos`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.PartOfSpeechCount
+	for rows.Next() {
+		var c models.PartOfSpeechCount
+		if err := rows.Scan(&c.PartOfSpeech, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+func (r *Repository) queryDailyCounts(ctx context.Context, query string, args ...any) ([]models.DailyCount, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.DailyCount
+	for rows.Next() {
+		var day time.Time
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, models.DailyCount{Date: day.Format(time.DateOnly), Count: count})
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+)
+
+// Import and export operations
+
+// StreamWordbookEntries calls fn for each of a user's entries, oldest first,
+// with snapshot and contexts populated. Rows are read from a single cursor so
+// large wordbooks are never held in memory at once.
+func (r *Repository) StreamWordbookEntries(ctx context.Context, userID string, fn func(*models.WordbookEntry) error) error {
+	query := `
+		SELECT ` + wordbookColumns + `, snapshot,
+			COALESCE((
+				SELECT json_agg(json_build_object(
+					'id', c.id,
+					'sentence', c.sentence,
+					'source_title', c.source_title,
+					'source_url', c.source_url,
+					'location', c.location,
+					'created_at', c.created_at
+				) ORDER BY c.created_at, c.id)
+				FROM wordbook_contexts c
+				WHERE c.entry_id = wordbook_entries.id
+			), '[]')
+		FROM wordbook_entries
+		WHERE user_id = $1
+		ORDER BY created_at, id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snapshot, contexts []byte
+		entry, err := scanWordbookEntry(rows, &snapshot, &contexts)
+		if err != nil {
+			return err
+		}
+
+		if snapshot != nil {
+			entry.Snapshot = &models.DictionaryEntry{}
+			if err := json.Unmarshal(snapshot, entry.Snapshot); err != nil {
+				return fmt.Errorf("failed to unmarshal snapshot of %q: %w", entry.Word, err)
+			}
+		}
+		if err := json.Unmarshal(contexts, &entry.Contexts); err != nil {
+			return fmt.Errorf("failed to unmarshal contexts of %q: %w", entry.Word, err)
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ImportWordbookEntries applies already-validated entries in one transaction,
+// resolving words that already exist according to policy. With dryRun set the
+// transaction is rolled back, so the returned results describe what would
+// have happened without changing anything.
+func (r *Repository) ImportWordbookEntries(ctx context.Context, userID string, entries []models.WordbookEntry, policy string, dryRun bool) ([]string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	actions := make([]string, len(entries))
+	for i := range entries {
+		incoming := &entries[i]
+
+		existing, err := getWordbookEntry(ctx, tx, userID, incoming.Language, incoming.Word, true)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+
+		target, replaceContexts := incoming, false
+		switch {
+		case existing == nil:
+			actions[i] = models.ImportActionCreated
+		case policy == models.ImportOverwrite:
+			actions[i] = models.ImportActionOverwritten
+			replaceContexts = true
+		case policy == models.ImportMerge:
+			actions[i] = models.ImportActionMerged
+			existing.MergeFrom(incoming)
+			target = existing
+		default:
+			actions[i] = models.ImportActionSkipped
+			continue
+		}
+
+		if err := writeImportedEntry(ctx, tx, userID, target, replaceContexts); err != nil {
+			return nil, fmt.Errorf("row %d (%s): %w", i+1, incoming.Word, err)
+		}
+	}
+
+	if dryRun {
+		return actions, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// writeImportedEntry stores every field of e, including review state, and
+// inserts its contexts that have no ID yet. With replaceContexts set, the
+// entry's existing contexts are removed first.
+func writeImportedEntry(ctx context.Context, tx pgx.Tx, userID string, e *models.WordbookEntry, replaceContexts bool) error {
+	word, err := normalize.Word(e.Language, e.Word)
+	if err != nil {
+		return err
+	}
+
+	var snapshot []byte
+	if e.Snapshot != nil {
+		if snapshot, err = json.Marshal(e.Snapshot); err != nil {
+			return fmt.Errorf("failed to marshal snapshot: %w", err)
+		}
+	}
+
+	var meaning, definition *int
+	if e.SelectedSense != nil {
+		meaning, definition = &e.SelectedSense.MeaningIndex, &e.SelectedSense.DefinitionIndex
+	}
+
+	tags := e.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	// Zero review state and timestamps mean the source had none: new rows get
+	// the column defaults and existing rows keep their current values.
+	var ease *float64
+	var intervalDays, repetitions *int
+	if e.Ease != 0 {
+		ease, intervalDays, repetitions = &e.Ease, &e.IntervalDays, &e.Repetitions
+	}
+	var createdAt, dueAt *time.Time
+	if !e.CreatedAt.IsZero() {
+		createdAt = &e.CreatedAt
+	}
+	if !e.DueAt.IsZero() {
+		dueAt = &e.DueAt
+	}
+
+	query := `
+		INSERT INTO wordbook_entries (user_id, word, short_definition, notes, tags, snapshot,
+			selected_meaning, selected_definition, ease, interval_days, repetitions, due_at, created_at,
+			frequency_rank, zipf, cefr_level, language, selected_homograph)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+			COALESCE($9::double precision, 2.5), COALESCE($10::integer, 0), COALESCE($11::integer, 0),
+			COALESCE($12::timestamptz, NOW()), COALESCE($13::timestamptz, NOW()), $14, $15, $16, $17, $18)
+		ON CONFLICT (user_id, language, word) DO UPDATE SET
+			frequency_rank = EXCLUDED.frequency_rank,
+			zipf = EXCLUDED.zipf,
+			cefr_level = EXCLUDED.cefr_level,
+			short_definition = EXCLUDED.short_definition,
+			notes = EXCLUDED.notes,
+			tags = EXCLUDED.tags,
+			snapshot = EXCLUDED.snapshot,
+			selected_meaning = EXCLUDED.selected_meaning,
+			selected_definition = EXCLUDED.selected_definition,
+			selected_homograph = EXCLUDED.selected_homograph,
+			ease = COALESCE($9, wordbook_entries.ease),
+			interval_days = COALESCE($10, wordbook_entries.interval_days),
+			repetitions = COALESCE($11, wordbook_entries.repetitions),
+			due_at = COALESCE($12, wordbook_entries.due_at),
+			created_at = COALESCE($13, wordbook_entries.created_at)
+		RETURNING id`
+
+	var entryID int64
+	err = tx.QueryRow(ctx, query, userID, word, e.ShortDefinition, e.Notes, tags, snapshot,
+		meaning, definition, ease, intervalDays, repetitions, dueAt, createdAt,
+		e.FrequencyRank, e.Zipf, e.CEFRLevel, e.Language, e.SelectedHomograph).Scan(&entryID)
+	if err != nil {
+		return err
+	}
+
+	if replaceContexts {
+		if _, err := tx.Exec(ctx, `DELETE FROM wordbook_contexts WHERE entry_id = $1`, entryID); err != nil {
+			return err
+		}
+	}
+	for i := range e.Contexts {
+		wc := &e.Contexts[i]
+		if wc.ID != 0 && !replaceContexts {
+			continue
+		}
+		if err := addWordContext(ctx, tx, entryID, wc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
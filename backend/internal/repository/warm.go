@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+const warmRunColumns = `id, language, cardinality(words), position, fetched, cached, not_found,
+	failed, last_error, status, started_at, updated_at, ended_at`
+
+// Cache warming operations
+
+// CreateWarmRun records a new cache warming run over words.
+func (r *Repository) CreateWarmRun(ctx context.Context, language string, words []string) (*models.WarmRun, error) {
+	query := `
+		INSERT INTO cache_warm_runs (language, words, status)
+		VALUES ($1, $2, $3)
+		RETURNING ` + warmRunColumns
+	return scanWarmRun(r.db.QueryRow(ctx, query, language, words, models.WarmStatusRunning))
+}
+
+// GetWarmRun returns a cache warming run, or ErrNotFound.
+func (r *Repository) GetWarmRun(ctx context.Context, id int64) (*models.WarmRun, error) {
+	query := `SELECT ` + warmRunColumns + ` FROM cache_warm_runs WHERE id = $1`
+	run, err := scanWarmRun(r.db.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return run, err
+}
+
+// GetWarmRunWords returns the word list of a cache warming run from the
+// given position on.
+func (r *Repository) GetWarmRunWords(ctx context.Context, id int64, from int) ([]string, error) {
+	var words []string
+	// Postgres arrays are 1-based
+	err := r.db.QueryRow(ctx, `SELECT COALESCE(words[$2:], '{}') FROM cache_warm_runs WHERE id = $1`, id, from+1).Scan(&words)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return words, err
+}
+
+// GetWarmRuns returns up to limit cache warming runs, newest first.
+func (r *Repository) GetWarmRuns(ctx context.Context, limit int) ([]models.WarmRun, error) {
+	query := `SELECT ` + warmRunColumns + ` FROM cache_warm_runs ORDER BY id DESC LIMIT $1`
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.WarmRun
+	for rows.Next() {
+		run, err := scanWarmRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+
+	return runs, rows.Err()
+}
+
+// SaveWarmRunProgress stores the progress and status of a cache warming
+// run. A run is marked ended once it is no longer running.
+func (r *Repository) SaveWarmRunProgress(ctx context.Context, run *models.WarmRun) error {
+	if run.NotFound == nil {
+		run.NotFound = []string{}
+	}
+
+	query := `
+		UPDATE cache_warm_runs SET
+			position = $2, fetched = $3, cached = $4, not_found = $5, failed = $6,
+			last_error = $7, status = $8, updated_at = NOW(),
+			ended_at = CASE WHEN $8 = '` + models.WarmStatusRunning + `' THEN NULL ELSE NOW() END
+		WHERE id = $1
+		RETURNING updated_at, ended_at`
+
+	return r.db.QueryRow(ctx, query, run.ID, run.Position, run.Fetched, run.Cached, run.NotFound,
+		run.Failed, run.LastError, run.Status).Scan(&run.UpdatedAt, &run.EndedAt)
+}
+
+func scanWarmRun(row pgx.Row) (*models.WarmRun, error) {
+	var run models.WarmRun
+	err := row.Scan(&run.ID, &run.Language, &run.Total, &run.Position, &run.Fetched, &run.Cached, &run.NotFound,
+		&run.Failed, &run.LastError, &run.Status, &run.StartedAt, &run.UpdatedAt, &run.EndedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
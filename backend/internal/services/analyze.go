@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+	"github.com/warriorguo/vocabulary/internal/repository"
+)
+
+const (
+	defaultCandidateLimit = 100
+	// maxContextLength caps the bytes of sentence kept as a candidate's
+	// context, so a run-on paragraph doesn't become the "sentence".
+	maxContextLength = 400
+)
+
+var (
+	tokenPattern      = regexp.MustCompile(`\p{L}+(?:['’]\p{L}+)*`)
+	paragraphPattern  = regexp.MustCompile(`\n\s*\n`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// AnalyzeService harvests unknown words from texts
+type AnalyzeService struct {
+	repo  *repository.Repository
+	freq  *FrequencyList
+	morph *Morphology
+	known map[string]bool
+}
+
+// NewAnalyzeService creates an analyzer that ranks words by freq and treats
+// the words in known, along with each user's wordbook, as already learned.
+func NewAnalyzeService(repo *repository.Repository, freq *FrequencyList, known []string) *AnalyzeService {
+	s := &AnalyzeService{repo: repo, freq: freq, morph: DefaultMorphology(), known: make(map[string]bool, len(known))}
+	for _, word := range known {
+		s.known[word] = true
+	}
+	return s
+}
+
+// Analyze returns up to limit words of doc that are neither known nor in the
+// user's wordbook, rarest first.
+func (s *AnalyzeService) Analyze(ctx context.Context, userID string, doc *Document, limit int) (*models.TextAnalysis, error) {
+	if limit <= 0 {
+		limit = defaultCandidateLimit
+	}
+
+	words, err := s.repo.GetWordbookWords(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	inWordbook := make(map[string]bool, len(words))
+	for _, word := range words {
+		inWordbook[strings.ToLower(word)] = true
+	}
+
+	isWord := func(w string) bool { return s.freq.Contains(w) || s.known[w] || inWordbook[w] }
+	tokens, found := collectWords(doc.Text, func(w string) string { return s.morph.Lemma(w, isWord) })
+
+	analysis := &models.TextAnalysis{
+		Title:       doc.Title,
+		TotalTokens: tokens,
+		UniqueWords: len(found),
+		Candidates:  []models.WordCandidate{},
+	}
+
+	for _, fw := range found {
+		if s.known[fw.lemma] || inWordbook[fw.lemma] {
+			analysis.KnownWords++
+			continue
+		}
+		if fw.lower == 0 && fw.capitalizedMid > 0 {
+			// Only ever capitalized mid-sentence: almost certainly a name
+			continue
+		}
+
+		forms := make([]string, 0, len(fw.forms))
+		for form := range fw.forms {
+			forms = append(forms, form)
+		}
+		sort.Strings(forms)
+
+		analysis.Candidates = append(analysis.Candidates, models.WordCandidate{
+			Word:          fw.lemma,
+			Forms:         forms,
+			Count:         fw.count,
+			FrequencyRank: s.freq.Rank(fw.lemma),
+			Context:       models.WordContext{Sentence: fw.sentence, SourceTitle: doc.Title},
+		})
+	}
+
+	rankCandidates(analysis.Candidates)
+	if len(analysis.Candidates) > limit {
+		analysis.Candidates = analysis.Candidates[:limit]
+	}
+	return analysis, nil
+}
+
+// rankCandidates orders candidates rarest first. Words missing from the
+// frequency list count as rarer than any listed word; ties go to the word
+// that occurs more often in the text.
+func rankCandidates(candidates []models.WordCandidate) {
+	rarity := func(c models.WordCandidate) int {
+		if c.FrequencyRank == 0 {
+			return math.MaxInt
+		}
+		return c.FrequencyRank
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if ra, rb := rarity(a), rarity(b); ra != rb {
+			return ra > rb
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Word < b.Word
+	})
+}
+
+// foundWord tallies the occurrences of one lemma in a text
+type foundWord struct {
+	lemma          string
+	forms          map[string]bool
+	count          int
+	lower          int
+	capitalizedMid int
+	sentence       string
+}
+
+// collectWords tokenizes text and groups the tokens by lemma, in order of
+// first appearance. It returns the number of tokens along with the groups.
+func collectWords(text string, lemma func(string) string) (int, []*foundWord) {
+	var tokens int
+	var order []*foundWord
+	byLemma := make(map[string]*foundWord)
+
+	for _, sentence := range splitSentences(text) {
+		for i, loc := range tokenPattern.FindAllStringIndex(sentence, -1) {
+			raw := sentence[loc[0]:loc[1]]
+			word, err := normalize.Word(DefaultLanguage, raw)
+			if err != nil {
+				continue
+			}
+			word = strings.TrimSuffix(word, "'s")
+			if strings.Contains(word, "'") || utf8.RuneCountInString(word) < 2 {
+				// Contractions are function words; single letters aren't vocabulary
+				continue
+			}
+			tokens++
+
+			base := lemma(word)
+			fw, ok := byLemma[base]
+			if !ok {
+				fw = &foundWord{lemma: base, forms: make(map[string]bool), sentence: contextWindow(sentence, loc[0], loc[1])}
+				byLemma[base] = fw
+				order = append(order, fw)
+			}
+			fw.count++
+			fw.forms[word] = true
+
+			first, _ := utf8.DecodeRuneInString(raw)
+			switch {
+			case !unicode.IsUpper(first):
+				fw.lower++
+			case i > 0:
+				fw.capitalizedMid++
+			}
+		}
+	}
+
+	return tokens, order
+}
+
+// splitSentences breaks text into paragraphs at blank lines and paragraphs
+// into sentences at terminal punctuation followed by whitespace. Whitespace
+// within a sentence is collapsed to single spaces.
+func splitSentences(text string) []string {
+	var sentences []string
+	for _, para := range paragraphPattern.Split(text, -1) {
+		para = strings.TrimSpace(whitespacePattern.ReplaceAllString(para, " "))
+
+		start := 0
+		for i := 0; i < len(para); i++ {
+			switch para[i] {
+			case '.', '!', '?':
+				end := i + 1
+				for end < len(para) && strings.ContainsRune(`.!?"')]`, rune(para[end])) {
+					end++
+				}
+				if end < len(para) && para[end] != ' ' {
+					continue
+				}
+				if s := strings.TrimSpace(para[start:end]); s != "" {
+					sentences = append(sentences, s)
+				}
+				start, i = end, end
+			}
+		}
+		if s := strings.TrimSpace(para[start:]); s != "" {
+			sentences = append(sentences, s)
+		}
+	}
+	return sentences
+}
+
+// contextWindow returns sentence, or for overlong sentences the part of it
+// around the token at [start, end), cut at word boundaries.
+func contextWindow(sentence string, start, end int) string {
+	if len(sentence) <= maxContextLength {
+		return sentence
+	}
+
+	margin := (maxContextLength - (end - start)) / 2
+	from, to := max(0, start-margin), min(len(sentence), end+margin)
+	if i := strings.IndexByte(sentence[from:start], ' '); from > 0 && i >= 0 {
+		from += i + 1
+	}
+	if i := strings.LastIndexByte(sentence[end:to], ' '); to < len(sentence) && i >= 0 {
+		to = end + i
+	}
+
+	window := sentence[from:to]
+	if from > 0 {
+		window = "…" + window
+	}
+	if to < len(sentence) {
+		window += "…"
+	}
+	return window
+}
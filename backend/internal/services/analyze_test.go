@@ -0,0 +1,115 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestSplitSentences(t *testing.T) {
+	text := "It was late.  \"Who's there?\" she asked.\nNobody answered\n\nA new paragraph"
+	want := []string{
+		"It was late.",
+		"\"Who's there?\"",
+		"she asked.",
+		"Nobody answered",
+		"A new paragraph",
+	}
+	if got := splitSentences(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCollectWords(t *testing.T) {
+	text := "Ishmael watched the whales. The whale's breath was ephemeral. Ishmael's whale dived."
+	lemma := func(w string) string { return strings.TrimSuffix(w, "s") }
+
+	tokens, found := collectWords(text, lemma)
+	if tokens != 12 {
+		t.Errorf("expected 12 tokens, got %d", tokens)
+	}
+
+	byLemma := make(map[string]*foundWord)
+	for _, fw := range found {
+		byLemma[fw.lemma] = fw
+	}
+
+	whale := byLemma["whale"]
+	if whale == nil || whale.count != 3 {
+		t.Fatalf("expected whale counted 3 times, got %+v", whale)
+	}
+	if whale.sentence != "Ishmael watched the whales." {
+		t.Errorf("context should be the first sentence, got %q", whale.sentence)
+	}
+	if !whale.forms["whales"] || !whale.forms["whale"] {
+		t.Errorf("expected both forms, got %v", whale.forms)
+	}
+
+	ishmael := byLemma["ishmael"]
+	if ishmael.lower != 0 || ishmael.capitalizedMid != 0 {
+		t.Errorf("sentence-initial capitals aren't evidence of a name: %+v", ishmael)
+	}
+	if byLemma["the"].lower != 1 {
+		t.Errorf("expected one lowercase 'the', got %+v", byLemma["the"])
+	}
+}
+
+func TestRankCandidates(t *testing.T) {
+	candidates := []models.WordCandidate{
+		{Word: "common", FrequencyRank: 10, Count: 5},
+		{Word: "unlisted", Count: 1},
+		{Word: "rare", FrequencyRank: 3000, Count: 1},
+		{Word: "frequent", Count: 4},
+	}
+	rankCandidates(candidates)
+
+	var got []string
+	for _, c := range candidates {
+		got = append(got, c.Word)
+	}
+	want := []string{"frequent", "unlisted", "rare", "common"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	long := strings.Repeat("word ", 200) + "target " + strings.Repeat("word ", 200)
+	start := strings.Index(long, "target")
+
+	window := contextWindow(long, start, start+len("target"))
+	if len(window) > maxContextLength+2*len("…") {
+		t.Errorf("window too long: %d bytes", len(window))
+	}
+	if !strings.Contains(window, "target") || !strings.HasPrefix(window, "…word") || !strings.HasSuffix(window, "word…") {
+		t.Errorf("unexpected window %q", window)
+	}
+}
+
+func TestDefaultFrequencyList(t *testing.T) {
+	freq := DefaultFrequencyList()
+	if freq.Rank("the") != 1 {
+		t.Errorf("expected 'the' to rank first, got %d", freq.Rank("the"))
+	}
+	if freq.Len() < 1000 {
+		t.Errorf("bundled list looks truncated: %d words", freq.Len())
+	}
+	if freq.Contains("ephemeral") {
+		t.Error("rare words should not be in the bundled list")
+	}
+}
+
+func TestFrequencyZipf(t *testing.T) {
+	freq := DefaultFrequencyList()
+	if z := freq.Zipf("the"); z < 7 || z > 8 {
+		t.Errorf("expected 'the' to score about 7.7, got %v", z)
+	}
+	if freq.Zipf("the") <= freq.Zipf("house") {
+		t.Error("expected more frequent words to score higher")
+	}
+	if z := freq.Zipf("ephemeral"); z != 0 {
+		t.Errorf("expected unlisted words to score 0, got %v", z)
+	}
+}
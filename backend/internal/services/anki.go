@@ -0,0 +1,476 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	FormatAnki = "apkg"
+
+	// ankiModelID and ankiDeckID are fixed so that every export refers to the
+	// same note type and deck, letting Anki match them on re-import.
+	ankiModelID   int64 = 1700000000001
+	ankiDeckID    int64 = 1700000000002
+	ankiModelName       = "Vocabulary Word"
+	ankiDeckName        = "Vocabulary"
+
+	ankiFieldSeparator = "\x1f"
+	ankiGUIDAlphabet   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&()*+,-./:;<=>?@[]^_`{|}~"
+)
+
+// ankiFields is the field layout of the exported note type, in order
+var ankiFields = []string{"Word", "IPA", "Definitions", "Examples", "Notes", "Audio"}
+
+// ankiAudioField is the index of the field playing a word's recording
+const ankiAudioField = 5
+
+// ankiAudioExtensions maps the media types of stored recordings to the file
+// extension Anki needs to play them
+var ankiAudioExtensions = map[string]string{
+	"audio/mpeg": ".mp3",
+	"audio/ogg":  ".ogg",
+	"audio/wav":  ".wav",
+	"audio/mp4":  ".m4a",
+	"audio/webm": ".webm",
+}
+
+// AnkiNote is a single note of an Anki deck
+type AnkiNote struct {
+	GUID   string
+	Fields []string // one value per ankiFields entry, already HTML
+	Tags   []string
+}
+
+// AnkiMedia is a media file bundled in an Anki package
+type AnkiMedia struct {
+	Name string
+	Data []byte
+}
+
+// ExportAnki writes the user's wordbook as an Anki package. Definitions and
+// phonetics come from each entry's stored snapshot, falling back to the
+// dictionary cache; entries with neither carry only their short definition.
+// Recordings already in the audio store are bundled with the notes; the
+// export never downloads or synthesizes audio.
+func (s *TransferService) ExportAnki(ctx context.Context, userID string, w io.Writer) error {
+	var entries []*models.WordbookEntry
+	err := s.repo.StreamWordbookEntries(ctx, userID, func(e *models.WordbookEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	notes := make([]AnkiNote, 0, len(entries))
+	var media []AnkiMedia
+	bundled := make(map[string]bool)
+	for _, e := range entries {
+		// The card shows only the pinned homograph
+		dict := e.SelectedSnapshot()
+		if dict == nil {
+			dict = s.cachedEntry(ctx, e.Language, e.Word)
+		}
+		note := AnkiNoteFromEntry(userID, e, dict)
+
+		if m := s.storedAudio(ctx, e.Language, e.Word); m != nil {
+			note.Fields[ankiAudioField] = "[sound:" + m.Name + "]"
+			// Identical recordings are stored, and bundled, once
+			if !bundled[m.Name] {
+				bundled[m.Name] = true
+				media = append(media, *m)
+			}
+		}
+		notes = append(notes, note)
+	}
+
+	return WriteAnkiPackage(w, ankiDeckName, notes, media)
+}
+
+// storedAudio returns the recording of word the audio proxy serves by
+// default, or nil when none is stored or it can't be read.
+func (s *TransferService) storedAudio(ctx context.Context, language, word string) *AnkiMedia {
+	if s.audio == nil {
+		return nil
+	}
+	f, err := s.repo.GetAudioFile(ctx, language, word, "")
+	if err != nil || f == nil {
+		return nil
+	}
+	name := ankiAudioName(f)
+	if name == "" {
+		return nil
+	}
+
+	file, err := s.audio.Open(f.Checksum)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil
+	}
+	return &AnkiMedia{Name: name, Data: data}
+}
+
+// ankiAudioName names a recording in an Anki package after its checksum, or
+// returns "" for a media type Anki can't be told how to play.
+func ankiAudioName(f *models.AudioFile) string {
+	ext, ok := ankiAudioExtensions[f.ContentType]
+	if !ok || f.Checksum == "" {
+		return ""
+	}
+	return f.Checksum + ext
+}
+
+// cachedEntry returns the cached dictionary entry for word, or nil when it
+// isn't cached or can't be read. It never calls the upstream API.
+func (s *TransferService) cachedEntry(ctx context.Context, language, word string) *models.DictionaryEntry {
+	cached, err := s.repo.GetCachedDictionary(ctx, language, word)
+	if err != nil || cached == nil {
+		return nil
+	}
+
+	entry, _, err := entryCodec.decode(cached)
+	if err != nil {
+		return nil
+	}
+	return entry
+}
+
+// AnkiNoteFromEntry converts a wordbook entry into an Anki note, taking IPA,
+// definitions and examples from dict when given. The GUID is derived from the
+// user and word, so exporting the same word again updates the existing note
+// in Anki instead of adding a duplicate.
+func AnkiNoteFromEntry(userID string, e *models.WordbookEntry, dict *models.DictionaryEntry) AnkiNote {
+	fields := make([]string, len(ankiFields))
+	fields[0] = html.EscapeString(e.Word)
+	fields[4] = strings.ReplaceAll(html.EscapeString(e.Notes), "\n", "<br>")
+
+	if dict != nil {
+		fillAnkiFields(fields, dict)
+	}
+	if fields[2] == "" {
+		fields[2] = html.EscapeString(e.ShortDefinition)
+	}
+
+	tags := make([]string, 0, len(e.Tags))
+	for _, tag := range e.Tags {
+		// Anki tags are space separated
+		tags = append(tags, strings.ReplaceAll(tag, " ", "_"))
+	}
+
+	return AnkiNote{GUID: ankiNoteGUID(userID, e), Fields: fields, Tags: tags}
+}
+
+// ankiNoteGUID returns the GUID of an entry's note. English notes keep the
+// key they had before entries had a language, so decks imported earlier are
+// updated rather than duplicated.
+func ankiNoteGUID(userID string, e *models.WordbookEntry) string {
+	if e.Language == "" || e.Language == DefaultLanguage {
+		return ankiGUID(userID + "\x00" + e.Word)
+	}
+	return ankiGUID(userID + "\x00" + e.Language + "\x00" + e.Word)
+}
+
+// fillAnkiFields sets the IPA, definitions and examples fields from a
+// dictionary entry.
+func fillAnkiFields(fields []string, entry *models.DictionaryEntry) {
+	for _, p := range entry.Phonetics {
+		if p.Text != "" {
+			fields[1] = html.EscapeString(p.Text)
+			break
+		}
+	}
+
+	var defs, examples strings.Builder
+	for _, m := range entry.Meanings {
+		if len(m.Definitions) == 0 {
+			continue
+		}
+		fmt.Fprintf(&defs, "<div><i>%s</i><ol>", html.EscapeString(m.PartOfSpeech))
+		for _, d := range m.Definitions {
+			fmt.Fprintf(&defs, "<li>%s</li>", html.EscapeString(d.Definition))
+			if d.Example != "" {
+				fmt.Fprintf(&examples, "<li>%s</li>", html.EscapeString(d.Example))
+			}
+		}
+		defs.WriteString("</ol></div>")
+	}
+
+	if defs.Len() > 0 {
+		fields[2] = defs.String()
+	}
+	if examples.Len() > 0 {
+		fields[3] = "<ul>" + examples.String() + "</ul>"
+	}
+}
+
+// ankiGUID hashes key into a 10-character base-91 string, the same shape
+// Anki uses for the GUIDs it generates itself.
+func ankiGUID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	n := binary.BigEndian.Uint64(sum[:8])
+
+	base := uint64(len(ankiGUIDAlphabet))
+	guid := make([]byte, 0, 10)
+	for i := 0; i < 10; i++ {
+		guid = append(guid, ankiGUIDAlphabet[n%base])
+		n /= base
+	}
+	return string(guid)
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// ankiChecksum is the first 32 bits of the SHA-1 of a field stripped of HTML,
+// which Anki uses to detect duplicate notes.
+func ankiChecksum(field string) int64 {
+	stripped := html.UnescapeString(htmlTagPattern.ReplaceAllString(field, ""))
+	sum := sha1.Sum([]byte(stripped))
+	v, _ := strconv.ParseInt(hex.EncodeToString(sum[:4]), 16, 64)
+	return v
+}
+
+// WriteAnkiPackage builds an .apkg file: a zip holding a legacy (schema 11)
+// collection.anki2 SQLite database, a media manifest and the media files.
+func WriteAnkiPackage(w io.Writer, deckName string, notes []AnkiNote, media []AnkiMedia) error {
+	dir, err := os.MkdirTemp("", "apkg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "collection.anki2")
+	if err := writeAnkiCollection(dbPath, deckName, notes); err != nil {
+		return fmt.Errorf("failed to build collection: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+	if err := addFileToZip(zw, "collection.anki2", dbPath); err != nil {
+		return err
+	}
+
+	manifest := make(map[string]string, len(media))
+	for i, m := range media {
+		name := strconv.Itoa(i)
+		manifest[name] = m.Name
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(m.Data); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create("media")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(manifest); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func addFileToZip(zw *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func writeAnkiCollection(path, deckName string, notes []AnkiNote) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range ankiSchema {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	col, err := ankiCollectionJSON(deckName, now.Unix())
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		now.Unix(), now.UnixMilli(), now.UnixMilli(), col.conf, col.models, col.decks, col.dconf)
+	if err != nil {
+		return err
+	}
+
+	// Note and card IDs only need to be unique within the collection; Anki
+	// matches notes on re-import by GUID.
+	baseID := now.UnixMilli()
+	for i, n := range notes {
+		id := baseID + int64(i)
+		tags := ""
+		if len(n.Tags) > 0 {
+			tags = " " + strings.Join(n.Tags, " ") + " "
+		}
+
+		_, err := tx.Exec(`INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`,
+			id, n.GUID, ankiModelID, now.Unix(), tags,
+			strings.Join(n.Fields, ankiFieldSeparator), html.UnescapeString(n.Fields[0]), ankiChecksum(n.Fields[0]))
+		if err != nil {
+			return err
+		}
+
+		// New card: type 0, queue 0, due is the position in the new queue
+		_, err = tx.Exec(`INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+			id, id, ankiDeckID, now.Unix(), i+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+type ankiCollection struct {
+	conf, models, decks, dconf string
+}
+
+func ankiCollectionJSON(deckName string, mod int64) (*ankiCollection, error) {
+	fields := make([]map[string]any, len(ankiFields))
+	for i, name := range ankiFields {
+		fields[i] = map[string]any{
+			"name": name, "ord": i, "sticky": false, "rtl": false,
+			"font": "Arial", "size": 20, "media": []any{},
+		}
+	}
+
+	model := map[string]any{
+		"id": ankiModelID, "name": ankiModelName, "type": 0, "mod": mod, "usn": -1,
+		"sortf": 0, "did": ankiDeckID, "flds": fields, "tags": []any{}, "vers": []any{},
+		"tmpls": []map[string]any{{
+			"name": "Recognition", "ord": 0, "did": nil, "bqfmt": "", "bafmt": "",
+			"qfmt": `<div class="word">{{Word}}</div><div class="ipa">{{IPA}}</div>{{Audio}}`,
+			"afmt": `{{FrontSide}}<hr id="answer">{{Definitions}}{{#Examples}}<div class="examples">{{Examples}}</div>{{/Examples}}{{#Notes}}<div class="notes">{{Notes}}</div>{{/Notes}}`,
+		}},
+		"css":       ".card { font-family: arial; font-size: 20px; text-align: center; } .word { font-size: 32px; } .ipa { color: #666; } ol, ul { text-align: left; } .notes { color: #555; font-style: italic; }",
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+		"req":       []any{[]any{0, "any", []int{0}}},
+	}
+
+	deck := func(id int64, name string) map[string]any {
+		return map[string]any{
+			"id": id, "name": name, "mod": mod, "usn": -1, "desc": "", "dyn": 0, "conf": 1,
+			"collapsed": false, "extendNew": 10, "extendRev": 50,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+
+	dconf := map[string]any{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true,
+		"timer": 0, "replayq": true, "dyn": false,
+		"new": map[string]any{
+			"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500,
+			"order": 1, "perDay": 20, "bury": true, "separate": true,
+		},
+		"rev": map[string]any{
+			"perDay": 200, "ease4": 1.3, "fuzz": 0.05, "ivlFct": 1, "maxIvl": 36500,
+			"bury": true, "minSpace": 1,
+		},
+		"lapse": map[string]any{
+			"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0,
+		},
+	}
+
+	conf := map[string]any{
+		"nextPos": 1, "estTimes": true, "activeDecks": []int64{1}, "sortType": "noteFld",
+		"timeLim": 0, "sortBackwards": false, "addToCur": true, "curDeck": 1,
+		"newBury": true, "newSpread": 0, "dueCounts": true,
+		"curModel": strconv.FormatInt(ankiModelID, 10), "collapseTime": 1200,
+	}
+
+	var col ankiCollection
+	for _, part := range []struct {
+		dst *string
+		v   any
+	}{
+		{&col.conf, conf},
+		{&col.models, map[string]any{strconv.FormatInt(ankiModelID, 10): model}},
+		{&col.decks, map[string]any{"1": deck(1, "Default"), strconv.FormatInt(ankiDeckID, 10): deck(ankiDeckID, deckName)}},
+		{&col.dconf, map[string]any{"1": dconf}},
+	} {
+		data, err := json.Marshal(part.v)
+		if err != nil {
+			return nil, err
+		}
+		*part.dst = string(data)
+	}
+
+	return &col, nil
+}
+
+// ankiSchema creates the tables and indexes of a schema-11 collection
+var ankiSchema = []string{
+	`CREATE TABLE col (
+		id integer primary key, crt integer not null, mod integer not null, scm integer not null,
+		ver integer not null, dty integer not null, usn integer not null, ls integer not null,
+		conf text not null, models text not null, decks text not null, dconf text not null, tags text not null)`,
+	`CREATE TABLE notes (
+		id integer primary key, guid text not null, mid integer not null, mod integer not null,
+		usn integer not null, tags text not null, flds text not null, sfld integer not null,
+		csum integer not null, flags integer not null, data text not null)`,
+	`CREATE TABLE cards (
+		id integer primary key, nid integer not null, did integer not null, ord integer not null,
+		mod integer not null, usn integer not null, type integer not null, queue integer not null,
+		due integer not null, ivl integer not null, factor integer not null, reps integer not null,
+		lapses integer not null, left integer not null, odue integer not null, odid integer not null,
+		flags integer not null, data text not null)`,
+	`CREATE TABLE revlog (
+		id integer primary key, cid integer not null, usn integer not null, ease integer not null,
+		ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null,
+		type integer not null)`,
+	`CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null)`,
+	`CREATE INDEX ix_notes_usn ON notes (usn)`,
+	`CREATE INDEX ix_cards_usn ON cards (usn)`,
+	`CREATE INDEX ix_revlog_usn ON revlog (usn)`,
+	`CREATE INDEX ix_cards_nid ON cards (nid)`,
+	`CREATE INDEX ix_cards_sched ON cards (did, queue, due)`,
+	`CREATE INDEX ix_revlog_cid ON revlog (cid)`,
+	`CREATE INDEX ix_notes_csum ON notes (csum)`,
+}
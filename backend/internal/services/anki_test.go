@@ -0,0 +1,179 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestAnkiNoteFromEntry(t *testing.T) {
+	entry := &models.WordbookEntry{
+		Language:        "en",
+		Word:            "bass",
+		ShortDefinition: "a low sound",
+		Notes:           "not the fish\nremember <this>",
+		Tags:            []string{"music theory"},
+	}
+	dict := &models.DictionaryEntry{
+		Word:      "bass",
+		Phonetics: []models.Phonetic{{Audio: "https://example.com/bass.mp3"}, {Text: "/beɪs/"}},
+		Meanings: []models.Meaning{
+			{PartOfSpeech: "noun", Definitions: []models.Definition{
+				{Definition: "a low-frequency sound", Example: "turn up the bass"},
+			}},
+		},
+	}
+
+	note := AnkiNoteFromEntry("default", entry, dict)
+
+	if note.Fields[1] != "/beɪs/" {
+		t.Errorf("IPA field: got %q", note.Fields[1])
+	}
+	if !strings.Contains(note.Fields[2], "<li>a low-frequency sound</li>") {
+		t.Errorf("definitions field: got %q", note.Fields[2])
+	}
+	if !strings.Contains(note.Fields[3], "turn up the bass") {
+		t.Errorf("examples field: got %q", note.Fields[3])
+	}
+	if note.Fields[4] != "not the fish<br>remember &lt;this&gt;" {
+		t.Errorf("notes field: got %q", note.Fields[4])
+	}
+	if len(note.Tags) != 1 || note.Tags[0] != "music_theory" {
+		t.Errorf("tags: got %v", note.Tags)
+	}
+
+	// Without dictionary data the short definition is used
+	plain := AnkiNoteFromEntry("default", entry, nil)
+	if plain.Fields[2] != "a low sound" {
+		t.Errorf("fallback definition: got %q", plain.Fields[2])
+	}
+
+	if plain.GUID != note.GUID {
+		t.Error("GUID must be stable for the same user and word")
+	}
+	if other := AnkiNoteFromEntry("someone-else", entry, nil); other.GUID == note.GUID {
+		t.Error("GUID must differ between users")
+	}
+	if len(note.GUID) != 10 {
+		t.Errorf("expected 10-character GUID, got %q", note.GUID)
+	}
+
+	// English notes keep the GUID they had before entries had a language
+	if note.GUID != ankiGUID("default\x00"+entry.Word) {
+		t.Error("GUID of an English note must not depend on its language")
+	}
+	german := *entry
+	german.Language = "de"
+	if other := AnkiNoteFromEntry("default", &german, nil); other.GUID == note.GUID {
+		t.Error("GUID must differ between languages")
+	}
+}
+
+func TestWriteAnkiPackage(t *testing.T) {
+	notes := []AnkiNote{
+		AnkiNoteFromEntry("default", &models.WordbookEntry{Word: "hello", ShortDefinition: "a greeting"}, nil),
+		AnkiNoteFromEntry("default", &models.WordbookEntry{Word: "world", ShortDefinition: "the earth", Tags: []string{"basics"}}, nil),
+	}
+	media := []AnkiMedia{{Name: "hello.mp3", Data: []byte("ID3")}}
+
+	var buf bytes.Buffer
+	if err := WriteAnkiPackage(&buf, "Test Deck", notes, media); err != nil {
+		t.Fatalf("WriteAnkiPackage failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("package is not a zip: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	var manifest map[string]string
+	if err := json.Unmarshal(files["media"], &manifest); err != nil {
+		t.Fatalf("invalid media manifest: %v", err)
+	}
+	if manifest["0"] != "hello.mp3" || string(files["0"]) != "ID3" {
+		t.Errorf("media not bundled: manifest=%v", manifest)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "collection.anki2")
+	if err := os.WriteFile(dbPath, files["collection.anki2"], 0o600); err != nil {
+		t.Fatalf("write collection: %v", err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open collection: %v", err)
+	}
+	defer db.Close()
+
+	var noteCount, cardCount int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM notes`).Scan(&noteCount); err != nil {
+		t.Fatalf("count notes: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM cards`).Scan(&cardCount); err != nil {
+		t.Fatalf("count cards: %v", err)
+	}
+	if noteCount != 2 || cardCount != 2 {
+		t.Errorf("expected 2 notes and 2 cards, got %d/%d", noteCount, cardCount)
+	}
+
+	var guid, flds, tags string
+	if err := db.QueryRow(`SELECT guid, flds, tags FROM notes WHERE sfld = 'world'`).Scan(&guid, &flds, &tags); err != nil {
+		t.Fatalf("query note: %v", err)
+	}
+	if guid != notes[1].GUID {
+		t.Errorf("GUID mismatch: got %q, want %q", guid, notes[1].GUID)
+	}
+	if got := strings.Split(flds, ankiFieldSeparator); len(got) != len(ankiFields) || got[2] != "the earth" {
+		t.Errorf("unexpected fields: %q", got)
+	}
+	if tags != " basics " {
+		t.Errorf("unexpected tags: %q", tags)
+	}
+
+	var modelsJSON, decksJSON string
+	if err := db.QueryRow(`SELECT models, decks FROM col`).Scan(&modelsJSON, &decksJSON); err != nil {
+		t.Fatalf("query col: %v", err)
+	}
+	if !strings.Contains(modelsJSON, ankiModelName) || !strings.Contains(decksJSON, "Test Deck") {
+		t.Errorf("collection metadata missing model or deck")
+	}
+}
+
+func TestAnkiAudioName(t *testing.T) {
+	tests := []struct {
+		file models.AudioFile
+		want string
+	}{
+		{models.AudioFile{Checksum: "ab12", ContentType: "audio/mpeg"}, "ab12.mp3"},
+		{models.AudioFile{Checksum: "ab12", ContentType: "audio/ogg", Synthetic: true}, "ab12.ogg"},
+		{models.AudioFile{Checksum: "ab12", ContentType: "application/octet-stream"}, ""},
+		{models.AudioFile{ContentType: "audio/wav"}, ""},
+	}
+	for _, tt := range tests {
+		if got := ankiAudioName(&tt.file); got != tt.want {
+			t.Errorf("ankiAudioName(%+v) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+
+	// Every note has a field for its recording
+	note := AnkiNoteFromEntry("default", &models.WordbookEntry{Word: "hello", ShortDefinition: "a greeting"}, nil)
+	if len(note.Fields) != len(ankiFields) || ankiFields[ankiAudioField] != "Audio" || note.Fields[ankiAudioField] != "" {
+		t.Errorf("unexpected fields %q", note.Fields)
+	}
+}
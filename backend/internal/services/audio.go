@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+	"github.com/warriorguo/vocabulary/internal/repository"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// AudioPath is the route the audio proxy serves recordings under
+	AudioPath = "/api/audio/"
+
+	// maxAudioSize caps the bytes downloaded for one recording
+	maxAudioSize = 5 << 20
+)
+
+// ErrNoAudio is returned when the dictionary has no recording of a word
+var ErrNoAudio = errors.New("no audio available")
+
+// audioAccents are the regional variants upstream recordings are tagged
+// with, by a suffix of their file name ("hello-uk.mp3"). A recording in no
+// particular accent is preferred in this order.
+var audioAccents = []string{"us", "uk", "au", "ca", "ie", "nz", "sco", "in"}
+
+// audioContentTypes maps recording file extensions to their media type
+var audioContentTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".wav":  "audio/wav",
+	".m4a":  "audio/mp4",
+	".webm": "audio/webm",
+}
+
+// AudioStore keeps recordings on disk under their checksum, so identical
+// files are stored once.
+type AudioStore struct {
+	dir string
+}
+
+// NewAudioStore creates a store in dir, creating the directory if needed.
+func NewAudioStore(dir string) (*AudioStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audio store: %w", err)
+	}
+	return &AudioStore{dir: dir}, nil
+}
+
+func (s *AudioStore) path(checksum string) string {
+	return filepath.Join(s.dir, checksum[:2], checksum)
+}
+
+// Put stores data and returns its checksum.
+func (s *AudioStore) Put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	name := s.path(checksum)
+	if _, err := os.Stat(name); err == nil {
+		return checksum, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", err
+	}
+
+	// Written aside and renamed, so a reader never sees a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(name), checksum+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return checksum, os.Rename(tmp.Name(), name)
+}
+
+// Open opens the file with the given checksum. The error wraps
+// fs.ErrNotExist if it isn't stored.
+func (s *AudioStore) Open(checksum string) (*os.File, error) {
+	if len(checksum) < 2 || strings.ContainsAny(checksum, `/\.`) {
+		return nil, fmt.Errorf("invalid checksum %q: %w", checksum, fs.ErrNotExist)
+	}
+	return os.Open(s.path(checksum))
+}
+
+// AudioService proxies pronunciation recordings: each is downloaded from
+// the dictionary's source once, then served from the audio store. Words
+// without a recording are spoken by the synthesizer, if one is configured.
+type AudioService struct {
+	repo    *repository.Repository
+	dictSvc *DictionaryService
+	store   *AudioStore
+	synth   *Synthesizer
+	client  *http.Client
+	fetches singleflight.Group
+}
+
+// NewAudioService creates the audio proxy. synth may be nil, leaving words
+// without a recording silent.
+func NewAudioService(repo *repository.Repository, dictSvc *DictionaryService, store *AudioStore, synth *Synthesizer) *AudioService {
+	return &AudioService{
+		repo:    repo,
+		dictSvc: dictSvc,
+		store:   store,
+		synth:   synth,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Open returns the recording of word in accent, or the best recording when
+// accent is empty, fetching it first if it isn't stored yet. Synthetic audio
+// is served until the dictionary has a recording of the word. The caller
+// closes the file.
+func (s *AudioService) Open(ctx context.Context, language, word, accent string) (*models.AudioFile, *os.File, error) {
+	form := normalize.Form(word)
+	word, err := normalize.Word(language, word)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := s.repo.GetAudioFile(ctx, language, word, accent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("audio lookup failed: %w", err)
+	}
+	if stored != nil && stored.Synthetic && s.hasRecording(ctx, language, form) {
+		// The dictionary gained a recording since the word was synthesized,
+		// and fetching it replaces the synthetic audio
+		stored = nil
+	}
+	if stored != nil {
+		file, err := s.store.Open(stored.Checksum)
+		if err == nil {
+			return stored, file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, err
+		}
+		// The store was cleared, so the recording is fetched again
+	}
+
+	// Concurrent requests for the same recording share one download, which
+	// outlives any single request being cancelled
+	v, err, _ := s.fetches.Do(language+"\x00"+word+"\x00"+accent, func() (any, error) {
+		return s.fetch(context.WithoutCancel(ctx), language, form, accent)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	f := v.(*models.AudioFile)
+	file, err := s.store.Open(f.Checksum)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, file, nil
+}
+
+// hasRecording reports whether the dictionary entry of word has a recording.
+// A word that can't be looked up has none.
+func (s *AudioService) hasRecording(ctx context.Context, language, word string) bool {
+	entry, err := s.dictSvc.LookupWord(ctx, language, word)
+	return err == nil && bestAudio(entry.Phonetics, "") != ""
+}
+
+// fetch downloads the recording of word, or synthesizes one, and records
+// it. The word is looked up as given, since dictionaries can be case-sensitive.
+func (s *AudioService) fetch(ctx context.Context, language, word, accent string) (*models.AudioFile, error) {
+	entry, err := s.dictSvc.LookupWord(ctx, language, word)
+	if err != nil {
+		return nil, err
+	}
+	src := bestAudio(entry.Phonetics, accent)
+	if src == "" {
+		// Synthetic audio has no accent of its own to ask for
+		if s.synth != nil && accent == "" {
+			return s.synthesize(ctx, entry)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNoAudio, word)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("audio request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNoAudio, word)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("audio source returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAudioSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	if len(data) > maxAudioSize {
+		return nil, fmt.Errorf("audio exceeds %d bytes", maxAudioSize)
+	}
+
+	checksum, err := s.store.Put(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store audio: %w", err)
+	}
+	f := &models.AudioFile{
+		Language:    language,
+		Word:        word,
+		Accent:      accent,
+		SourceURL:   src,
+		ContentType: audioContentType(resp.Header.Get("Content-Type"), src, data),
+		Checksum:    checksum,
+		Size:        int64(len(data)),
+	}
+	if err := s.repo.SaveAudioFile(ctx, f); err != nil {
+		return nil, fmt.Errorf("failed to record audio: %w", err)
+	}
+	return f, nil
+}
+
+// synthesize speaks entry's word with the synthesizer and records the
+// audio as synthetic.
+func (s *AudioService) synthesize(ctx context.Context, entry *models.DictionaryEntry) (*models.AudioFile, error) {
+	var ipa string
+	for _, p := range entry.Phonetics {
+		if p.Text != "" {
+			ipa = p.Text
+			break
+		}
+	}
+
+	data, err := s.synth.Synthesize(ctx, entry.Language, entry.Word, ipa)
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := s.store.Put(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store audio: %w", err)
+	}
+	f := &models.AudioFile{
+		Language:    entry.Language,
+		Word:        entry.Word,
+		ContentType: s.synth.ContentType(),
+		Checksum:    checksum,
+		Size:        int64(len(data)),
+		Synthetic:   true,
+	}
+	if err := s.repo.SaveAudioFile(ctx, f); err != nil {
+		return nil, fmt.Errorf("failed to record audio: %w", err)
+	}
+	return f, nil
+}
+
+// ProxyAudio points the audio of an entry's phonetics at the audio proxy,
+// keeping the accent of each recording. An entry with no recording gets
+// synthetic audio when a synthesizer is configured.
+func (s *AudioService) ProxyAudio(entry *models.DictionaryEntry) {
+	recorded := false
+	for i := range entry.Phonetics {
+		p := &entry.Phonetics[i]
+		if p.Audio != "" {
+			p.Audio = AudioURL(entry.Language, entry.Word, audioAccent(p.Audio))
+			recorded = true
+		}
+	}
+	if recorded || s.synth == nil {
+		return
+	}
+
+	synthetic := models.Phonetic{Audio: AudioURL(entry.Language, entry.Word, ""), Synthetic: true}
+	for i := range entry.Phonetics {
+		if entry.Phonetics[i].Text != "" {
+			entry.Phonetics[i].Audio, entry.Phonetics[i].Synthetic = synthetic.Audio, true
+			return
+		}
+	}
+	entry.Phonetics = append(entry.Phonetics, synthetic)
+}
+
+// AudioURL returns the proxied path of the recording of word in accent.
+func AudioURL(language, word, accent string) string {
+	query := url.Values{}
+	if language != "" && language != DefaultLanguage {
+		query.Set("lang", language)
+	}
+	if accent != "" {
+		query.Set("accent", accent)
+	}
+
+	u := AudioPath + url.PathEscape(word)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// bestAudio picks the recording in accent from phonetics, or with no
+// accent given, the first recording in the most preferred accent. It
+// returns "" when there is none.
+func bestAudio(phonetics []models.Phonetic, accent string) string {
+	var first string
+	best := len(audioAccents)
+	for _, p := range phonetics {
+		if p.Audio == "" {
+			continue
+		}
+		a := audioAccent(p.Audio)
+		if accent != "" {
+			if a == accent {
+				return p.Audio
+			}
+			continue
+		}
+
+		if first == "" {
+			first = p.Audio
+		}
+		for rank, preferred := range audioAccents[:best] {
+			if a == preferred {
+				first, best = p.Audio, rank
+				break
+			}
+		}
+	}
+	return first
+}
+
+// audioAccent returns the accent a recording's file name is tagged with, or
+// "" if it has none.
+func audioAccent(src string) string {
+	u, err := url.Parse(src)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return ""
+	}
+	suffix := strings.ToLower(name[i+1:])
+	for _, a := range audioAccents {
+		if suffix == a {
+			return a
+		}
+	}
+	return ""
+}
+
+// audioContentType determines the media type of a downloaded recording from
+// the response header, falling back to its file extension and then to its
+// content.
+func audioContentType(header, src string, data []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(header); err == nil && strings.HasPrefix(mediaType, "audio/") {
+		return mediaType
+	}
+	if u, err := url.Parse(src); err == nil {
+		if t, ok := audioContentTypes[strings.ToLower(path.Ext(u.Path))]; ok {
+			return t
+		}
+	}
+	return http.DetectContentType(data)
+}
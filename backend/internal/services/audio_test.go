@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestAudioStore(t *testing.T) {
+	store, err := NewAudioStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checksum, err := store.Put([]byte("ID3 audio"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if len(checksum) != 64 {
+		t.Fatalf("expected a SHA-256 checksum, got %q", checksum)
+	}
+	if again, err := store.Put([]byte("ID3 audio")); err != nil || again != checksum {
+		t.Errorf("storing the same data again gave %q, %v", again, err)
+	}
+
+	f, err := store.Open(checksum)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	if data, _ := io.ReadAll(f); string(data) != "ID3 audio" {
+		t.Errorf("unexpected contents %q", data)
+	}
+
+	for _, bad := range []string{"0000000000000000000000000000000000000000000000000000000000000000", "../etc", ""} {
+		if _, err := store.Open(bad); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Open(%q) error = %v, want fs.ErrNotExist", bad, err)
+		}
+	}
+}
+
+func TestBestAudio(t *testing.T) {
+	phonetics := []models.Phonetic{
+		{Text: "/həˈləʊ/"},
+		{Audio: "https://example.com/hello-au.mp3"},
+		{Audio: "https://example.com/hello-uk.mp3"},
+		{Audio: "https://example.com/hello-us.mp3"},
+	}
+
+	tests := map[string]string{
+		"":   "https://example.com/hello-us.mp3",
+		"uk": "https://example.com/hello-uk.mp3",
+		"ca": "",
+	}
+	for accent, want := range tests {
+		if got := bestAudio(phonetics, accent); got != want {
+			t.Errorf("bestAudio(%q) = %q, want %q", accent, got, want)
+		}
+	}
+
+	untagged := []models.Phonetic{{Audio: "https://example.com/to-do.ogg"}}
+	if got := bestAudio(untagged, ""); got != untagged[0].Audio {
+		t.Errorf("expected the only recording, got %q", got)
+	}
+	if got := bestAudio(nil, ""); got != "" {
+		t.Errorf("expected no recording, got %q", got)
+	}
+}
+
+func TestProxyAudio(t *testing.T) {
+	entry := &models.DictionaryEntry{
+		Word:     "and/or",
+		Language: "en",
+		Phonetics: []models.Phonetic{
+			{Text: "/ænd ɔː/"},
+			{Audio: "https://example.com/and-or-uk.mp3"},
+		},
+	}
+	(&AudioService{}).ProxyAudio(entry)
+	if entry.Phonetics[0].Audio != "" {
+		t.Errorf("a phonetic without audio gained one: %q", entry.Phonetics[0].Audio)
+	}
+	if got := entry.Phonetics[1].Audio; got != "/api/audio/and%2For?accent=uk" {
+		t.Errorf("unexpected proxied URL %q", got)
+	}
+
+	if got := AudioURL("de", "straße", ""); got != "/api/audio/stra%C3%9Fe?lang=de" {
+		t.Errorf("unexpected proxied URL %q", got)
+	}
+
+	t.Run("synthetic", func(t *testing.T) {
+		svc := &AudioService{synth: &Synthesizer{args: []string{"espeak-ng"}, format: TTSFormatWAV}}
+
+		silent := &models.DictionaryEntry{Word: "hello", Language: "en", Phonetics: []models.Phonetic{{}, {Text: "/həˈləʊ/"}}}
+		svc.ProxyAudio(silent)
+		if p := silent.Phonetics[1]; p.Audio != "/api/audio/hello" || !p.Synthetic {
+			t.Errorf("expected synthetic audio on the transcribed phonetic, got %+v", p)
+		}
+		if silent.Phonetics[0].Audio != "" {
+			t.Errorf("expected synthetic audio once, got %+v", silent.Phonetics)
+		}
+
+		bare := &models.DictionaryEntry{Word: "hello", Language: "fr"}
+		svc.ProxyAudio(bare)
+		if len(bare.Phonetics) != 1 || bare.Phonetics[0].Audio != "/api/audio/hello?lang=fr" || !bare.Phonetics[0].Synthetic {
+			t.Errorf("expected a synthetic phonetic to be added, got %+v", bare.Phonetics)
+		}
+
+		recorded := &models.DictionaryEntry{Word: "and/or", Language: "en", Phonetics: []models.Phonetic{{Audio: "https://example.com/and-or-uk.mp3"}}}
+		svc.ProxyAudio(recorded)
+		if recorded.Phonetics[0].Synthetic {
+			t.Error("a recording was marked synthetic")
+		}
+	})
+}
+
+func TestAudioContentType(t *testing.T) {
+	tests := []struct {
+		header, src, want string
+	}{
+		{"audio/mpeg; charset=binary", "https://example.com/a", "audio/mpeg"},
+		{"application/octet-stream", "https://example.com/a.ogg?x=1", "audio/ogg"},
+		{"", "https://example.com/a", "audio/wave"},
+	}
+	for _, tt := range tests {
+		if got := audioContentType(tt.header, tt.src, []byte("RIFF\x00\x00\x00\x00WAVEfmt ")); got != tt.want {
+			t.Errorf("audioContentType(%q, %q) = %q, want %q", tt.header, tt.src, got, tt.want)
+		}
+	}
+}
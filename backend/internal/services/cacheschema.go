@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/repository"
+)
+
+// cacheMigrationBatch is the number of cache rows MigrateCache reads at once
+const cacheMigrationBatch = 200
+
+// ErrCacheOutdated is returned for a cached entry written in a schema version
+// that can't be upgraded to the current one; the word has to be fetched again
+var ErrCacheOutdated = errors.New("cached entry is outdated")
+
+// cacheUpgrade rewrites the JSON of a cached entry in one schema version as
+// the next version.
+type cacheUpgrade func(data []byte) ([]byte, error)
+
+// cacheUpgrades holds the upgrade of cached entries from each schema version
+// to the next. Entries of a version without one are fetched again.
+var cacheUpgrades = map[int]cacheUpgrade{
+	1: upgradeToHomographs,
+}
+
+// cacheCodec reads cached entries written in any schema version it can
+// upgrade to its own.
+type cacheCodec struct {
+	version  int
+	upgrades map[int]cacheUpgrade
+}
+
+var entryCodec = cacheCodec{version: models.CacheSchemaVersion, upgrades: cacheUpgrades}
+
+// decode returns the entry of a cache row. When the row was written in an
+// older schema version, upgraded holds its data rewritten in the current
+// one, to be stored back.
+func (c cacheCodec) decode(cached *models.DictionaryCache) (entry *models.DictionaryEntry, upgraded []byte, err error) {
+	data, err := c.upgrade(cached.Data, cached.SchemaVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal cached data: %w", err)
+	}
+	if cached.SchemaVersion != c.version {
+		upgraded = data
+	}
+	return entry, upgraded, nil
+}
+
+// upgrade rewrites data in schema version as the codec's version. Entries of
+// newer versions, written by a newer server, aren't guessed at.
+func (c cacheCodec) upgrade(data []byte, version int) ([]byte, error) {
+	if version > c.version {
+		return nil, fmt.Errorf("%w: schema version %d is newer than %d", ErrCacheOutdated, version, c.version)
+	}
+	for ; version < c.version; version++ {
+		up, ok := c.upgrades[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: no upgrade from schema version %d", ErrCacheOutdated, version)
+		}
+		var err error
+		if data, err = up(data); err != nil {
+			return nil, fmt.Errorf("%w: upgrade from schema version %d failed: %v", ErrCacheOutdated, version, err)
+		}
+	}
+	return data, nil
+}
+
+// storeUpgrade writes back a cached entry upgraded on read, so it is
+// upgraded once rather than on every lookup.
+func (s *DictionaryService) storeUpgrade(ctx context.Context, cached *models.DictionaryCache, upgraded []byte) {
+	if _, err := s.repo.UpgradeCachedDictionary(ctx, cached.Language, cached.Word, cached.SchemaVersion, upgraded); err != nil {
+		fmt.Printf("Warning: failed to store upgraded cache entry for %q: %v\n", cached.Word, err)
+	}
+}
+
+// CacheMigration counts the cache rows rewritten by MigrateCache
+type CacheMigration struct {
+	Scanned  int
+	Upgraded int
+	// Renormalized rows were normalized again from their upstream payload,
+	// which is preferred to upgrading them
+	Renormalized int
+	// Refetched rows couldn't be normalized again or upgraded and were
+	// fetched again
+	Refetched int
+	// Skipped rows couldn't be upgraded and were left for lookups to fetch
+	// again
+	Skipped int
+	// Failed rows couldn't be fetched again, e.g. when the word is no
+	// longer found upstream
+	Failed    int
+	LastError string
+}
+
+// MigrateCache rewrites every cache row written in an older schema version,
+// expired or not, in the current one. Rows are normalized again from their
+// upstream payload when they have one, and otherwise upgraded, or else
+// fetched again when fetch is set. progress, if not nil, is called after each
+// batch.
+func (s *DictionaryService) MigrateCache(ctx context.Context, fetch bool, progress func(CacheMigration)) (CacheMigration, error) {
+	var m CacheMigration
+	var after repository.WordKey
+	for {
+		rows, err := s.repo.GetOutdatedCacheRows(ctx, after, cacheMigrationBatch)
+		if err != nil {
+			return m, err
+		}
+		if len(rows) == 0 {
+			return m, nil
+		}
+
+		for i := range rows {
+			if err := ctx.Err(); err != nil {
+				return m, err
+			}
+			cached := &rows[i]
+			after = repository.WordKey{Language: cached.Language, Word: cached.Word}
+			m.Scanned++
+
+			if _, err := s.renormalize(ctx, cached); err == nil {
+				m.Renormalized++
+				continue
+			}
+			_, upgraded, err := entryCodec.decode(cached)
+			if err == nil {
+				if _, err := s.repo.UpgradeCachedDictionary(ctx, cached.Language, cached.Word, cached.SchemaVersion, upgraded); err != nil {
+					return m, err
+				}
+				m.Upgraded++
+				continue
+			}
+
+			switch {
+			case !fetch:
+				m.Skipped++
+			default:
+				if err := s.Refresh(ctx, cached.Language, cached.Word); err != nil {
+					m.Failed++
+					m.LastError = fmt.Sprintf("%s: %v", cached.Word, err)
+					continue
+				}
+				m.Refetched++
+			}
+		}
+
+		if progress != nil {
+			progress(m)
+		}
+	}
+}
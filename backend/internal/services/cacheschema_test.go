@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestCacheCodecDecode(t *testing.T) {
+	// Version 1 entries named the headword "term"; version 2 renamed it
+	codec := cacheCodec{version: 3, upgrades: map[int]cacheUpgrade{
+		1: func(data []byte) ([]byte, error) {
+			return bytes.Replace(data, []byte(`"term"`), []byte(`"word"`), 1), nil
+		},
+		2: func(data []byte) ([]byte, error) { return data, nil },
+	}}
+
+	entry, upgraded, err := codec.decode(&models.DictionaryCache{Data: []byte(`{"term":"hello"}`), SchemaVersion: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Word != "hello" || string(upgraded) != `{"word":"hello"}` {
+		t.Errorf("expected the entry upgraded, got %+v and %s", entry, upgraded)
+	}
+
+	entry, upgraded, err = codec.decode(&models.DictionaryCache{Data: []byte(`{"word":"hello"}`), SchemaVersion: 3})
+	if err != nil || entry.Word != "hello" || upgraded != nil {
+		t.Errorf("expected a current entry read as is, got %+v, %s, %v", entry, upgraded, err)
+	}
+
+	if _, _, err := codec.decode(&models.DictionaryCache{Data: []byte(`{}`), SchemaVersion: 0}); !errors.Is(err, ErrCacheOutdated) {
+		t.Errorf("expected ErrCacheOutdated without an upgrade, got %v", err)
+	}
+	if _, _, err := codec.decode(&models.DictionaryCache{Data: []byte(`{}`), SchemaVersion: 4}); !errors.Is(err, ErrCacheOutdated) {
+		t.Errorf("expected ErrCacheOutdated for a newer version, got %v", err)
+	}
+
+	failing := cacheCodec{version: 2, upgrades: map[int]cacheUpgrade{
+		1: func([]byte) ([]byte, error) { return nil, errors.New("unrecognized") },
+	}}
+	if _, _, err := failing.decode(&models.DictionaryCache{Data: []byte(`{}`), SchemaVersion: 1}); !errors.Is(err, ErrCacheOutdated) {
+		t.Errorf("expected ErrCacheOutdated when the upgrade fails, got %v", err)
+	}
+
+	// Corrupt data isn't mistaken for an outdated entry
+	if _, _, err := codec.decode(&models.DictionaryCache{Data: []byte(`{`), SchemaVersion: 3}); err == nil || errors.Is(err, ErrCacheOutdated) {
+		t.Errorf("expected an unmarshal error, got %v", err)
+	}
+}
+
+func TestUpgradeToHomographs(t *testing.T) {
+	v1 := []byte(`{"word":"bass","phonetics":[{"text":"/beɪs/"}],"meanings":[{"partOfSpeech":"noun","definitions":[{"definition":"A low sound."}]}]}`)
+
+	entry, upgraded, err := entryCodec.decode(&models.DictionaryCache{Data: v1, SchemaVersion: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if upgraded == nil || len(entry.Homographs) != 1 {
+		t.Fatalf("expected a single homograph, got %+v", entry.Homographs)
+	}
+	h := entry.Homographs[0]
+	if len(h.Phonetics) != 1 || h.Phonetics[0].Text != "/beɪs/" || len(h.Meanings) != 1 || h.Meanings[0].Definitions[0].Definition != "A low sound." {
+		t.Errorf("expected the homograph to hold the entry's phonetics and meanings, got %+v", h)
+	}
+	if len(entry.Meanings) != 1 || len(entry.Phonetics) != 1 {
+		t.Errorf("expected the merged view kept, got %+v", entry)
+	}
+}
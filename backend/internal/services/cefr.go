@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+//go:embed data/cefr.txt
+var bundledCEFRList string
+
+// CEFRLevels lists the Common European Framework levels, easiest first
+var CEFRLevels = []string{"A1", "A2", "B1", "B2", "C1", "C2"}
+
+// CEFRList maps headwords to the CEFR level at which learners are expected
+// to know them.
+type CEFRList struct {
+	levels map[string]string
+}
+
+// LoadCEFRList reads "word level" lines. Blank lines and lines starting with
+// # are ignored; a repeated word keeps its first level.
+func LoadCEFRList(r io.Reader) (*CEFRList, error) {
+	l := &CEFRList{levels: make(map[string]string)}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"word level\", got %q", n, line)
+		}
+		word, level := strings.ToLower(fields[0]), strings.ToUpper(fields[1])
+		if !slices.Contains(CEFRLevels, level) {
+			return nil, fmt.Errorf("line %d: unknown CEFR level %q", n, fields[1])
+		}
+		if _, ok := l.levels[word]; !ok {
+			l.levels[word] = level
+		}
+	}
+
+	return l, scanner.Err()
+}
+
+var (
+	defaultCEFROnce sync.Once
+	defaultCEFR     *CEFRList
+)
+
+// DefaultCEFRList returns the CEFR word list bundled with the binary.
+func DefaultCEFRList() *CEFRList {
+	defaultCEFROnce.Do(func() {
+		var err error
+		if defaultCEFR, err = LoadCEFRList(strings.NewReader(bundledCEFRList)); err != nil {
+			panic("bundled CEFR list: " + err.Error())
+		}
+	})
+	return defaultCEFR
+}
+
+// Level returns the word's CEFR level, or "" if it isn't in the list.
+func (l *CEFRList) Level(word string) string {
+	return l.levels[word]
+}
+
+// Len returns the number of words in the list.
+func (l *CEFRList) Len() int {
+	return len(l.levels)
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadCEFRList(t *testing.T) {
+	list, err := LoadCEFRList(strings.NewReader("# comment\nHouse a1\n\nhouse B2\nabandon C1\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := list.Level("house"); got != "A1" {
+		t.Errorf("expected the first level to win, got %q", got)
+	}
+	if got := list.Level("abandon"); got != "C1" {
+		t.Errorf("expected C1, got %q", got)
+	}
+	if got := list.Level("zyzzyva"); got != "" {
+		t.Errorf("expected no level for unlisted words, got %q", got)
+	}
+
+	for _, bad := range []string{"house\n", "house D1\n", "house A1 extra\n"} {
+		if _, err := LoadCEFRList(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestDefaultCEFRList(t *testing.T) {
+	list := DefaultCEFRList()
+	if list.Len() < 1000 {
+		t.Errorf("bundled list looks truncated: %d words", list.Len())
+	}
+	for word, want := range map[string]string{"house": "A1", "advice": "A2", "abandon": "B2"} {
+		if got := list.Level(word); got != want {
+			t.Errorf("Level(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+const (
+	clozeBlank         = "_____"
+	clozeSourceContext = "context"
+	clozeSourceExample = "example"
+
+	// clozeNonWord matches a character that can't be part of a word, in any
+	// script
+	clozeNonWord = `[^\p{L}\p{M}\p{N}_]`
+)
+
+// clozeTokenPattern matches the words of a sentence
+var clozeTokenPattern = regexp.MustCompile(`[\p{L}\p{M}]+(?:['’][\p{L}\p{M}]+)*`)
+
+// BuildClozeItems turns the sentences associated with a wordbook entry into
+// fill-in-the-blank exercises. The user's own encounter contexts come first,
+// followed by examples from the stored dictionary snapshot, narrowed to the
+// pinned homograph. Sentences that don't contain the word are skipped.
+func BuildClozeItems(entry *models.WordbookEntry) []models.ClozeItem {
+	items := make([]models.ClozeItem, 0)
+	lemma := clozeLemma(entry.Language)
+
+	for _, wc := range entry.Contexts {
+		if text, answer, ok := MakeCloze(wc.Sentence, entry.Word, lemma); ok {
+			items = append(items, models.ClozeItem{Word: entry.Word, Text: text, Answer: answer, Source: clozeSourceContext})
+		}
+	}
+
+	if snapshot := entry.SelectedSnapshot(); snapshot != nil {
+		for _, m := range snapshot.Meanings {
+			for _, d := range m.Definitions {
+				if d.Example == "" {
+					continue
+				}
+				if text, answer, ok := MakeCloze(d.Example, entry.Word, lemma); ok {
+					items = append(items, models.ClozeItem{Word: entry.Word, Text: text, Answer: answer, Source: clozeSourceExample})
+				}
+			}
+		}
+	}
+
+	return items
+}
+
+// MakeCloze blanks out every occurrence of word in sentence, including
+// inflections: the sentence's words with the same lemma ("ran" and "running"
+// for "run", "mice" for "mouse"), and simple plurals and -ed/-ing forms of
+// words lemma doesn't know. answer is the form found in the sentence at its
+// first occurrence.
+func MakeCloze(sentence, word string, lemma func(string) string) (text, answer string, ok bool) {
+	// In a phrase it is the first word that inflects ("looked up"), and the
+	// words may be split across a line break
+	parts := strings.Fields(word)
+	if len(parts) == 0 {
+		return "", "", false
+	}
+
+	forms := []string{regexp.QuoteMeta(parts[0]) + `(?:s|es|ed|d|ing)?`}
+	for _, form := range inflectedForms(sentence, parts[0], lemma) {
+		forms = append(forms, regexp.QuoteMeta(form))
+	}
+	core := `(?:` + strings.Join(forms, "|") + `)`
+	for _, part := range parts[1:] {
+		core += `\s+` + regexp.QuoteMeta(part)
+	}
+	// \b only knows ASCII letters, so "über" would never start a word;
+	// boundaries are spelled out as any character that isn't part of one
+	re := regexp.MustCompile(`(?i)(?:^|` + clozeNonWord + `)(` + core + `)(?:$|` + clozeNonWord + `)`)
+
+	var b strings.Builder
+	last := 0
+	for {
+		loc := re.FindStringSubmatchIndex(sentence[last:])
+		if loc == nil {
+			break
+		}
+		start, end := last+loc[2], last+loc[3]
+		if answer == "" {
+			answer = sentence[start:end]
+		}
+		b.WriteString(sentence[last:start])
+		b.WriteString(clozeBlank)
+		// The boundary after a match may start the next one
+		last = end
+	}
+	if answer == "" {
+		return "", "", false
+	}
+	b.WriteString(sentence[last:])
+	return b.String(), answer, true
+}
+
+// inflectedForms returns the words of sentence, lowercased, that share their
+// lemma with word but aren't word itself.
+func inflectedForms(sentence, word string, lemma func(string) string) []string {
+	word = strings.ToLower(word)
+	base := lemma(word)
+	var forms []string
+	for _, token := range clozeTokenPattern.FindAllString(sentence, -1) {
+		token = strings.ToLower(token)
+		if token != word && !slices.Contains(forms, token) && lemma(token) == base {
+			forms = append(forms, token)
+		}
+	}
+	return forms
+}
+
+// clozeLemma returns the lemmatizer cloze items of a language match words
+// by. Only English has one, so other languages match inflections by suffix.
+func clozeLemma(language string) func(string) string {
+	if language != "" && language != DefaultLanguage {
+		return func(word string) string { return word }
+	}
+	morph, freq := DefaultMorphology(), DefaultFrequencyList()
+	return func(word string) string { return morph.Lemma(word, freq.Contains) }
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestMakeCloze(t *testing.T) {
+	tests := []struct {
+		name       string
+		sentence   string
+		word       string
+		wantText   string
+		wantAnswer string
+		wantOK     bool
+	}{
+		{"exact match", "It was pure serendipity.", "serendipity", "It was pure _____.", "serendipity", true},
+		{"capitalized", "Serendipity struck.", "serendipity", "_____ struck.", "Serendipity", true},
+		{"inflected", "She walked home and kept walking.", "walk", "She _____ home and kept _____.", "walked", true},
+		{"phrase", "Never give up on it.", "give up", "Never _____ on it.", "give up", true},
+		{"inflected phrase", "She looked\nup the word.", "look up", "She _____ the word.", "looked\nup", true},
+		{"phrase with slash", "Tea and/or coffee.", "and/or", "Tea _____ coffee.", "and/or", true},
+		{"no partial word", "The runway was wet.", "run", "", "", false},
+		{"irregular form", "The mice ran off.", "mouse", "The _____ ran off.", "mice", true},
+		{"irregular verb", "They ran, then kept running.", "run", "They _____, then kept _____.", "ran", true},
+		{"doubled consonant", "He stopped.", "stop", "He _____.", "stopped", true},
+		{"y to ies", "She studies law.", "study", "She _____ law.", "studies", true},
+		{"progressive", "I was running late.", "run", "I was _____ late.", "running", true},
+		{"repeated", "Walk, walk!", "walk", "_____, _____!", "Walk", true},
+		{"non-ASCII start", "Er sprang über den Zaun.", "über", "Er sprang _____ den Zaun.", "über", true},
+		{"non-ASCII end", "Un café, por favor.", "café", "Un _____, por favor.", "café", true},
+		{"non-ASCII plural", "El árbol y los árboles.", "árbol", "El _____ y los _____.", "árbol", true},
+		{"no partial non-ASCII word", "Die Übergabe war kurz.", "über", "", "", false},
+		{"absent", "Nothing to see here.", "hello", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, answer, ok := MakeCloze(tt.sentence, tt.word, clozeLemma(DefaultLanguage))
+			if ok != tt.wantOK || text != tt.wantText || answer != tt.wantAnswer {
+				t.Errorf("MakeCloze(%q, %q) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.sentence, tt.word, text, answer, ok, tt.wantText, tt.wantAnswer, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestBuildClozeItems(t *testing.T) {
+	entry := &models.WordbookEntry{
+		Word: "bank",
+		Contexts: []models.WordContext{
+			{Sentence: "We sat on the river bank."},
+			{Sentence: "Unrelated sentence."},
+		},
+		Snapshot: &models.DictionaryEntry{
+			Meanings: []models.Meaning{
+				{Definitions: []models.Definition{{Definition: "a financial institution", Example: "Two banks merged."}}},
+			},
+		},
+	}
+
+	items := BuildClozeItems(entry)
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d: %+v", len(items), items)
+	}
+	if items[0].Source != clozeSourceContext || items[0].Text != "We sat on the river _____." {
+		t.Errorf("unexpected context item: %+v", items[0])
+	}
+	if items[1].Source != clozeSourceExample || items[1].Answer != "banks" {
+		t.Errorf("unexpected example item: %+v", items[1])
+	}
+}
+
+func TestBuildClozeItemsPinnedHomograph(t *testing.T) {
+	sound := models.Meaning{Definitions: []models.Definition{{Definition: "a low sound", Example: "Turn up the bass."}}}
+	fish := models.Meaning{Definitions: []models.Definition{{Definition: "a fish", Example: "He caught a bass."}}}
+	pinned := 1
+	entry := &models.WordbookEntry{
+		Word:              "bass",
+		SelectedHomograph: &pinned,
+		Snapshot: &models.DictionaryEntry{
+			Meanings:   []models.Meaning{sound, fish},
+			Homographs: []models.Homograph{{Meanings: []models.Meaning{sound}}, {Meanings: []models.Meaning{fish}}},
+		},
+	}
+
+	// Only the pinned homograph's examples are used
+	items := BuildClozeItems(entry)
+	if len(items) != 1 || items[0].Text != "He caught a _____." {
+		t.Errorf("expected only the fish example, got %+v", items)
+	}
+}
+
+func TestBuildClozeItemsInflectedContexts(t *testing.T) {
+	entry := &models.WordbookEntry{
+		Language: "en",
+		Word:     "mouse",
+		Contexts: []models.WordContext{{Sentence: "The mice ran off."}},
+	}
+
+	// The wordbook stores lemmas, while contexts keep the forms as read
+	items := BuildClozeItems(entry)
+	if len(items) != 1 || items[0].Text != "The _____ ran off." || items[0].Answer != "mice" {
+		t.Errorf("expected the inflected context as an item, got %+v", items)
+	}
+}
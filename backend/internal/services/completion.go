@@ -0,0 +1,130 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxCompletions is how many completions each trie node keeps, and so the
+// most a single prefix query can return
+const MaxCompletions = 20
+
+// Completer completes word prefixes from an in-memory trie. Every node keeps
+// its best completions precomputed, so a query costs one walk down the
+// prefix. It is safe for concurrent use.
+type Completer struct {
+	freq *FrequencyList
+
+	mu   sync.RWMutex
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	word     bool
+	best     []string
+}
+
+// NewCompleter creates a completer over words, ranking completions by their
+// frequency in freq.
+func NewCompleter(freq *FrequencyList, words []string) *Completer {
+	c := &Completer{freq: freq, root: &trieNode{}}
+	c.Add(words...)
+	return c
+}
+
+// Add inserts words into the trie. Words already present are ignored.
+func (c *Completer) Add(words ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" {
+			continue
+		}
+
+		path := []*trieNode{c.root}
+		node := c.root
+		for _, r := range word {
+			child, ok := node.children[r]
+			if !ok {
+				if node.children == nil {
+					node.children = make(map[rune]*trieNode)
+				}
+				child = &trieNode{}
+				node.children[r] = child
+			}
+			node = child
+			path = append(path, node)
+		}
+		if node.word {
+			continue
+		}
+		node.word = true
+
+		for _, n := range path {
+			n.best = c.insertBest(n.best, word)
+		}
+	}
+}
+
+// Complete returns up to limit words starting with prefix, most frequent
+// first.
+func (c *Completer) Complete(prefix string, limit int) []string {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" || limit <= 0 {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	node := c.root
+	for _, r := range prefix {
+		if node = node.children[r]; node == nil {
+			return nil
+		}
+	}
+
+	n := min(limit, len(node.best))
+	return append([]string(nil), node.best[:n]...)
+}
+
+// insertBest adds word to a node's ranked completions, keeping at most
+// MaxCompletions.
+func (c *Completer) insertBest(best []string, word string) []string {
+	i := sort.Search(len(best), func(i int) bool { return c.less(word, best[i]) })
+	if i >= MaxCompletions {
+		return best
+	}
+	best = append(best, "")
+	copy(best[i+1:], best[i:])
+	best[i] = word
+	if len(best) > MaxCompletions {
+		best = best[:MaxCompletions]
+	}
+	return best
+}
+
+// less orders completions: frequent words first, then shorter words, then
+// alphabetically.
+func (c *Completer) less(a, b string) bool {
+	ra, rb := c.rank(a), c.rank(b)
+	if ra != rb {
+		return ra < rb
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func (c *Completer) rank(word string) int {
+	if r := c.freq.Rank(word); r > 0 {
+		return r
+	}
+	return math.MaxInt
+}
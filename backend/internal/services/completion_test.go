@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompleterComplete(t *testing.T) {
+	freq, _ := LoadFrequencyList(strings.NewReader("the\nthen\nthere\nthink\n"))
+	c := NewCompleter(freq, []string{"think", "there", "the", "then", "theorem", "thesis"})
+
+	got := c.Complete("the", 10)
+	want := []string{"the", "then", "there", "thesis", "theorem"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got := c.Complete("TH", 2); !reflect.DeepEqual(got, []string{"the", "then"}) {
+		t.Errorf("prefix should be case-insensitive and limited, got %v", got)
+	}
+	if got := c.Complete("xyz", 5); got != nil {
+		t.Errorf("expected no completions, got %v", got)
+	}
+
+	c.Add("theme")
+	if got := c.Complete("them", 5); !reflect.DeepEqual(got, []string{"theme"}) {
+		t.Errorf("added words should complete, got %v", got)
+	}
+}
+
+func TestCompleterKeepsBestCompletions(t *testing.T) {
+	freq, _ := LoadFrequencyList(strings.NewReader("wordy\n"))
+	var words []string
+	for i := 0; i < MaxCompletions*2; i++ {
+		words = append(words, fmt.Sprintf("word%02d", i))
+	}
+	c := NewCompleter(freq, append(words, "wordy"))
+
+	got := c.Complete("wo", MaxCompletions*2)
+	if len(got) != MaxCompletions {
+		t.Fatalf("expected %d completions, got %d", MaxCompletions, len(got))
+	}
+	if got[0] != "wordy" {
+		t.Errorf("ranked words should come first, got %q", got[0])
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+)
+
+// ErrInvalidCustomEntry is returned for a custom entry or override that
+// can't be applied
+var ErrInvalidCustomEntry = errors.New("invalid custom entry")
+
+// SaveCustomEntry validates and stores an admin's curation of word, which
+// takes effect on the next lookup.
+func (s *DictionaryService) SaveCustomEntry(ctx context.Context, language, word string, req models.CustomEntryRequest, actor string) (*models.CustomEntry, error) {
+	language, err := s.Language(language)
+	if err != nil {
+		return nil, err
+	}
+	if word, err = normalize.Word(language, word); err != nil {
+		return nil, err
+	}
+	if req.Entry != nil {
+		// Homographs, when given, take precedence over phonetics and
+		// meanings, which are merged from them like upstream entries
+		if len(req.Entry.Homographs) > 0 {
+			mergeHomographs(req.Entry)
+		} else {
+			ensureHomographs(req.Entry)
+		}
+	}
+	if err := validateCustomEntry(req); err != nil {
+		return nil, err
+	}
+
+	custom := &models.CustomEntry{Language: language, Word: word, Entry: req.Entry, Overrides: req.Overrides}
+	if custom.Entry != nil {
+		custom.Entry.Word, custom.Entry.Language = word, language
+		custom.Entry.Curated = false
+		if custom.Entry.Phonetics == nil {
+			custom.Entry.Phonetics = []models.Phonetic{}
+		}
+	}
+	if err := s.repo.SaveCustomEntry(ctx, custom, actor); err != nil {
+		return nil, err
+	}
+
+	if language == DefaultLanguage {
+		s.speller.Add(word)
+		s.words.Add(word)
+	}
+	return custom, nil
+}
+
+// validateCustomEntry checks that a custom entry defines the word and its
+// overrides are complete.
+func validateCustomEntry(req models.CustomEntryRequest) error {
+	if req.Entry == nil && len(req.Overrides) == 0 {
+		return fmt.Errorf("%w: an entry or overrides are required", ErrInvalidCustomEntry)
+	}
+
+	if req.Entry != nil {
+		defined := slices.ContainsFunc(req.Entry.Meanings, func(m models.Meaning) bool {
+			return slices.ContainsFunc(m.Definitions, func(d models.Definition) bool {
+				return strings.TrimSpace(d.Definition) != ""
+			})
+		})
+		if !defined {
+			return fmt.Errorf("%w: the entry needs at least one definition", ErrInvalidCustomEntry)
+		}
+	}
+
+	for i, o := range req.Overrides {
+		var missing string
+		switch o.Action {
+		case models.OverrideReplaceDefinition, models.OverrideAddDefinition:
+			if o.PartOfSpeech == "" {
+				missing = "part_of_speech"
+			} else if strings.TrimSpace(o.Definition) == "" {
+				missing = "definition"
+			}
+		case models.OverrideRemoveDefinition, models.OverrideRemoveMeaning:
+			if o.PartOfSpeech == "" {
+				missing = "part_of_speech"
+			}
+		case models.OverrideSetPhonetic:
+			if strings.TrimSpace(o.Phonetic) == "" {
+				missing = "phonetic"
+			}
+		default:
+			return fmt.Errorf("%w: override %d has unknown action %q", ErrInvalidCustomEntry, i, o.Action)
+		}
+		if missing != "" {
+			return fmt.Errorf("%w: override %d (%s) requires %s", ErrInvalidCustomEntry, i, o.Action, missing)
+		}
+		if o.Index < 0 {
+			return fmt.Errorf("%w: override %d has a negative index", ErrInvalidCustomEntry, i)
+		}
+	}
+	return nil
+}
+
+// ApplyOverrides amends entry in place, applying overrides in order. Meanings
+// and definitions are addressed as merged across homographs, and changed in
+// the homograph they come from. An override addressing a meaning or
+// definition the entry doesn't have, for instance after upstream data
+// changed, is skipped.
+func ApplyOverrides(entry *models.DictionaryEntry, overrides []models.EntryOverride) {
+	if len(overrides) == 0 {
+		return
+	}
+	ensureHomographs(entry)
+
+	for _, o := range overrides {
+		switch o.Action {
+		case models.OverrideReplaceDefinition:
+			h, m, d, ok := findDefinition(entry, o.PartOfSpeech, o.Index)
+			if !ok {
+				continue
+			}
+			def := &entry.Homographs[h].Meanings[m].Definitions[d]
+			def.Definition = o.Definition
+			if o.Example != "" {
+				def.Example = o.Example
+			}
+
+		case models.OverrideAddDefinition:
+			def := models.Definition{Definition: o.Definition, Example: o.Example}
+			// Added to the last homograph with the part of speech
+			h, m := len(entry.Homographs)-1, -1
+			for ; h >= 0; h-- {
+				if m = findMeaning(entry.Homographs[h], o.PartOfSpeech); m >= 0 {
+					break
+				}
+			}
+			if m >= 0 {
+				meaning := &entry.Homographs[h].Meanings[m]
+				meaning.Definitions = append(meaning.Definitions, def)
+				continue
+			}
+			if len(entry.Homographs) == 0 {
+				entry.Homographs = append(entry.Homographs, models.Homograph{Phonetics: []models.Phonetic{}})
+			}
+			first := &entry.Homographs[0]
+			first.Meanings = append(first.Meanings, models.Meaning{PartOfSpeech: o.PartOfSpeech, Definitions: []models.Definition{def}})
+
+		case models.OverrideRemoveDefinition:
+			h, m, d, ok := findDefinition(entry, o.PartOfSpeech, o.Index)
+			if !ok {
+				continue
+			}
+			meaning := &entry.Homographs[h].Meanings[m]
+			meaning.Definitions = slices.Delete(meaning.Definitions, d, d+1)
+			if len(meaning.Definitions) == 0 {
+				entry.Homographs[h].Meanings = slices.Delete(entry.Homographs[h].Meanings, m, m+1)
+			}
+
+		case models.OverrideRemoveMeaning:
+			for h := range entry.Homographs {
+				entry.Homographs[h].Meanings = slices.DeleteFunc(entry.Homographs[h].Meanings, func(m models.Meaning) bool {
+					return strings.EqualFold(m.PartOfSpeech, o.PartOfSpeech)
+				})
+			}
+
+		case models.OverrideSetPhonetic:
+			if len(entry.Homographs) == 0 {
+				continue
+			}
+			first := &entry.Homographs[0]
+			if len(first.Phonetics) == 0 {
+				first.Phonetics = append(first.Phonetics, models.Phonetic{})
+			}
+			first.Phonetics[0].Text = o.Phonetic
+		}
+	}
+
+	// Homographs left without meanings have nothing to show
+	entry.Homographs = slices.DeleteFunc(entry.Homographs, func(h models.Homograph) bool {
+		return len(h.Meanings) == 0
+	})
+	mergeHomographs(entry)
+}
+
+// findMeaning returns the index of the meaning of h with the part of speech,
+// or -1.
+func findMeaning(h models.Homograph, partOfSpeech string) int {
+	return slices.IndexFunc(h.Meanings, func(m models.Meaning) bool {
+		return strings.EqualFold(m.PartOfSpeech, partOfSpeech)
+	})
+}
+
+// findDefinition locates the definition at index among those of the part of
+// speech across all homographs, in the order they are merged.
+func findDefinition(entry *models.DictionaryEntry, partOfSpeech string, index int) (h, m, d int, ok bool) {
+	for h, homograph := range entry.Homographs {
+		for m, meaning := range homograph.Meanings {
+			if !strings.EqualFold(meaning.PartOfSpeech, partOfSpeech) {
+				continue
+			}
+			if index < len(meaning.Definitions) {
+				return h, m, index, true
+			}
+			index -= len(meaning.Definitions)
+		}
+	}
+	return 0, 0, 0, false
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	entry := &models.DictionaryEntry{
+		Word: "bank",
+		Meanings: []models.Meaning{
+			{PartOfSpeech: "noun", Definitions: []models.Definition{
+				{Definition: "A financial institution.", Example: "I went to the bank."},
+				{Definition: "The edge of a river."},
+			}},
+			{PartOfSpeech: "verb", Definitions: []models.Definition{{Definition: "To deposit money."}}},
+		},
+	}
+
+	ApplyOverrides(entry, []models.EntryOverride{
+		{Action: models.OverrideReplaceDefinition, PartOfSpeech: "Noun", Index: 1, Definition: "The sloping land beside a river."},
+		{Action: models.OverrideRemoveDefinition, PartOfSpeech: "verb"},
+		{Action: models.OverrideAddDefinition, PartOfSpeech: "adjective", Definition: "Of a bank."},
+		// Indexes past the upstream definitions are skipped
+		{Action: models.OverrideReplaceDefinition, PartOfSpeech: "noun", Index: 5, Definition: "Stale."},
+		{Action: models.OverrideRemoveMeaning, PartOfSpeech: "interjection"},
+		{Action: models.OverrideSetPhonetic, Phonetic: "/bæŋk/"},
+	})
+
+	phonetics := []models.Phonetic{{Text: "/bæŋk/"}}
+	meanings := []models.Meaning{
+		{PartOfSpeech: "noun", Definitions: []models.Definition{
+			{Definition: "A financial institution.", Example: "I went to the bank."},
+			{Definition: "The sloping land beside a river."},
+		}},
+		{PartOfSpeech: "adjective", Definitions: []models.Definition{{Definition: "Of a bank."}}},
+	}
+	want := &models.DictionaryEntry{
+		Word:       "bank",
+		Phonetics:  phonetics,
+		Meanings:   meanings,
+		Homographs: []models.Homograph{{Phonetics: phonetics, Meanings: meanings}},
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("ApplyOverrides = %+v, want %+v", entry, want)
+	}
+}
+
+func TestApplyOverridesHomographs(t *testing.T) {
+	entry := &models.DictionaryEntry{
+		Word: "bass",
+		Homographs: []models.Homograph{
+			{Phonetics: []models.Phonetic{{Text: "/beɪs/"}}, Meanings: []models.Meaning{
+				{PartOfSpeech: "noun", Definitions: []models.Definition{{Definition: "A low sound."}}},
+			}},
+			{Phonetics: []models.Phonetic{{Text: "/bæs/"}}, Origin: "Old English bærs", Meanings: []models.Meaning{
+				{PartOfSpeech: "noun", Definitions: []models.Definition{{Definition: "A fish."}}},
+				{PartOfSpeech: "verb", Definitions: []models.Definition{{Definition: "To fish for bass."}}},
+			}},
+		},
+	}
+	mergeHomographs(entry)
+
+	ApplyOverrides(entry, []models.EntryOverride{
+		// The second noun definition merged is the fish
+		{Action: models.OverrideReplaceDefinition, PartOfSpeech: "noun", Index: 1, Definition: "A perch-like fish."},
+		{Action: models.OverrideAddDefinition, PartOfSpeech: "noun", Definition: "A bass guitar."},
+		{Action: models.OverrideRemoveMeaning, PartOfSpeech: "verb"},
+	})
+
+	fish := entry.Homographs[1]
+	if fish.Origin != "Old English bærs" || len(fish.Meanings) != 1 {
+		t.Fatalf("expected the fish homograph to keep its origin and lose its verb, got %+v", fish)
+	}
+	if defs := fish.Meanings[0].Definitions; len(defs) != 2 || defs[0].Definition != "A perch-like fish." || defs[1].Definition != "A bass guitar." {
+		t.Errorf("expected the fish homograph amended, got %+v", defs)
+	}
+	if len(entry.Meanings) != 1 || len(entry.Meanings[0].Definitions) != 3 || len(entry.Phonetics) != 2 {
+		t.Errorf("expected the merged view rebuilt, got %+v", entry)
+	}
+
+	// Removing every sense of a homograph removes it
+	ApplyOverrides(entry, []models.EntryOverride{{Action: models.OverrideRemoveDefinition, PartOfSpeech: "noun", Index: 0}})
+	if len(entry.Homographs) != 1 || entry.Homographs[0].Origin == "" {
+		t.Errorf("expected only the fish homograph left, got %+v", entry.Homographs)
+	}
+}
+
+func TestValidateCustomEntry(t *testing.T) {
+	defined := &models.DictionaryEntry{Meanings: []models.Meaning{{PartOfSpeech: "noun", Definitions: []models.Definition{{Definition: "A thing."}}}}}
+
+	tests := []struct {
+		name  string
+		req   models.CustomEntryRequest
+		valid bool
+	}{
+		{"empty", models.CustomEntryRequest{}, false},
+		{"entry", models.CustomEntryRequest{Entry: defined}, true},
+		{"entry without definitions", models.CustomEntryRequest{Entry: &models.DictionaryEntry{Meanings: []models.Meaning{{PartOfSpeech: "noun"}}}}, false},
+		{"override", models.CustomEntryRequest{Overrides: []models.EntryOverride{{Action: models.OverrideRemoveMeaning, PartOfSpeech: "verb"}}}, true},
+		{"unknown action", models.CustomEntryRequest{Overrides: []models.EntryOverride{{Action: "rename", PartOfSpeech: "verb"}}}, false},
+		{"missing definition", models.CustomEntryRequest{Overrides: []models.EntryOverride{{Action: models.OverrideAddDefinition, PartOfSpeech: "verb"}}}, false},
+		{"missing part of speech", models.CustomEntryRequest{Overrides: []models.EntryOverride{{Action: models.OverrideRemoveDefinition}}}, false},
+		{"negative index", models.CustomEntryRequest{Overrides: []models.EntryOverride{{Action: models.OverrideRemoveDefinition, PartOfSpeech: "verb", Index: -1}}}, false},
+		{"missing phonetic", models.CustomEntryRequest{Overrides: []models.EntryOverride{{Action: models.OverrideSetPhonetic}}}, false},
+	}
+	for _, tt := range tests {
+		err := validateCustomEntry(tt.req)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidCustomEntry) {
+			t.Errorf("%s: expected ErrInvalidCustomEntry, got %v", tt.name, err)
+		}
+	}
+}
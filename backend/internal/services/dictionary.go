@@ -3,180 +3,490 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
 	"github.com/warriorguo/vocabulary/internal/repository"
 )
 
 const (
-	freeDictAPIURL = "https://api.dictionaryapi.dev/api/v2/entries/en/"
-	cacheTTL       = 7 * 24 * time.Hour // 7 days
-	sourceFreeDic  = "freedictionaryapi"
+	cacheTTL = 7 * 24 * time.Hour // 7 days
+
+	// DefaultLanguage is the language of lookups that don't name one. The
+	// lemmatizer, spelling suggestions and word levels only cover it.
+	DefaultLanguage = "en"
+
+	// maxBaseLookups bounds the upstream requests spent guessing the lemma of
+	// a word the frequency list doesn't know
+	maxBaseLookups = 3
 )
 
+// ErrWordNotFound is returned when the dictionary has no entry for a word
+var ErrWordNotFound = errors.New("word not found")
+
+// wiktionaryLanguages are the languages looked up on Wiktionary
+var wiktionaryLanguages = []string{"de", "es", "fr", "it", "pt"}
+
+// DictionaryProvider fetches dictionary entries for one language from an
+// upstream source
+type DictionaryProvider interface {
+	// Source names the provider in the dictionary cache
+	Source() string
+	// Fetch returns the entry for a normalized word, or an error wrapping
+	// ErrWordNotFound when the source has none
+	Fetch(ctx context.Context, word string) (*models.DictionaryEntry, error)
+}
+
+// RawDictionaryProvider is a DictionaryProvider normalizing entries from an
+// upstream payload, which is cached with them so entries can be normalized
+// again without going upstream.
+type RawDictionaryProvider interface {
+	DictionaryProvider
+	// FetchRaw returns the upstream payload for a normalized word, or an
+	// error wrapping ErrWordNotFound when the source has none
+	FetchRaw(ctx context.Context, word string) ([]byte, error)
+	// Normalize returns the entry for word read from a payload FetchRaw
+	// returned, or an error wrapping ErrWordNotFound when it has none
+	Normalize(word string, payload []byte) (*models.DictionaryEntry, error)
+}
+
 type DictionaryService struct {
-	repo   *repository.Repository
-	client *http.Client
+	repo *repository.Repository
+	// providers lists the sources of each language in the order they are
+	// tried
+	providers map[string][]DictionaryProvider
+	morph     *Morphology
+	freq      *FrequencyList
+	cefr      *CEFRList
+	speller   *Speller
+	words     *Completer
+	// cacheHits and cacheMisses count cache reads since startup
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
 }
 
 func NewDictionaryService(repo *repository.Repository) *DictionaryService {
+	morph, freq := DefaultMorphology(), DefaultFrequencyList()
+	bundled := freq.Top(freq.Len())
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	phrases := DefaultPhraseList()
+	providers := map[string][]DictionaryProvider{
+		DefaultLanguage: {phrases, NewFreeDictionaryAPI(client, freeDictAPIURL, DefaultLanguage)},
+	}
+	for _, language := range wiktionaryLanguages {
+		providers[language] = []DictionaryProvider{NewWiktionary(client, wiktionaryAPIURL, language)}
+	}
+
 	return &DictionaryService{
-		repo: repo,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}
-}
-
-// FreeDictAPIResponse represents the raw API response
-type FreeDictAPIResponse []struct {
-	Word      string `json:"word"`
-	Phonetics []struct {
-		Text      string `json:"text"`
-		Audio     string `json:"audio"`
-		SourceURL string `json:"sourceUrl"`
-	} `json:"phonetics"`
-	Meanings []struct {
-		PartOfSpeech string `json:"partOfSpeech"`
-		Definitions  []struct {
-			Definition string   `json:"definition"`
-			Example    string   `json:"example"`
-			Synonyms   []string `json:"synonyms"`
-			Antonyms   []string `json:"antonyms"`
-		} `json:"definitions"`
-		Synonyms []string `json:"synonyms"`
-		Antonyms []string `json:"antonyms"`
-	} `json:"meanings"`
-	SourceUrls []string `json:"sourceUrls"`
-}
-
-func (s *DictionaryService) LookupWord(ctx context.Context, word string) (*models.DictionaryEntry, error) {
-	word = strings.ToLower(strings.TrimSpace(word))
-	if word == "" {
-		return nil, fmt.Errorf("word cannot be empty")
+		repo:      repo,
+		providers: providers,
+		morph:     morph,
+		freq:      freq,
+		cefr:      DefaultCEFRList(),
+		speller:   NewSpeller(freq, append(bundled, morph.Forms()...)),
+		words:     NewCompleter(freq, append(bundled, phrases.Phrases()...)),
 	}
+}
 
-	// Check cache first
-	cached, err := s.repo.GetCachedDictionary(ctx, word)
+// CacheCounts returns the number of lookups of a single headword answered
+// from the cache and fetched upstream since the service started. A lookup
+// of an inflected form may read the cache for several candidate headwords.
+func (s *DictionaryService) CacheCounts() (hits, misses int64) {
+	return s.cacheHits.Load(), s.cacheMisses.Load()
+}
+
+// IndexCachedWords adds every cached headword to the spelling suggestions
+// and prefix completions. Words cached later are added as they are looked up.
+func (s *DictionaryService) IndexCachedWords(ctx context.Context) error {
+	words, err := s.repo.GetCachedWords(ctx, DefaultLanguage)
 	if err != nil {
-		return nil, fmt.Errorf("cache lookup failed: %w", err)
+		return err
 	}
-	if cached != nil {
-		var entry models.DictionaryEntry
-		if err := json.Unmarshal(cached.Data, &entry); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal cached data: %w", err)
+	s.speller.Add(words...)
+	s.words.Add(words...)
+	return nil
+}
+
+// Languages returns the languages words can be looked up in, sorted.
+func (s *DictionaryService) Languages() []string {
+	languages := make([]string, 0, len(s.providers))
+	for language := range s.providers {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Language normalizes a language tag and checks that words can be looked up
+// in it. An empty tag means DefaultLanguage.
+func (s *DictionaryService) Language(tag string) (string, error) {
+	language := normalize.Language(tag)
+	if language == "" {
+		return DefaultLanguage, nil
+	}
+	if _, ok := s.providers[language]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedLanguage, tag)
+	}
+	return language, nil
+}
+
+// Levels returns the frequency rank, Zipf score and CEFR level of a lemma.
+// Words missing from the bundled lists, including phrases and words of
+// other languages than English, get zero values.
+func (s *DictionaryService) Levels(language, word string) models.WordLevels {
+	word, err := normalize.Word(language, word)
+	if err != nil || language != DefaultLanguage {
+		return models.WordLevels{}
+	}
+	return models.WordLevels{
+		FrequencyRank: s.freq.Rank(word),
+		Zipf:          s.freq.Zipf(word),
+		CEFRLevel:     s.cefr.Level(word),
+	}
+}
+
+// BackfillWordLevels annotates wordbook entries saved before word levels
+// were recorded.
+func (s *DictionaryService) BackfillWordLevels(ctx context.Context) error {
+	keys, err := s.repo.GetUnleveledWordbookWords(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.repo.SetWordbookLevels(ctx, key.Language, key.Word, s.Levels(key.Language, key.Word)); err != nil {
+			return err
 		}
-		return &entry, nil
 	}
+	return nil
+}
 
-	// Fetch from API
-	entry, err := s.fetchFromAPI(ctx, word)
+// Complete returns up to limit words in language starting with prefix:
+// matches from the user's wordbook first, then, for English, bundled and
+// cached words, most frequent first. The prefix is normalized like a word.
+func (s *DictionaryService) Complete(ctx context.Context, userID, language, prefix string, limit int) ([]models.Completion, error) {
+	language, err := s.Language(language)
 	if err != nil {
 		return nil, err
 	}
+	if prefix, err = normalize.Word(language, prefix); err != nil {
+		return nil, err
+	}
+	limit = min(limit, MaxCompletions)
 
-	// Cache the result
-	data, err := json.Marshal(entry)
+	own, err := s.repo.SearchWordbookWords(ctx, userID, language, prefix, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal entry: %w", err)
+		return nil, err
 	}
 
-	if cacheErr := s.repo.SetCachedDictionary(ctx, word, data, sourceFreeDic, cacheTTL); cacheErr != nil {
-		// Log but don't fail - caching is optional
-		fmt.Printf("Warning: failed to cache dictionary entry: %v\n", cacheErr)
+	completions := make([]models.Completion, 0, limit)
+	seen := make(map[string]bool, len(own))
+	for _, word := range own {
+		seen[word] = true
+		completions = append(completions, models.Completion{Word: word, InWordbook: true, FrequencyRank: s.Levels(language, word).FrequencyRank})
+	}
+	// The bundled and cached words are English
+	if language != DefaultLanguage {
+		return completions, nil
+	}
+	for _, word := range s.words.Complete(prefix, limit) {
+		if len(completions) == limit {
+			break
+		}
+		if !seen[word] {
+			completions = append(completions, models.Completion{Word: word, FrequencyRank: s.freq.Rank(word)})
+		}
 	}
+	return completions, nil
+}
 
-	return entry, nil
+// Suggest returns up to limit corrections for a word the dictionary doesn't
+// have, best first. Only English words get suggestions.
+func (s *DictionaryService) Suggest(language, word string, limit int) []models.SpellingSuggestion {
+	var suggestions []models.SpellingSuggestion
+	if language == DefaultLanguage {
+		suggestions = s.speller.Suggest(word, limit)
+	}
+	if suggestions == nil {
+		suggestions = []models.SpellingSuggestion{}
+	}
+	return suggestions
 }
 
-func (s *DictionaryService) fetchFromAPI(ctx context.Context, word string) (*models.DictionaryEntry, error) {
-	url := freeDictAPIURL + word
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// LookupWord returns the dictionary entry for word in language, annotated
+// with its word levels. Inflected English forms the dictionary has no entry
+// for resolve to the entry of their lemma, and words it only has without
+// diacritics ("naïve") to that entry, with QueriedForm set to the word as
+// asked; a form it has keeps its own entry, with Lemma offering the lemma. Entries are cached
+// under the word's key, but fetched in the case it is given in.
+func (s *DictionaryService) LookupWord(ctx context.Context, language, word string) (*models.DictionaryEntry, error) {
+	language, err := s.Language(language)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
+	}
+	form := normalize.Form(word)
+	if word, err = normalize.Word(language, word); err != nil {
+		return nil, err
 	}
 
-	resp, err := s.client.Do(req)
+	entry, err := s.resolve(ctx, language, word, form)
+	if stripped := normalize.StripDiacritics(word); errors.Is(err, ErrWordNotFound) && stripped != word {
+		if plain, plainErr := s.resolve(ctx, language, stripped, normalize.StripDiacritics(form)); plainErr == nil {
+			entry, err = plain, nil
+			if entry.QueriedForm == "" {
+				entry.QueriedForm = word
+			}
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("API request failed: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("word not found: %s", word)
+	// Levels come from the bundled lists rather than the cache, so they
+	// follow list updates without refetching
+	levels := s.Levels(language, entry.Word)
+	entry.FrequencyRank, entry.Zipf, entry.CEFRLevel = levels.FrequencyRank, levels.Zipf, levels.CEFRLevel
+	entry.Language = language
+	return entry, nil
+}
+
+// resolve finds the entry for a normalized word, given as typed in form,
+// falling back from inflected English forms without an entry to their lemma.
+func (s *DictionaryService) resolve(ctx context.Context, language, word, form string) (*models.DictionaryEntry, error) {
+	entry, err := s.lookup(ctx, language, word, form)
+	if language != DefaultLanguage {
+		return entry, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	lemma := s.Lemma(language, word)
+	if err == nil {
+		// A form with an entry of its own ("saw" the tool) is a headword,
+		// and its lemma is only offered alongside
+		if lemma != word {
+			entry.Lemma = lemma
+		}
+		return entry, nil
+	}
+	if !errors.Is(err, ErrWordNotFound) {
+		return nil, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+	if lemma != word {
+		lemmaEntry, lemmaErr := s.lookup(ctx, language, lemma, lemma)
+		if lemmaErr == nil {
+			lemmaEntry.QueriedForm = word
+			return lemmaEntry, nil
+		}
+		if !errors.Is(lemmaErr, ErrWordNotFound) {
+			return nil, lemmaErr
+		}
+		return nil, err
 	}
 
-	var apiResp FreeDictAPIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	// A phrase is listed with its verb uninflected, so "looked up" is
+	// found as "look up"
+	if head, rest, ok := strings.Cut(word, " "); ok {
+		if base := s.morph.Lemma(head, s.freq.Contains); base != head {
+			if baseEntry, baseErr := s.lookup(ctx, language, base+" "+rest, base+" "+rest); baseErr == nil {
+				baseEntry.QueriedForm = word
+				return baseEntry, nil
+			} else if !errors.Is(baseErr, ErrWordNotFound) {
+				return nil, baseErr
+			}
+		}
+		return nil, err
 	}
 
-	if len(apiResp) == 0 {
-		return nil, fmt.Errorf("empty response from API")
+	// The word may be an inflection of a word too rare for the frequency
+	// list, so try the likeliest bases the dictionary itself confirms.
+	for i, base := range s.morph.Bases(word) {
+		if i == maxBaseLookups {
+			break
+		}
+		baseEntry, baseErr := s.lookup(ctx, language, base, base)
+		if baseErr == nil {
+			baseEntry.QueriedForm = word
+			return baseEntry, nil
+		}
+		if !errors.Is(baseErr, ErrWordNotFound) {
+			return nil, baseErr
+		}
 	}
+	return nil, err
+}
 
-	// Normalize to our format
-	return s.normalizeResponse(apiResp), nil
+// Lemma returns the normalized lemma of word as far as it can be told
+// without the dictionary, or "" for an invalid word. Phrases and words of
+// other languages than English are only normalized.
+func (s *DictionaryService) Lemma(language, word string) string {
+	word, err := normalize.Word(language, word)
+	if err != nil || language != DefaultLanguage || strings.Contains(word, " ") {
+		return word
+	}
+	return s.morph.Lemma(word, s.freq.Contains)
 }
 
-func (s *DictionaryService) normalizeResponse(apiResp FreeDictAPIResponse) *models.DictionaryEntry {
-	first := apiResp[0]
+// lookup returns the entry for exactly word, from its custom entry, the
+// cache or the language's provider, with any curated overrides applied.
+// Providers are asked for form, the word in its own case.
+func (s *DictionaryService) lookup(ctx context.Context, language, word, form string) (*models.DictionaryEntry, error) {
+	// Curated entries take precedence over the cache and providers
+	custom, err := s.repo.GetCustomEntry(ctx, language, word)
+	if err != nil {
+		return nil, fmt.Errorf("custom entry lookup failed: %w", err)
+	}
+	if custom != nil && custom.Entry != nil {
+		entry := *custom.Entry
+		ApplyOverrides(&entry, custom.Overrides)
+		entry.Curated = true
+		return &entry, nil
+	}
 
-	entry := &models.DictionaryEntry{
-		Word:      first.Word,
-		Phonetics: make([]models.Phonetic, 0),
-		Meanings:  make([]models.Meaning, 0),
+	entry, err := s.lookupUpstream(ctx, language, word, form)
+	if err != nil {
+		return nil, err
+	}
+	if custom != nil {
+		ApplyOverrides(entry, custom.Overrides)
+		entry.Curated = true
 	}
+	return entry, nil
+}
 
-	if len(first.SourceUrls) > 0 {
-		entry.SourceURL = first.SourceUrls[0]
+// lookupUpstream returns the cached entry for word, fetching it as form from
+// the providers when it isn't cached.
+func (s *DictionaryService) lookupUpstream(ctx context.Context, language, word, form string) (*models.DictionaryEntry, error) {
+	// Check cache first
+	cached, err := s.repo.GetCachedDictionary(ctx, language, word)
+	if err != nil {
+		return nil, fmt.Errorf("cache lookup failed: %w", err)
 	}
+	if cached != nil {
+		// Older entries are preferably normalized again from their upstream
+		// payload, which may tell more than an upgrade can
+		if cached.SchemaVersion < models.CacheSchemaVersion {
+			if entry, err := s.renormalizeCached(ctx, language, word); err == nil {
+				s.cacheHits.Add(1)
+				return entry, nil
+			}
+		}
 
-	// Process phonetics - prefer ones with audio
-	for _, p := range first.Phonetics {
-		phonetic := models.Phonetic{
-			Text:      p.Text,
-			Audio:     p.Audio,
-			SourceURL: p.SourceURL,
+		entry, upgraded, err := entryCodec.decode(cached)
+		switch {
+		case err == nil:
+			s.cacheHits.Add(1)
+			if upgraded != nil {
+				s.storeUpgrade(ctx, cached, upgraded)
+			}
+			return entry, nil
+		case !errors.Is(err, ErrCacheOutdated):
+			return nil, err
 		}
-		entry.Phonetics = append(entry.Phonetics, phonetic)
+		// An entry that can't be upgraded is replaced like an expired one
 	}
+	s.cacheMisses.Add(1)
+	return s.fetch(ctx, language, word, form)
+}
 
-	// Process meanings
-	for _, m := range first.Meanings {
-		meaning := models.Meaning{
-			PartOfSpeech: m.PartOfSpeech,
-			Definitions:  make([]models.Definition, 0),
-			Synonyms:     m.Synonyms,
-			Antonyms:     m.Antonyms,
-		}
+// Refresh fetches word again from the language's providers, replacing its
+// cached entry. A word the providers no longer have is left to expire. A
+// cached word is fetched as its cached headword, whose case its key lacks.
+func (s *DictionaryService) Refresh(ctx context.Context, language, word string) error {
+	language, err := s.Language(language)
+	if err != nil {
+		return err
+	}
+	form := normalize.Form(word)
+	if word, err = normalize.Word(language, word); err != nil {
+		return err
+	}
+	if cached, err := s.repo.GetCacheRow(ctx, language, word); err == nil {
+		form = cachedHeadword(cached)
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("cache lookup failed: %w", err)
+	}
+	_, err = s.fetch(ctx, language, word, form)
+	return err
+}
 
-		for _, d := range m.Definitions {
-			def := models.Definition{
-				Definition: d.Definition,
-				Example:    d.Example,
-				Synonyms:   d.Synonyms,
-				Antonyms:   d.Antonyms,
-			}
-			meaning.Definitions = append(meaning.Definitions, def)
+// cachedHeadword returns the word a cached entry names, which keeps the case
+// its key folds away, or the key when the entry names another word.
+func cachedHeadword(cached *models.DictionaryCache) string {
+	var head struct {
+		Word string `json:"word"`
+	}
+	if json.Unmarshal(cached.Data, &head) != nil {
+		return cached.Word
+	}
+	if key, err := normalize.Word(cached.Language, head.Word); err != nil || key != cached.Word {
+		return cached.Word
+	}
+	return normalize.Form(head.Word)
+}
+
+// fetch returns the entry for exactly word, asking for it as form, from the
+// first of the language's providers that has it, and caches it.
+func (s *DictionaryService) fetch(ctx context.Context, language, word, form string) (*models.DictionaryEntry, error) {
+	var err error
+	var provider DictionaryProvider
+	var entry *models.DictionaryEntry
+	var raw []byte
+	for _, provider = range s.providers[language] {
+		entry, raw, err = fetchFrom(ctx, provider, form)
+		if !errors.Is(err, ErrWordNotFound) {
+			break
 		}
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		entry.Meanings = append(entry.Meanings, meaning)
+	// Cache the result
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal entry: %w", err)
+	}
+
+	if cacheErr := s.repo.SetCachedDictionary(ctx, language, word, data, raw, provider.Source(), cacheTTL); cacheErr != nil {
+		// Log but don't fail - caching is optional
+		fmt.Printf("Warning: failed to cache dictionary entry: %v\n", cacheErr)
+	}
+	if language == DefaultLanguage {
+		s.speller.Add(word)
+		s.words.Add(word)
+	}
+
+	return entry, nil
+}
+
+// fetchFrom fetches word from provider, along with the upstream payload the
+// entry was normalized from when the provider has one. Entries of sources
+// that don't tell homographs apart get a single one.
+func fetchFrom(ctx context.Context, provider DictionaryProvider, word string) (*models.DictionaryEntry, []byte, error) {
+	var entry *models.DictionaryEntry
+	var raw []byte
+	var err error
+	if rp, ok := provider.(RawDictionaryProvider); ok {
+		if raw, err = rp.FetchRaw(ctx, word); err != nil {
+			return nil, nil, err
+		}
+		entry, err = rp.Normalize(word, raw)
+	} else {
+		entry, err = provider.Fetch(ctx, word)
+	}
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return entry
+	ensureHomographs(entry)
+	return entry, raw, nil
 }
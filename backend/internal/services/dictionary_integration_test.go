@@ -0,0 +1,185 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/warriorguo/vocabulary/internal/repository"
+)
+
+// setupDictionaryDB starts a database with just the tables a lookup reads
+// and writes.
+func setupDictionaryDB(t *testing.T) *repository.Repository {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("testdb"),
+		postgres.WithUsername("test"),
+		postgres.WithPassword("test"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(5*time.Second)),
+	)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	t.Cleanup(func() { pgContainer.Terminate(ctx) })
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("failed to get connection string: %v", err)
+	}
+	pool, err := pgxpool.New(ctx, connStr)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrations := []string{
+		`CREATE TABLE dictionary_cache (
+			language VARCHAR(16) NOT NULL DEFAULT 'en',
+			word VARCHAR(128) NOT NULL,
+			data JSONB NOT NULL,
+			source VARCHAR(64) NOT NULL,
+			fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMPTZ NOT NULL,
+			schema_version INTEGER NOT NULL DEFAULT 1,
+			raw BYTEA
+		)`,
+		`CREATE UNIQUE INDEX idx_cache_language_word ON dictionary_cache(language, word)`,
+		`CREATE TABLE custom_entries (
+			language VARCHAR(16) NOT NULL,
+			word VARCHAR(128) NOT NULL,
+			entry JSONB,
+			overrides JSONB NOT NULL DEFAULT '[]',
+			updated_by VARCHAR(64) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (language, word)
+		)`,
+	}
+	for _, m := range migrations {
+		if _, err := pool.Exec(ctx, m); err != nil {
+			t.Fatalf("failed to run migration: %v", err)
+		}
+	}
+	return repository.New(pool)
+}
+
+func TestLookupWordFetchesWordAsTyped(t *testing.T) {
+	ctx := context.Background()
+	repo := setupDictionaryDB(t)
+
+	// Wiktionary titles are case-sensitive, so only the noun's own page exists
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path != "/definition/Haus" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"de": [{"partOfSpeech": "Noun", "definitions": [{"definition": "house"}]}]}`))
+	}))
+	defer server.Close()
+
+	svc := NewDictionaryService(repo)
+	svc.providers["de"] = []DictionaryProvider{NewWiktionary(server.Client(), server.URL+"/definition/", "de")}
+
+	entry, err := svc.LookupWord(ctx, "de", "Haus")
+	if err != nil {
+		t.Fatalf("LookupWord failed: %v (requests %v)", err, requests)
+	}
+	if entry.Word != "Haus" || entry.SourceURL != wiktionaryPageURL+"Haus" {
+		t.Errorf("expected the entry of Haus, got %q from %q", entry.Word, entry.SourceURL)
+	}
+
+	// The entry is cached under its key, so any spelling finds it
+	if _, err := repo.GetCachedDictionary(ctx, "de", "haus"); err != nil {
+		t.Errorf("expected the entry cached under haus: %v", err)
+	}
+	requests = nil
+	if _, err := svc.LookupWord(ctx, "de", "HAUS"); err != nil {
+		t.Errorf("expected HAUS to find the cached entry: %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no upstream requests, got %v", requests)
+	}
+
+	// Refreshing asks for the cached headword, not the key
+	if err := svc.Refresh(ctx, "de", "haus"); err != nil {
+		t.Errorf("Refresh failed: %v (requests %v)", err, requests)
+	}
+}
+
+func TestWarmCachesWordItself(t *testing.T) {
+	ctx := context.Background()
+	repo := setupDictionaryDB(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"en": [{"partOfSpeech": "Verb", "definitions": [{"definition": "to move"}]}]}`))
+	}))
+	defer server.Close()
+
+	svc := NewDictionaryService(repo)
+	svc.providers[DefaultLanguage] = []DictionaryProvider{NewWiktionary(server.Client(), server.URL+"/definition/", DefaultLanguage)}
+	warmer := NewCacheWarmer(repo, svc)
+
+	// An inflected form is cached as itself rather than as its lemma, so a
+	// resumed run finds it cached
+	if outcome, err := warmer.warm(ctx, DefaultLanguage, "went", nil); err != nil || outcome != warmFetched {
+		t.Fatalf("expected went to be fetched, got %v, %v", outcome, err)
+	}
+	if outcome, err := warmer.warm(ctx, DefaultLanguage, "went", nil); err != nil || outcome != warmCached {
+		t.Errorf("expected went to be cached, got %v, %v", outcome, err)
+	}
+}
+
+func TestLookupWordPrefersFormsWithOwnEntry(t *testing.T) {
+	ctx := context.Background()
+	repo := setupDictionaryDB(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/definition/saw", "/definition/see", "/definition/running", "/definition/run", "/definition/mouse":
+			w.Write([]byte(`{"en": [{"partOfSpeech": "Noun", "definitions": [{"definition": "` + r.URL.Path + `"}]}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	svc := NewDictionaryService(repo)
+	svc.providers[DefaultLanguage] = []DictionaryProvider{NewWiktionary(server.Client(), server.URL+"/definition/", DefaultLanguage)}
+
+	tests := []struct {
+		word, headword, queried, lemma string
+	}{
+		{"saw", "saw", "", ""},
+		// A form with its own entry offers its lemma rather than becoming it
+		{"running", "running", "", "run"},
+		// A form without one falls back to its lemma
+		{"mice", "mouse", "mice", ""},
+	}
+	for _, tt := range tests {
+		entry, err := svc.LookupWord(ctx, DefaultLanguage, tt.word)
+		if err != nil {
+			t.Errorf("LookupWord(%q) failed: %v", tt.word, err)
+			continue
+		}
+		if entry.Word != tt.headword || entry.QueriedForm != tt.queried || entry.Lemma != tt.lemma {
+			t.Errorf("LookupWord(%q) = %q queried as %q with lemma %q, want %q, %q, %q",
+				tt.word, entry.Word, entry.QueriedForm, entry.Lemma, tt.headword, tt.queried, tt.lemma)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/warriorguo/vocabulary/internal/models"
@@ -37,7 +39,7 @@ func (m *mockRepository) SetCachedDictionary(ctx context.Context, word string, d
 }
 
 func TestNormalizeResponse(t *testing.T) {
-	svc := &DictionaryService{}
+	svc := &FreeDictionaryAPI{}
 
 	apiResp := FreeDictAPIResponse{
 		{
@@ -98,6 +100,51 @@ func TestNormalizeResponse(t *testing.T) {
 	}
 }
 
+func TestNormalizeHomographs(t *testing.T) {
+	svc := &FreeDictionaryAPI{}
+	body := []byte(`[
+		{"word": "bass", "phonetics": [{"text": "/beɪs/"}], "meanings": [
+			{"partOfSpeech": "noun", "definitions": [{"definition": "A low range of sound."}], "synonyms": ["low"]},
+			{"partOfSpeech": "adjective", "definitions": [{"definition": "Low in pitch."}]}
+		], "sourceUrls": ["https://en.wiktionary.org/wiki/bass"]},
+		{"word": "bass", "phonetics": [{"text": "/bæs/"}, {"text": "/beɪs/"}], "origin": "Old English bærs", "meanings": [
+			{"partOfSpeech": "noun", "definitions": [{"definition": "A perch-like fish."}], "synonyms": ["low", "perch"]}
+		]}
+	]`)
+
+	entry, err := svc.Normalize("bass", body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entry.Phonetics) != 2 || entry.Phonetics[1].Text != "/bæs/" {
+		t.Errorf("expected both pronunciations once, got %+v", entry.Phonetics)
+	}
+	if len(entry.Meanings) != 2 {
+		t.Fatalf("expected meanings merged by part of speech, got %+v", entry.Meanings)
+	}
+	noun := entry.Meanings[0]
+	if noun.PartOfSpeech != "noun" || len(noun.Definitions) != 2 || noun.Definitions[1].Definition != "A perch-like fish." {
+		t.Errorf("expected the second homograph's noun sense appended, got %+v", noun)
+	}
+	if !reflect.DeepEqual(noun.Synonyms, []string{"low", "perch"}) {
+		t.Errorf("expected merged synonyms, got %q", noun.Synonyms)
+	}
+	if len(entry.Homographs) != 2 || entry.Homographs[0].Origin != "" || entry.Homographs[1].Origin != "Old English bærs" {
+		t.Fatalf("expected each result kept as a homograph, got %+v", entry.Homographs)
+	}
+	if fish := entry.Homographs[1]; len(fish.Phonetics) != 2 || len(fish.Meanings) != 1 {
+		t.Errorf("expected the fish homograph to keep its own phonetics and meanings, got %+v", fish)
+	}
+	if entry.SourceURL != "https://en.wiktionary.org/wiki/bass" {
+		t.Errorf("unexpected source URL %q", entry.SourceURL)
+	}
+
+	if _, err := svc.Normalize("bass", []byte(`[]`)); err == nil {
+		t.Error("expected an error for an empty response")
+	}
+}
+
 func TestFetchFromAPI(t *testing.T) {
 	// Create a mock server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -122,6 +169,10 @@ func TestFetchFromAPI(t *testing.T) {
 			json.NewEncoder(w).Encode(response)
 			return
 		}
+		if r.URL.EscapedPath() == "/api/v2/entries/en/and%2For" {
+			json.NewEncoder(w).Encode([]map[string]interface{}{{"word": "and/or"}})
+			return
+		}
 		if r.URL.Path == "/api/v2/entries/en/notfound" {
 			w.WriteHeader(http.StatusNotFound)
 			return
@@ -130,14 +181,34 @@ func TestFetchFromAPI(t *testing.T) {
 	}))
 	defer server.Close()
 
-	// Create service with custom client pointing to mock server
-	svc := &DictionaryService{
-		client: server.Client(),
-	}
+	// Create provider with custom client pointing to mock server
+	svc := NewFreeDictionaryAPI(server.Client(), server.URL+"/api/v2/entries/", "en")
+
+	t.Run("fetches and normalizes a word", func(t *testing.T) {
+		result, err := svc.Fetch(context.Background(), "hello")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Word != "hello" || len(result.Meanings) != 1 {
+			t.Errorf("unexpected entry: %+v", result)
+		}
+	})
+
+	t.Run("escapes the word", func(t *testing.T) {
+		result, err := svc.Fetch(context.Background(), "and/or")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Word != "and/or" {
+			t.Errorf("unexpected entry: %+v", result)
+		}
+	})
 
-	// Test successful lookup - we need to override the URL
-	// For this test, we'll test the normalizeResponse function instead
-	// since fetchFromAPI uses a hardcoded URL
+	t.Run("reports a missing word", func(t *testing.T) {
+		if _, err := svc.Fetch(context.Background(), "notfound"); !errors.Is(err, ErrWordNotFound) {
+			t.Errorf("expected ErrWordNotFound, got %v", err)
+		}
+	})
 
 	t.Run("normalizes response correctly", func(t *testing.T) {
 		apiResp := FreeDictAPIResponse{
@@ -177,15 +248,64 @@ func TestFetchFromAPI(t *testing.T) {
 }
 
 func TestLookupWordEmptyInput(t *testing.T) {
-	svc := &DictionaryService{}
+	svc := NewDictionaryService(nil)
 
-	_, err := svc.LookupWord(context.Background(), "")
+	_, err := svc.LookupWord(context.Background(), "en", "")
 	if err == nil {
 		t.Error("expected error for empty word")
 	}
 
-	_, err = svc.LookupWord(context.Background(), "   ")
+	_, err = svc.LookupWord(context.Background(), "en", "   ")
 	if err == nil {
 		t.Error("expected error for whitespace-only word")
 	}
 }
+
+func TestLookupWordUnsupportedLanguage(t *testing.T) {
+	svc := NewDictionaryService(nil)
+
+	if _, err := svc.LookupWord(context.Background(), "xx", "word"); !errors.Is(err, ErrUnsupportedLanguage) {
+		t.Errorf("expected ErrUnsupportedLanguage, got %v", err)
+	}
+}
+
+func TestDictionaryLanguage(t *testing.T) {
+	svc := NewDictionaryService(nil)
+
+	tests := map[string]string{
+		"":      "en",
+		"EN":    "en",
+		"de-AT": "de",
+		"fr":    "fr",
+	}
+	for tag, want := range tests {
+		if got, err := svc.Language(tag); err != nil || got != want {
+			t.Errorf("Language(%q) = %q, %v, want %q", tag, got, err, want)
+		}
+	}
+	if _, err := svc.Language("tlh"); !errors.Is(err, ErrUnsupportedLanguage) {
+		t.Errorf("expected ErrUnsupportedLanguage, got %v", err)
+	}
+}
+
+func TestDictionaryLemma(t *testing.T) {
+	svc := NewDictionaryService(nil)
+
+	tests := map[string]string{
+		"Running":      "run",
+		"mice":         "mouse",
+		"went":         "go",
+		"things":       "thing",
+		"building":     "building",
+		"news":         "news",
+		"teachers":     "teacher",
+		"ephemeral":    "ephemeral",
+		"look ups":     "look ups",
+		"  children  ": "child",
+	}
+	for word, want := range tests {
+		if got := svc.Lemma("en", word); got != want {
+			t.Errorf("Lemma(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Document formats accepted for text analysis
+const (
+	FormatText = "txt"
+	FormatHTML = "html"
+	FormatEPUB = "epub"
+	FormatSRT  = "srt"
+)
+
+// maxEPUBSize caps the bytes decompressed from an EPUB, across all of its
+// documents, so a small archive can't expand without bound
+const maxEPUBSize = 128 << 20 // 128 MiB
+
+// Document is the plain text extracted from an analyzed file, split into
+// paragraphs by blank lines.
+type Document struct {
+	Title string
+	Text  string
+}
+
+// ExtractDocument reads r in the given format and returns its readable text.
+// Unknown formats yield ErrUnsupportedFormat; files that can't be parsed
+// yield ErrMalformedImport.
+func ExtractDocument(format string, r io.Reader) (*Document, error) {
+	switch strings.ToLower(format) {
+	case "", "text", FormatText:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return &Document{Text: strings.TrimPrefix(string(data), "\ufeff")}, nil
+	case FormatHTML, "htm", "xhtml":
+		return extractHTML(r)
+	case FormatEPUB:
+		return extractEPUB(r, maxEPUBSize)
+	case FormatSRT:
+		return extractSRT(r)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// blockElements end a paragraph in extracted HTML text
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.Section: true, atom.Article: true, atom.Pre: true,
+}
+
+func extractHTML(r io.Reader) (*Document, error) {
+	doc := &Document{}
+	var text strings.Builder
+	var skip, inTitle bool
+
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			doc.Text = text.String()
+			return doc, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			switch a := atom.Lookup(name); {
+			case a == atom.Script || a == atom.Style:
+				skip = true
+			case a == atom.Title:
+				inTitle = true
+			case blockElements[a]:
+				text.WriteString("\n\n")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch a := atom.Lookup(name); {
+			case a == atom.Script || a == atom.Style:
+				skip = false
+			case a == atom.Title:
+				inTitle = false
+			case blockElements[a]:
+				text.WriteString("\n\n")
+			}
+		case html.TextToken:
+			switch {
+			case inTitle:
+				doc.Title = strings.TrimSpace(string(z.Text()))
+			case !skip:
+				text.Write(z.Text())
+			}
+		}
+	}
+}
+
+// epubContainer and epubPackage hold the parts of META-INF/container.xml and
+// the OPF package document needed to read chapters in reading order.
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Title    string `xml:"metadata>title"`
+	Manifest []struct {
+		ID   string `xml:"id,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// epubArchive reads the files of an EPUB, counting the bytes decompressed
+// against a budget.
+type epubArchive struct {
+	files     map[string]*zip.File
+	max, left int64
+}
+
+// read passes the decompressed contents of the named file to fn. It fails
+// with ErrMalformedImport once the archive has expanded beyond its budget.
+func (a *epubArchive) read(name string, fn func(io.Reader) error) error {
+	f, ok := a.files[name]
+	if !ok {
+		return fmt.Errorf("missing file %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Reading one byte past the budget means the archive expands too far
+	lr := &io.LimitedReader{R: rc, N: a.left + 1}
+	err = fn(lr)
+	if lr.N == 0 {
+		return fmt.Errorf("%w: EPUB expands beyond %d bytes", ErrMalformedImport, a.max)
+	}
+	a.left = lr.N - 1
+	return err
+}
+
+// extractEPUB reads the chapters of an EPUB in reading order, decompressing
+// at most maxSize bytes in total.
+func extractEPUB(r io.Reader, maxSize int64) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: not an EPUB file: %v", ErrMalformedImport, err)
+	}
+
+	archive := &epubArchive{files: make(map[string]*zip.File, len(zr.File)), max: maxSize, left: maxSize}
+	for _, f := range zr.File {
+		archive.files[f.Name] = f
+	}
+
+	doc := &Document{}
+	chapters, title, err := epubSpine(archive)
+	if err != nil {
+		return nil, err
+	}
+	doc.Title = title
+
+	var text strings.Builder
+	for _, name := range chapters {
+		if _, ok := archive.files[name]; !ok {
+			continue
+		}
+		err := archive.read(name, func(r io.Reader) error {
+			chapter, err := extractHTML(r)
+			if err != nil {
+				return err
+			}
+			text.WriteString(chapter.Text)
+			text.WriteString("\n\n")
+			return nil
+		})
+		if errors.Is(err, ErrMalformedImport) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrMalformedImport, name, err)
+		}
+	}
+	doc.Text = text.String()
+	return doc, nil
+}
+
+// epubSpine returns the chapter files of an EPUB in reading order along with
+// the book title. Archives without a usable package document fall back to
+// every HTML file, sorted by name.
+func epubSpine(archive *epubArchive) ([]string, string, error) {
+	var container epubContainer
+	err := readZipXML(archive, "META-INF/container.xml", &container)
+	if errors.Is(err, ErrMalformedImport) {
+		return nil, "", err
+	}
+	if err != nil || len(container.Rootfiles) == 0 {
+		var chapters []string
+		for name := range archive.files {
+			switch strings.ToLower(path.Ext(name)) {
+			case ".html", ".htm", ".xhtml":
+				chapters = append(chapters, name)
+			}
+		}
+		if len(chapters) == 0 {
+			return nil, "", fmt.Errorf("%w: EPUB has no content documents", ErrMalformedImport)
+		}
+		sort.Strings(chapters)
+		return chapters, "", nil
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := readZipXML(archive, opfPath, &pkg); err != nil {
+		if errors.Is(err, ErrMalformedImport) {
+			return nil, "", err
+		}
+		return nil, "", fmt.Errorf("%w: invalid package document: %v", ErrMalformedImport, err)
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		hrefs[item.ID] = item.Href
+	}
+
+	dir := path.Dir(opfPath)
+	chapters := make([]string, 0, len(pkg.Spine))
+	for _, ref := range pkg.Spine {
+		if href, ok := hrefs[ref.IDRef]; ok {
+			chapters = append(chapters, path.Join(dir, href))
+		}
+	}
+	return chapters, strings.TrimSpace(pkg.Title), nil
+}
+
+func readZipXML(archive *epubArchive, name string, v any) error {
+	return archive.read(name, func(r io.Reader) error {
+		return xml.NewDecoder(r).Decode(v)
+	})
+}
+
+var (
+	srtTiming = regexp.MustCompile(`^\d{1,2}:\d{2}:\d{2}[,.]\d{1,3}\s*-->`)
+	srtIndex  = regexp.MustCompile(`^\d+$`)
+	srtTag    = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+)
+
+// extractSRT drops cue numbers, timings and styling tags. Cues are joined
+// with spaces because subtitle sentences often run across several cues.
+func extractSRT(r io.Reader) (*Document, error) {
+	var text strings.Builder
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || srtIndex.MatchString(line) || srtTiming.MatchString(line) {
+			continue
+		}
+		text.WriteString(srtTag.ReplaceAllString(line, ""))
+		text.WriteByte(' ')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &Document{Text: text.String()}, nil
+}
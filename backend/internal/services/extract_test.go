@@ -0,0 +1,106 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExtractHTML(t *testing.T) {
+	page := `<html><head><title>Whales</title><style>p { color: red }</style></head>
+<body><p>Call me <em>Ishmael</em>.</p><script>var x = 1;</script><p>Some years ago&mdash;never mind.</p></body></html>`
+
+	doc, err := ExtractDocument(FormatHTML, strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Whales" {
+		t.Errorf("expected title Whales, got %q", doc.Title)
+	}
+	if got := splitSentences(doc.Text); len(got) != 2 || got[0] != "Call me Ishmael." || got[1] != "Some years ago—never mind." {
+		t.Errorf("unexpected sentences %q", got)
+	}
+	if strings.Contains(doc.Text, "color") || strings.Contains(doc.Text, "var x") {
+		t.Errorf("scripts and styles should be dropped: %q", doc.Text)
+	}
+}
+
+func TestExtractSRT(t *testing.T) {
+	srt := "1\n00:00:01,000 --> 00:00:03,000\n<i>It was the best</i>\n\n2\n00:00:03,500 --> 00:00:05,000\nof times.\n"
+
+	doc, err := ExtractDocument(FormatSRT, strings.NewReader(srt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := splitSentences(doc.Text); len(got) != 1 || got[0] != "It was the best of times." {
+		t.Errorf("cues should join into one sentence, got %q", got)
+	}
+}
+
+func TestExtractEPUB(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`,
+		"OEBPS/content.opf": `<package><metadata><title>Moby Dick</title></metadata>
+<manifest><item id="c1" href="one.xhtml"/><item id="c2" href="two.xhtml"/></manifest>
+<spine><itemref idref="c2"/><itemref idref="c1"/></spine></package>`,
+		"OEBPS/one.xhtml": `<html><body><p>Second chapter.</p></body></html>`,
+		"OEBPS/two.xhtml": `<html><body><p>First chapter.</p></body></html>`,
+	}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := ExtractDocument(FormatEPUB, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Moby Dick" {
+		t.Errorf("expected title from package metadata, got %q", doc.Title)
+	}
+	if got := splitSentences(doc.Text); len(got) != 2 || got[0] != "First chapter." {
+		t.Errorf("chapters should follow the spine order, got %q", got)
+	}
+}
+
+func TestExtractDocumentErrors(t *testing.T) {
+	if _, err := ExtractDocument("docx", strings.NewReader("")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+	if _, err := ExtractDocument(FormatEPUB, strings.NewReader("not a zip")); !errors.Is(err, ErrMalformedImport) {
+		t.Errorf("expected ErrMalformedImport, got %v", err)
+	}
+}
+
+func TestExtractEPUBLimitsExpansion(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("OEBPS/bomb.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("<html><body><p>" + strings.Repeat("a", 4096) + "</p></body></html>"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	if _, err := extractEPUB(bytes.NewReader(data), 1024); !errors.Is(err, ErrMalformedImport) {
+		t.Errorf("expected ErrMalformedImport past the budget, got %v", err)
+	}
+	doc, err := extractEPUB(bytes.NewReader(data), 8192)
+	if err != nil || !strings.Contains(doc.Text, "aaaa") {
+		t.Errorf("expected the chapter within the budget, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+const (
+	freeDictAPIURL = "https://api.dictionaryapi.dev/api/v2/entries/"
+	sourceFreeDic  = "freedictionaryapi"
+)
+
+// FreeDictionaryAPI is a DictionaryProvider backed by the Free Dictionary API
+// at dictionaryapi.dev, which is richest for English.
+type FreeDictionaryAPI struct {
+	client   *http.Client
+	baseURL  string
+	language string
+}
+
+// NewFreeDictionaryAPI creates a provider for one language of the API
+// rooted at baseURL, such as freeDictAPIURL.
+func NewFreeDictionaryAPI(client *http.Client, baseURL, language string) *FreeDictionaryAPI {
+	return &FreeDictionaryAPI{client: client, baseURL: baseURL, language: language}
+}
+
+// Source implements DictionaryProvider.
+func (a *FreeDictionaryAPI) Source() string { return sourceFreeDic }
+
+// FreeDictAPIResponse represents the raw API response
+type FreeDictAPIResponse []struct {
+	Word      string `json:"word"`
+	Phonetics []struct {
+		Text      string `json:"text"`
+		Audio     string `json:"audio"`
+		SourceURL string `json:"sourceUrl"`
+	} `json:"phonetics"`
+	Meanings []struct {
+		PartOfSpeech string `json:"partOfSpeech"`
+		Definitions  []struct {
+			Definition string   `json:"definition"`
+			Example    string   `json:"example"`
+			Synonyms   []string `json:"synonyms"`
+			Antonyms   []string `json:"antonyms"`
+		} `json:"definitions"`
+		Synonyms []string `json:"synonyms"`
+		Antonyms []string `json:"antonyms"`
+	} `json:"meanings"`
+	Origin     string   `json:"origin"`
+	SourceUrls []string `json:"sourceUrls"`
+}
+
+// Fetch implements DictionaryProvider.
+func (a *FreeDictionaryAPI) Fetch(ctx context.Context, word string) (*models.DictionaryEntry, error) {
+	body, err := a.FetchRaw(ctx, word)
+	if err != nil {
+		return nil, err
+	}
+	return a.Normalize(word, body)
+}
+
+// FetchRaw implements RawDictionaryProvider.
+func (a *FreeDictionaryAPI) FetchRaw(ctx context.Context, word string) ([]byte, error) {
+	// Escaped so phrases ("give up") and words with slashes ("and/or")
+	// stay one path segment
+	endpoint := a.baseURL + a.language + "/" + url.PathEscape(word)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrWordNotFound, word)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return body, nil
+}
+
+// Normalize implements RawDictionaryProvider.
+func (a *FreeDictionaryAPI) Normalize(word string, body []byte) (*models.DictionaryEntry, error) {
+	var apiResp FreeDictAPIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	if len(apiResp) == 0 {
+		return nil, fmt.Errorf("empty response from API")
+	}
+
+	// Normalize to our format
+	return a.normalizeResponse(apiResp), nil
+}
+
+// normalizeResponse reads each result of a response as a homograph, which
+// the API separates by origin.
+func (a *FreeDictionaryAPI) normalizeResponse(apiResp FreeDictAPIResponse) *models.DictionaryEntry {
+	first := apiResp[0]
+
+	entry := &models.DictionaryEntry{
+		Word:       first.Word,
+		Homographs: make([]models.Homograph, 0, len(apiResp)),
+	}
+
+	for _, result := range apiResp {
+		if entry.SourceURL == "" && len(result.SourceUrls) > 0 {
+			entry.SourceURL = result.SourceUrls[0]
+		}
+
+		homograph := models.Homograph{
+			Phonetics: make([]models.Phonetic, 0),
+			Origin:    result.Origin,
+			Meanings:  make([]models.Meaning, 0),
+		}
+
+		// Process phonetics - prefer ones with audio
+		for _, p := range result.Phonetics {
+			phonetic := models.Phonetic{
+				Text:      p.Text,
+				Audio:     p.Audio,
+				SourceURL: p.SourceURL,
+			}
+			homograph.Phonetics = append(homograph.Phonetics, phonetic)
+		}
+
+		// Process meanings
+		for _, m := range result.Meanings {
+			meaning := models.Meaning{
+				PartOfSpeech: m.PartOfSpeech,
+				Definitions:  make([]models.Definition, 0),
+				Synonyms:     m.Synonyms,
+				Antonyms:     m.Antonyms,
+			}
+
+			for _, d := range m.Definitions {
+				def := models.Definition{
+					Definition: d.Definition,
+					Example:    d.Example,
+					Synonyms:   d.Synonyms,
+					Antonyms:   d.Antonyms,
+				}
+				meaning.Definitions = append(meaning.Definitions, def)
+			}
+
+			homograph.Meanings = append(homograph.Meanings, meaning)
+		}
+
+		entry.Homographs = append(entry.Homographs, homograph)
+	}
+
+	mergeHomographs(entry)
+	return entry
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"math"
+	"strings"
+	"sync"
+)
+
+//go:embed data/frequency.txt
+var bundledFrequencyList string
+
+// zipfScale is the estimated occurrences per billion words of the rank 1
+// word, which puts "the" at a Zipf score of about 7.7
+const zipfScale = 5e7
+
+// FrequencyList maps headwords to their 1-based rank in a corpus frequency
+// list, where rank 1 is the most common word.
+type FrequencyList struct {
+	ranks map[string]int
+	words []string
+}
+
+// LoadFrequencyList reads one word per line, most frequent first. Blank lines
+// and lines starting with # are ignored; repeated words keep their first rank.
+func LoadFrequencyList(r io.Reader) (*FrequencyList, error) {
+	words, err := LoadWordList(r)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FrequencyList{ranks: make(map[string]int, len(words))}
+	for _, word := range words {
+		if _, ok := f.ranks[word]; ok {
+			continue
+		}
+		f.words = append(f.words, word)
+		f.ranks[word] = len(f.words)
+	}
+	return f, nil
+}
+
+// LoadWordList reads a lowercased word per line, ignoring blank lines and
+// lines starting with #.
+func LoadWordList(r io.Reader) ([]string, error) {
+	var words []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+
+	return words, scanner.Err()
+}
+
+var (
+	defaultFrequencyOnce sync.Once
+	defaultFrequency     *FrequencyList
+)
+
+// DefaultFrequencyList returns the frequency list bundled with the binary.
+func DefaultFrequencyList() *FrequencyList {
+	defaultFrequencyOnce.Do(func() {
+		// The bundled list is plain text compiled into the binary, so reading
+		// it can't fail.
+		defaultFrequency, _ = LoadFrequencyList(strings.NewReader(bundledFrequencyList))
+	})
+	return defaultFrequency
+}
+
+// Rank returns the word's rank, or 0 if it isn't in the list.
+func (f *FrequencyList) Rank(word string) int {
+	return f.ranks[word]
+}
+
+// Zipf estimates the word's Zipf score, the base-10 log of its occurrences
+// per billion words, from its rank by Zipf's law. Common function words
+// score around 7 and words met once in a novel around 3; words not in the
+// list score 0.
+func (f *FrequencyList) Zipf(word string) float64 {
+	rank := f.ranks[word]
+	if rank == 0 {
+		return 0
+	}
+	return math.Round(math.Log10(zipfScale/float64(rank))*100) / 100
+}
+
+// Contains reports whether the word is in the list.
+func (f *FrequencyList) Contains(word string) bool {
+	_, ok := f.ranks[word]
+	return ok
+}
+
+// Len returns the number of words in the list.
+func (f *FrequencyList) Len() int {
+	return len(f.words)
+}
+
+// Top returns the n most frequent words.
+func (f *FrequencyList) Top(n int) []string {
+	if n > len(f.words) {
+		n = len(f.words)
+	}
+	return f.words[:n]
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+// upgradeToHomographs upgrades cached entries of schema version 1, from
+// before homographs, to a single homograph, as they merged any the source
+// had.
+func upgradeToHomographs(data []byte) ([]byte, error) {
+	var entry models.DictionaryEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	ensureHomographs(&entry)
+	return json.Marshal(&entry)
+}
+
+// ensureHomographs gives an entry without homographs a single one holding
+// its phonetics and meanings, for sources that don't tell homographs apart.
+func ensureHomographs(entry *models.DictionaryEntry) {
+	if len(entry.Homographs) > 0 || len(entry.Meanings) == 0 {
+		return
+	}
+	phonetics := entry.Phonetics
+	if phonetics == nil {
+		phonetics = []models.Phonetic{}
+	}
+	entry.Homographs = []models.Homograph{{
+		Phonetics: slices.Clone(phonetics),
+		Meanings:  cloneMeanings(entry.Meanings),
+	}}
+}
+
+// mergeHomographs sets the phonetics and meanings of an entry from its
+// homographs: phonetics in order without repeats, and meanings of the same
+// part of speech combined in the order the homographs list them.
+func mergeHomographs(entry *models.DictionaryEntry) {
+	entry.Phonetics = make([]models.Phonetic, 0)
+	entry.Meanings = make([]models.Meaning, 0)
+
+	seenPhonetics := make(map[models.Phonetic]bool)
+	meaningIndex := make(map[string]int)
+	for _, h := range entry.Homographs {
+		for _, p := range h.Phonetics {
+			if !seenPhonetics[p] {
+				seenPhonetics[p] = true
+				entry.Phonetics = append(entry.Phonetics, p)
+			}
+		}
+
+		for _, m := range h.Meanings {
+			i, ok := meaningIndex[m.PartOfSpeech]
+			if !ok {
+				i = len(entry.Meanings)
+				meaningIndex[m.PartOfSpeech] = i
+				entry.Meanings = append(entry.Meanings, models.Meaning{
+					PartOfSpeech: m.PartOfSpeech,
+					Definitions:  make([]models.Definition, 0),
+				})
+			}
+			merged := &entry.Meanings[i]
+			merged.Synonyms = appendNew(merged.Synonyms, m.Synonyms...)
+			merged.Antonyms = appendNew(merged.Antonyms, m.Antonyms...)
+			merged.Definitions = append(merged.Definitions, m.Definitions...)
+		}
+	}
+}
+
+func cloneMeanings(meanings []models.Meaning) []models.Meaning {
+	cloned := make([]models.Meaning, len(meanings))
+	for i, m := range meanings {
+		m.Definitions = slices.Clone(m.Definitions)
+		cloned[i] = m
+	}
+	return cloned
+}
+
+// appendNew appends the words not already in list.
+func appendNew(list []string, words ...string) []string {
+	for _, w := range words {
+		if !slices.Contains(list, w) {
+			list = append(list, w)
+		}
+	}
+	return list
+}
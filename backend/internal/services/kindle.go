@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+)
+
+const kindleEnrichWorkers = 4
+
+// KindleImportOptions controls how a Kindle Vocabulary Builder file is imported
+type KindleImportOptions struct {
+	// Language keeps only words looked up in this language (e.g. "en");
+	// empty imports every language.
+	Language string
+	// Enrich looks each word up in the dictionary to fill its short definition.
+	// It is ignored on dry runs to avoid upstream calls.
+	Enrich bool
+	// Lemmatize stores inflected stems under their lemma.
+	Lemmatize bool
+	Policy    string
+	DryRun    bool
+}
+
+// ImportKindle imports the words of a Kindle vocab.db. Each word is stored by
+// its stem, with every lookup's usage sentence and book title as an
+// encounter context. Duplicates are merged by default, so importing the same
+// file again only adds contexts that weren't seen before.
+func (s *TransferService) ImportKindle(ctx context.Context, userID string, r io.Reader, opts KindleImportOptions) (*models.ImportReport, error) {
+	policy, err := resolvePolicy(opts.Policy, models.ImportMerge)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp("", "kindle-vocab-*.db")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	language := ""
+	if opts.Language != "" {
+		if language, err = s.dictSvc.Language(opts.Language); err != nil {
+			return nil, err
+		}
+	}
+	supported := func(language string) bool {
+		_, err := s.dictSvc.Language(language)
+		return err == nil
+	}
+
+	rows, err := readKindleVocab(ctx, f.Name(), language, supported)
+	if err != nil {
+		return nil, err
+	}
+	s.annotateRows(rows, opts.Lemmatize)
+
+	if opts.Enrich && !opts.DryRun {
+		s.enrichDefinitions(ctx, rows)
+	}
+
+	return s.applyImport(ctx, userID, rows, policy, opts.DryRun)
+}
+
+// readKindleVocab reads the WORDS, LOOKUPS and BOOK_INFO tables of a Kindle
+// vocab.db into import rows, one per looked-up word. Words in another
+// language than the given one, or in one the dictionary doesn't support, are
+// skipped.
+func readKindleVocab(ctx context.Context, path, language string, supported func(language string) bool) ([]importRow, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	query := `
+		SELECT w.id, COALESCE(w.word, ''), COALESCE(w.stem, ''), COALESCE(w.lang, ''),
+			COALESCE(w.timestamp, 0), COALESCE(l.usage, ''), COALESCE(l.pos, ''),
+			COALESCE(b.title, '')
+		FROM WORDS w
+		LEFT JOIN LOOKUPS l ON l.word_key = w.id
+		LEFT JOIN BOOK_INFO b ON b.id = l.book_key
+		ORDER BY w.timestamp, w.id, l.timestamp`
+
+	result, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a Kindle vocabulary database: %v", ErrMalformedImport, err)
+	}
+	defer result.Close()
+
+	var rows []importRow
+	var lastID string
+	for result.Next() {
+		var id, word, stem, lang, usage, pos, title string
+		var timestamp int64
+		if err := result.Scan(&id, &word, &stem, &lang, &timestamp, &usage, &pos, &title); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedImport, err)
+		}
+
+		if id != lastID {
+			lastID = id
+			rows = append(rows, newKindleRow(len(rows)+1, word, stem, lang, timestamp, language, supported))
+		}
+
+		row := &rows[len(rows)-1]
+		if usage = strings.TrimSpace(usage); usage != "" && row.err == nil {
+			row.entry.Contexts = append(row.entry.Contexts, models.WordContext{
+				Sentence:    usage,
+				SourceTitle: title,
+				Location:    truncate(pos, maxLocation),
+			})
+		}
+	}
+
+	return rows, result.Err()
+}
+
+func newKindleRow(n int, word, stem, lang string, timestamp int64, language string, supported func(string) bool) importRow {
+	entryLanguage := normalize.Language(lang)
+	if entryLanguage == "" {
+		entryLanguage = DefaultLanguage
+	}
+
+	lemma := strings.TrimSpace(stem)
+	if lemma == "" {
+		lemma = strings.TrimSpace(word)
+	}
+	key, err := normalize.Word(entryLanguage, lemma)
+	if err == nil {
+		lemma = key
+	}
+
+	row := importRow{row: n, entry: models.WordbookEntry{Language: entryLanguage, Word: lemma}}
+	if timestamp > 0 {
+		row.entry.CreatedAt = time.UnixMilli(timestamp)
+	}
+
+	switch {
+	case lemma == "":
+		row.err = fmt.Errorf("word is required")
+	case err != nil:
+		row.err = err
+	case language != "" && entryLanguage != normalize.Language(language):
+		row.skip = true
+		row.detail = "language " + lang
+	case !supported(entryLanguage):
+		row.skip = true
+		row.detail = "unsupported language " + lang
+	}
+	return row
+}
+
+// enrichDefinitions fills the short definition of each importable row from
+// the dictionary, a few words at a time. Words the dictionary can't define
+// are still imported, with the reason noted on the row.
+func (s *TransferService) enrichDefinitions(ctx context.Context, rows []importRow) {
+	sem := make(chan struct{}, kindleEnrichWorkers)
+	var wg sync.WaitGroup
+
+	for i := range rows {
+		row := &rows[i]
+		if row.err != nil || row.skip {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			entry, err := s.dictSvc.LookupWord(ctx, row.entry.Language, row.entry.Word)
+			if err != nil {
+				row.detail = "no definition: " + err.Error()
+				return
+			}
+			row.entry.ShortDefinition = ShortDefinition(entry)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// ShortDefinition returns the first definition of a dictionary entry, which
+// is what the app stores as a wordbook entry's short definition.
+func ShortDefinition(entry *models.DictionaryEntry) string {
+	for _, m := range entry.Meanings {
+		for _, d := range m.Definitions {
+			if d.Definition != "" {
+				return d.Definition
+			}
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeKindleVocab creates a minimal vocab.db with the tables the importer reads.
+func writeKindleVocab(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "vocab.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmts := []string{
+		`CREATE TABLE WORDS (id TEXT PRIMARY KEY, word TEXT, stem TEXT, lang TEXT, category INTEGER DEFAULT 0, timestamp INTEGER DEFAULT 0, profileid TEXT)`,
+		`CREATE TABLE LOOKUPS (id TEXT PRIMARY KEY, word_key TEXT, book_key TEXT, dict_key TEXT, pos TEXT, usage TEXT, timestamp INTEGER DEFAULT 0)`,
+		`CREATE TABLE BOOK_INFO (id TEXT PRIMARY KEY, asin TEXT, guid TEXT, lang TEXT, title TEXT, authors TEXT)`,
+		`INSERT INTO BOOK_INFO VALUES ('b1', 'B00', 'g1', 'en', 'Moby Dick', 'Herman Melville')`,
+		`INSERT INTO WORDS VALUES ('en:ephemeral', 'ephemeral', 'Ephemeral', 'en', 0, 1700000000000, '')`,
+		`INSERT INTO WORDS VALUES ('en:sailing', 'sailing', 'sail', 'en-US', 0, 1700000100000, '')`,
+		`INSERT INTO WORDS VALUES ('de:Haus', 'Haus', 'Haus', 'de', 0, 1700000200000, '')`,
+		`INSERT INTO WORDS VALUES ('tlh:Qapla', 'Qapla', 'Qapla', 'tlh', 0, 1700000300000, '')`,
+		`INSERT INTO LOOKUPS VALUES ('l1', 'en:ephemeral', 'b1', '', '1024', 'An ephemeral joy.', 1700000000000)`,
+		`INSERT INTO LOOKUPS VALUES ('l2', 'en:ephemeral', 'b1', '', '2048', 'Ephemeral as foam.', 1700000050000)`,
+		`INSERT INTO LOOKUPS VALUES ('l3', 'en:sailing', 'b1', '', '4096', 'They went sailing.', 1700000100000)`,
+		`INSERT INTO LOOKUPS VALUES ('l4', 'de:Haus', 'b1', '', '10', 'Das Haus.', 1700000200000)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return path
+}
+
+// kindleLanguages are the languages the tests treat as supported
+func kindleLanguages(language string) bool {
+	return language == "en" || language == "de"
+}
+
+func TestReadKindleVocab(t *testing.T) {
+	rows, err := readKindleVocab(context.Background(), writeKindleVocab(t), "en", kindleLanguages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
+	}
+
+	ephemeral := rows[0]
+	if ephemeral.entry.Word != "ephemeral" || ephemeral.err != nil || ephemeral.skip {
+		t.Fatalf("unexpected first row: %+v", ephemeral)
+	}
+	if len(ephemeral.entry.Contexts) != 2 {
+		t.Fatalf("expected 2 contexts, got %d", len(ephemeral.entry.Contexts))
+	}
+	if wc := ephemeral.entry.Contexts[0]; wc.Sentence != "An ephemeral joy." || wc.SourceTitle != "Moby Dick" || wc.Location != "1024" {
+		t.Errorf("unexpected context: %+v", wc)
+	}
+	if got := ephemeral.entry.CreatedAt.UnixMilli(); got != 1700000000000 {
+		t.Errorf("created_at should come from the word's timestamp, got %d", got)
+	}
+
+	if rows[1].entry.Word != "sail" || rows[1].entry.Language != "en" || rows[1].skip {
+		t.Errorf("words should be imported by stem under their primary language, got %+v", rows[1])
+	}
+	if !rows[2].skip || rows[2].detail != "language de" {
+		t.Errorf("other languages should be skipped, got %+v", rows[2])
+	}
+
+	all, err := readKindleVocab(context.Background(), writeKindleVocab(t), "", kindleLanguages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[2].skip || all[2].entry.Word != "haus" {
+		t.Errorf("empty language should import every supported language, got %+v", all[2])
+	}
+	if !all[3].skip || all[3].detail != "unsupported language tlh" {
+		t.Errorf("unsupported languages should be skipped, got %+v", all[3])
+	}
+}
+
+func TestReadKindleVocabRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.db")
+	if err := os.WriteFile(path, []byte("word,short_definition\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readKindleVocab(context.Background(), path, "en", kindleLanguages); !errors.Is(err, ErrMalformedImport) {
+		t.Errorf("expected ErrMalformedImport, got %v", err)
+	}
+}
@@ -0,0 +1,500 @@
+package services
+
+import "strings"
+
+// metaphoneLength is the length of Double Metaphone codes
+const metaphoneLength = 4
+
+// DoubleMetaphone returns the primary and alternate Double Metaphone codes of
+// word, after Lawrence Philips' algorithm. Words that sound alike share at
+// least one code. Letters outside A-Z are ignored.
+func DoubleMetaphone(word string) (primary, alternate string) {
+	m := &metaphone{value: strings.ToUpper(strings.TrimSpace(word))}
+	m.slavoGermanic = strings.ContainsAny(m.value, "WK") || strings.Contains(m.value, "CZ")
+	m.encode()
+	return m.primary.String(), m.alternate.String()
+}
+
+type metaphone struct {
+	value              string
+	slavoGermanic      bool
+	primary, alternate strings.Builder
+}
+
+func (m *metaphone) encode() {
+	n := len(m.value)
+	i := 0
+	if m.contains(0, 2, "GN", "KN", "PN", "WR", "PS") {
+		i = 1
+	}
+	if m.at(0) == 'X' {
+		m.add("S")
+		i = 1
+	}
+
+	for !m.complete() && i < n {
+		switch c := m.at(i); c {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			if i == 0 {
+				m.add("A")
+			}
+			i++
+		case 'B':
+			m.add("P")
+			i = m.skip(i, 'B')
+		case 'C':
+			i = m.c(i)
+		case 'D':
+			i = m.d(i)
+		case 'F', 'K', 'N', 'Q', 'V':
+			code := string(c)
+			switch c {
+			case 'Q':
+				code = "K"
+			case 'V':
+				code = "F"
+			}
+			m.add(code)
+			i = m.skip(i, c)
+		case 'G':
+			i = m.g(i)
+		case 'H':
+			if (i == 0 || isVowel(m.at(i-1))) && isVowel(m.at(i+1)) {
+				m.add("H")
+				i += 2
+			} else {
+				i++
+			}
+		case 'J':
+			i = m.j(i)
+		case 'L':
+			i = m.l(i)
+		case 'M':
+			m.add("M")
+			if m.at(i+1) == 'M' || (m.contains(i-1, 3, "UMB") && (i+1 == n-1 || m.contains(i+2, 2, "ER"))) {
+				i += 2
+			} else {
+				i++
+			}
+		case 'P':
+			if m.at(i+1) == 'H' {
+				m.add("F")
+				i += 2
+			} else {
+				m.add("P")
+				if m.contains(i+1, 1, "P", "B") {
+					i += 2
+				} else {
+					i++
+				}
+			}
+		case 'R':
+			if i == n-1 && !m.slavoGermanic && m.contains(i-2, 2, "IE") && !m.contains(i-4, 2, "ME", "MA") {
+				m.addAlt("", "R")
+			} else {
+				m.add("R")
+			}
+			i = m.skip(i, 'R')
+		case 'S':
+			i = m.s(i)
+		case 'T':
+			i = m.t(i)
+		case 'W':
+			i = m.w(i)
+		case 'X':
+			if !(i == n-1 && (m.contains(i-3, 3, "IAU", "EAU") || m.contains(i-2, 2, "AU", "OU"))) {
+				m.add("KS")
+			}
+			if m.contains(i+1, 1, "C", "X") {
+				i += 2
+			} else {
+				i++
+			}
+		case 'Z':
+			if m.at(i+1) == 'H' {
+				m.add("J")
+				i += 2
+				break
+			}
+			if m.contains(i+1, 2, "ZO", "ZI", "ZA") || (m.slavoGermanic && i > 0 && m.at(i-1) != 'T') {
+				m.addAlt("S", "TS")
+			} else {
+				m.add("S")
+			}
+			i = m.skip(i, 'Z')
+		default:
+			i++
+		}
+	}
+}
+
+func (m *metaphone) c(i int) int {
+	switch {
+	case m.chiaOrGermanicCh(i):
+		m.add("K")
+		return i + 2
+	case i == 0 && m.contains(i, 6, "CAESAR"):
+		m.add("S")
+		return i + 2
+	case m.contains(i, 2, "CH"):
+		return m.ch(i)
+	case m.contains(i, 2, "CZ") && !m.contains(i-2, 4, "WICZ"):
+		m.addAlt("S", "X")
+		return i + 2
+	case m.contains(i+1, 3, "CIA"):
+		m.add("X")
+		return i + 3
+	case m.contains(i, 2, "CC") && !(i == 1 && m.at(0) == 'M'):
+		if m.contains(i+2, 1, "I", "E", "H") && !m.contains(i+2, 2, "HU") {
+			if (i == 1 && m.at(i-1) == 'A') || m.contains(i-1, 5, "UCCEE", "UCCES") {
+				m.add("KS")
+			} else {
+				m.add("X")
+			}
+			return i + 3
+		}
+		m.add("K")
+		return i + 2
+	case m.contains(i, 2, "CK", "CG", "CQ"):
+		m.add("K")
+		return i + 2
+	case m.contains(i, 2, "CI", "CE", "CY"):
+		if m.contains(i, 3, "CIO", "CIE", "CIA") {
+			m.addAlt("S", "X")
+		} else {
+			m.add("S")
+		}
+		return i + 2
+	}
+
+	m.add("K")
+	switch {
+	case m.contains(i+1, 2, " C", " Q", " G"):
+		return i + 3
+	case m.contains(i+1, 1, "C", "K", "Q") && !m.contains(i+1, 2, "CE", "CI"):
+		return i + 2
+	}
+	return i + 1
+}
+
+// chiaOrGermanicCh matches "CHIA" and the Germanic "ACH" as in "bacher".
+func (m *metaphone) chiaOrGermanicCh(i int) bool {
+	switch {
+	case m.contains(i, 4, "CHIA"):
+		return true
+	case i <= 1, isVowel(m.at(i - 2)), !m.contains(i-1, 3, "ACH"):
+		return false
+	}
+	c := m.at(i + 2)
+	return (c != 'I' && c != 'E') || m.contains(i-2, 6, "BACHER", "MACHER")
+}
+
+func (m *metaphone) ch(i int) int {
+	switch {
+	case i > 0 && m.contains(i, 4, "CHAE"):
+		m.addAlt("K", "X")
+	case i == 0 && (m.contains(i+1, 5, "HARAC", "HARIS") || m.contains(i+1, 3, "HOR", "HYM", "HIA", "HEM")) && !m.contains(0, 5, "CHORE"):
+		// Greek roots: chorus, chemistry
+		m.add("K")
+	case m.contains(0, 4, "VAN ", "VON ") || m.contains(0, 3, "SCH") ||
+		m.contains(i-2, 6, "ORCHES", "ARCHIT", "ORCHID") ||
+		m.contains(i+2, 1, "T", "S") ||
+		((m.contains(i-1, 1, "A", "O", "U", "E") || i == 0) &&
+			(m.contains(i+2, 1, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ") || i+1 == len(m.value)-1)):
+		m.add("K")
+	case i > 0:
+		if m.contains(0, 2, "MC") {
+			m.add("K")
+		} else {
+			m.addAlt("X", "K")
+		}
+	default:
+		m.add("X")
+	}
+	return i + 2
+}
+
+func (m *metaphone) d(i int) int {
+	switch {
+	case m.contains(i, 2, "DG"):
+		if m.contains(i+2, 1, "I", "E", "Y") {
+			m.add("J")
+			return i + 3
+		}
+		m.add("TK")
+		return i + 2
+	case m.contains(i, 2, "DT", "DD"):
+		m.add("T")
+		return i + 2
+	}
+	m.add("T")
+	return i + 1
+}
+
+func (m *metaphone) g(i int) int {
+	switch {
+	case m.at(i+1) == 'H':
+		return m.gh(i)
+	case m.at(i+1) == 'N':
+		switch {
+		case i == 1 && isVowel(m.at(0)) && !m.slavoGermanic:
+			m.addAlt("KN", "N")
+		case !m.contains(i+2, 2, "EY") && m.at(i+1) != 'Y' && !m.slavoGermanic:
+			m.addAlt("N", "KN")
+		default:
+			m.add("KN")
+		}
+		return i + 2
+	case m.contains(i+1, 2, "LI") && !m.slavoGermanic:
+		m.addAlt("KL", "L")
+		return i + 2
+	case i == 0 && (m.at(i+1) == 'Y' || m.contains(i+1, 2, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")):
+		m.addAlt("K", "J")
+		return i + 2
+	case (m.contains(i+1, 2, "ER") || m.at(i+1) == 'Y') &&
+		!m.contains(0, 6, "DANGER", "RANGER", "MANGER") &&
+		!m.contains(i-1, 1, "E", "I") && !m.contains(i-1, 3, "RGY", "OGY"):
+		m.addAlt("K", "J")
+		return i + 2
+	case m.contains(i+1, 1, "E", "I", "Y") || m.contains(i-1, 4, "AGGI", "OGGI"):
+		switch {
+		case m.contains(0, 4, "VAN ", "VON ") || m.contains(0, 3, "SCH") || m.contains(i+1, 2, "ET"):
+			m.add("K")
+		case m.contains(i+1, 3, "IER"):
+			m.add("J")
+		default:
+			m.addAlt("J", "K")
+		}
+		return i + 2
+	}
+	m.add("K")
+	return m.skip(i, 'G')
+}
+
+func (m *metaphone) gh(i int) int {
+	switch {
+	case i > 0 && !isVowel(m.at(i-1)):
+		m.add("K")
+	case i == 0:
+		if m.at(i+2) == 'I' {
+			m.add("J")
+		} else {
+			m.add("K")
+		}
+	case (i > 1 && m.contains(i-2, 1, "B", "H", "D")) ||
+		(i > 2 && m.contains(i-3, 1, "B", "H", "D")) ||
+		(i > 3 && m.contains(i-4, 1, "B", "H")):
+		// Silent, as in "hugh" and "bough"
+	case i > 2 && m.at(i-1) == 'U' && m.contains(i-3, 1, "C", "G", "L", "R", "T"):
+		// "laugh", "tough"
+		m.add("F")
+	case i > 0 && m.at(i-1) != 'I':
+		m.add("K")
+	}
+	return i + 2
+}
+
+func (m *metaphone) j(i int) int {
+	n := len(m.value)
+	if m.contains(i, 4, "JOSE") || m.contains(0, 4, "SAN ") {
+		if (i == 0 && m.at(i+4) == ' ') || n == 4 || m.contains(0, 4, "SAN ") {
+			m.add("H")
+		} else {
+			m.addAlt("J", "H")
+		}
+		return i + 1
+	}
+
+	switch {
+	case i == 0:
+		m.addAlt("J", "A")
+	case isVowel(m.at(i-1)) && !m.slavoGermanic && (m.at(i+1) == 'A' || m.at(i+1) == 'O'):
+		m.addAlt("J", "H")
+	case i == n-1:
+		m.addAlt("J", "")
+	case !m.contains(i+1, 1, "L", "T", "K", "S", "N", "M", "B", "Z") && !m.contains(i-1, 1, "S", "K", "L"):
+		m.add("J")
+	}
+	return m.skip(i, 'J')
+}
+
+func (m *metaphone) l(i int) int {
+	if m.at(i+1) != 'L' {
+		m.add("L")
+		return i + 1
+	}
+
+	n := len(m.value)
+	spanish := (i == n-3 && m.contains(i-1, 4, "ILLO", "ILLA", "ALLE")) ||
+		((m.contains(n-2, 2, "AS", "OS") || m.contains(n-1, 1, "A", "O")) && m.contains(i-1, 4, "ALLE"))
+	if spanish {
+		m.addAlt("L", "")
+	} else {
+		m.add("L")
+	}
+	return i + 2
+}
+
+func (m *metaphone) s(i int) int {
+	n := len(m.value)
+	switch {
+	case m.contains(i-1, 3, "ISL", "YSL"):
+		// Silent, as in "island"
+		return i + 1
+	case i == 0 && m.contains(i, 5, "SUGAR"):
+		m.addAlt("X", "S")
+		return i + 1
+	case m.contains(i, 2, "SH"):
+		if m.contains(i+1, 4, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			m.add("S")
+		} else {
+			m.add("X")
+		}
+		return i + 2
+	case m.contains(i, 3, "SIO", "SIA") || m.contains(i, 4, "SIAN"):
+		if m.slavoGermanic {
+			m.add("S")
+		} else {
+			m.addAlt("S", "X")
+		}
+		return i + 3
+	case (i == 0 && m.contains(i+1, 1, "M", "N", "L", "W")) || m.contains(i+1, 1, "Z"):
+		m.addAlt("S", "X")
+		if m.contains(i+1, 1, "Z") {
+			return i + 2
+		}
+		return i + 1
+	case m.contains(i, 2, "SC"):
+		return m.sc(i)
+	}
+
+	if i == n-1 && m.contains(i-2, 2, "AI", "OI") {
+		// French endings, as in "bois"
+		m.addAlt("", "S")
+	} else {
+		m.add("S")
+	}
+	if m.contains(i+1, 1, "S", "Z") {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) sc(i int) int {
+	switch {
+	case m.at(i+2) == 'H':
+		switch {
+		case m.contains(i+3, 2, "ER", "EN"):
+			m.addAlt("X", "SK")
+		case m.contains(i+3, 2, "OO", "UY", "ED", "EM"):
+			m.add("SK")
+		case i == 0 && !isVowel(m.at(3)) && m.at(3) != 'W':
+			m.addAlt("X", "S")
+		default:
+			m.add("X")
+		}
+	case m.contains(i+2, 1, "I", "E", "Y"):
+		m.add("S")
+	default:
+		m.add("SK")
+	}
+	return i + 3
+}
+
+func (m *metaphone) t(i int) int {
+	switch {
+	case m.contains(i, 4, "TION"), m.contains(i, 3, "TIA", "TCH"):
+		m.add("X")
+		return i + 3
+	case m.contains(i, 2, "TH"), m.contains(i, 3, "TTH"):
+		if m.contains(i+2, 2, "OM", "AM") || m.contains(0, 4, "VAN ", "VON ") || m.contains(0, 3, "SCH") {
+			m.add("T")
+		} else {
+			m.addAlt("0", "T")
+		}
+		return i + 2
+	}
+	m.add("T")
+	if m.contains(i+1, 1, "T", "D") {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) w(i int) int {
+	n := len(m.value)
+	switch {
+	case m.contains(i, 2, "WR"):
+		m.add("R")
+		return i + 2
+	case i == 0 && (isVowel(m.at(i+1)) || m.contains(i, 2, "WH")):
+		if isVowel(m.at(i + 1)) {
+			m.addAlt("A", "F")
+		} else {
+			m.add("A")
+		}
+	case (i == n-1 && isVowel(m.at(i-1))) ||
+		m.contains(i-1, 5, "EWSKI", "EWSKY", "OWSKI", "OWSKY") || m.contains(0, 3, "SCH"):
+		m.addAlt("", "F")
+	case m.contains(i, 4, "WICZ", "WITZ"):
+		m.addAlt("TS", "FX")
+		return i + 4
+	}
+	return i + 1
+}
+
+// at returns the byte at i, or 0 outside the word.
+func (m *metaphone) at(i int) byte {
+	if i < 0 || i >= len(m.value) {
+		return 0
+	}
+	return m.value[i]
+}
+
+// contains reports whether the length bytes at start equal one of options.
+func (m *metaphone) contains(start, length int, options ...string) bool {
+	if start < 0 || start+length > len(m.value) {
+		return false
+	}
+	sub := m.value[start : start+length]
+	for _, o := range options {
+		if sub == o {
+			return true
+		}
+	}
+	return false
+}
+
+// skip advances past the letter at i and a doubled copy of it.
+func (m *metaphone) skip(i int, c byte) int {
+	if m.at(i+1) == c {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) add(code string) {
+	m.addAlt(code, code)
+}
+
+func (m *metaphone) addAlt(primary, alternate string) {
+	appendCode(&m.primary, primary)
+	appendCode(&m.alternate, alternate)
+}
+
+func (m *metaphone) complete() bool {
+	return m.primary.Len() >= metaphoneLength && m.alternate.Len() >= metaphoneLength
+}
+
+func appendCode(b *strings.Builder, code string) {
+	if room := metaphoneLength - b.Len(); room > 0 {
+		if len(code) > room {
+			code = code[:room]
+		}
+		b.WriteString(code)
+	}
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("AEIOUY", c) >= 0
+}
@@ -0,0 +1,45 @@
+package services
+
+import "testing"
+
+func TestDoubleMetaphone(t *testing.T) {
+	tests := []struct {
+		word               string
+		primary, alternate string
+	}{
+		{"Smith", "SM0", "XMT"},
+		{"Schmidt", "XMT", "SMT"},
+		{"Thomas", "TMS", "TMS"},
+		{"knight", "NT", "NT"},
+		{"phone", "FN", "FN"},
+		{"laugh", "LF", "LF"},
+		{"character", "KRKT", "KRKT"},
+		{"judge", "JJ", "AJ"},
+		{"nation", "NXN", "NXN"},
+		{"Xavier", "SF", "SFR"},
+	}
+
+	for _, tt := range tests {
+		primary, alternate := DoubleMetaphone(tt.word)
+		if primary != tt.primary || alternate != tt.alternate {
+			t.Errorf("DoubleMetaphone(%q) = %q/%q, want %q/%q", tt.word, primary, alternate, tt.primary, tt.alternate)
+		}
+	}
+}
+
+func TestDoubleMetaphoneSoundAlikes(t *testing.T) {
+	pairs := [][2]string{
+		{"fone", "phone"},
+		{"nite", "night"},
+		{"kat", "cat"},
+		{"sycology", "psychology"},
+	}
+
+	for _, pair := range pairs {
+		a1, a2 := DoubleMetaphone(pair[0])
+		b1, b2 := DoubleMetaphone(pair[1])
+		if a1 != b1 && a1 != b2 && a2 != b1 && a2 != b2 {
+			t.Errorf("%q (%s/%s) and %q (%s/%s) should share a code", pair[0], a1, a2, pair[1], b1, b2)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+//go:embed data/irregular.txt
+var bundledIrregularForms string
+
+// Morphology reduces inflected English words to their lemma, using a table
+// of irregular forms and rules that undo regular suffixes.
+type Morphology struct {
+	irregular map[string]string
+}
+
+// LoadMorphology reads irregular forms as "form lemma" lines. Blank lines and
+// lines starting with # are ignored.
+func LoadMorphology(r io.Reader) (*Morphology, error) {
+	m := &Morphology{irregular: make(map[string]string)}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(strings.ToLower(line))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"form lemma\", got %q", n, line)
+		}
+		m.irregular[fields[0]] = fields[1]
+	}
+
+	return m, scanner.Err()
+}
+
+var (
+	defaultMorphologyOnce sync.Once
+	defaultMorphology     *Morphology
+)
+
+// DefaultMorphology returns the analyzer built from the bundled irregular
+// forms table.
+func DefaultMorphology() *Morphology {
+	defaultMorphologyOnce.Do(func() {
+		var err error
+		if defaultMorphology, err = LoadMorphology(strings.NewReader(bundledIrregularForms)); err != nil {
+			panic("bundled irregular forms: " + err.Error())
+		}
+	})
+	return defaultMorphology
+}
+
+// Lemma returns the lemma of word: its entry in the irregular table if it has
+// one, otherwise the first base left by undoing a suffix that isWord accepts,
+// otherwise word itself. Comparative -er and -est are only undone for words
+// isWord doesn't know, so "teacher" and "corner" keep their own entries.
+func (m *Morphology) Lemma(word string, isWord func(string) bool) string {
+	if lemma, ok := m.irregular[word]; ok {
+		return lemma
+	}
+	for _, base := range inflectionBases(word, !isWord(word)) {
+		if isWord(base) {
+			return base
+		}
+	}
+	return word
+}
+
+// Bases returns the possible lemmas of word, most likely first, for callers
+// that check them against something other than a word list. A word in the
+// irregular table has exactly one.
+func (m *Morphology) Bases(word string) []string {
+	if lemma, ok := m.irregular[word]; ok {
+		if lemma == word {
+			return nil
+		}
+		return []string{lemma}
+	}
+	return inflectionBases(word, true)
+}
+
+// Forms returns every form in the irregular table.
+func (m *Morphology) Forms() []string {
+	forms := make([]string, 0, len(m.irregular))
+	for form := range m.irregular {
+		forms = append(forms, form)
+	}
+	return forms
+}
+
+// inflectionBases lists candidate base forms of word, most specific first.
+// Comparative suffixes are only considered when comparatives is set.
+func inflectionBases(word string, comparatives bool) []string {
+	var bases []string
+	push := func(base string) {
+		if !slices.Contains(bases, base) {
+			bases = append(bases, base)
+		}
+	}
+	add := func(suffix string, replacements ...string) {
+		stem, ok := strings.CutSuffix(word, suffix)
+		if !ok || len(stem) < 2 {
+			return
+		}
+		for _, r := range replacements {
+			push(stem + r)
+		}
+		// Undo consonant doubling: stopped -> stop, bigger -> big
+		if n := len(stem); n >= 3 && stem[n-1] == stem[n-2] && !strings.ContainsRune("aeiou", rune(stem[n-1])) {
+			push(stem[:n-1])
+		}
+	}
+
+	add("ies", "y")
+	add("ied", "y")
+	if comparatives {
+		add("ier", "y")
+		add("iest", "y")
+	}
+	add("ves", "f", "fe")
+	add("es", "e", "")
+	add("s", "")
+	add("ed", "e", "")
+	add("ing", "e", "")
+	if comparatives {
+		add("er", "", "e")
+		add("est", "", "e")
+	}
+	return bases
+}
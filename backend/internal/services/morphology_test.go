@@ -0,0 +1,64 @@
+package services
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMorphologyLemma(t *testing.T) {
+	known := map[string]bool{
+		"study": true, "stop": true, "box": true, "use": true, "us": true, "wolf": true, "big": true,
+		"make": true, "walk": true, "run": true, "teach": true, "teacher": true, "news": true, "new": true,
+	}
+	isWord := func(w string) bool { return known[w] }
+	m := DefaultMorphology()
+
+	tests := map[string]string{
+		"studies":   "study",
+		"studied":   "study",
+		"stopped":   "stop",
+		"boxes":     "box",
+		"used":      "use",
+		"uses":      "use",
+		"wolves":    "wolf",
+		"bigger":    "big",
+		"making":    "make",
+		"running":   "run",
+		"walks":     "walk",
+		"walk":      "walk",
+		"went":      "go",
+		"mice":      "mouse",
+		"better":    "better",
+		"teacher":   "teacher",
+		"teachers":  "teacher",
+		"news":      "news",
+		"obscurely": "obscurely",
+	}
+	for word, want := range tests {
+		if got := m.Lemma(word, isWord); got != want {
+			t.Errorf("Lemma(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestMorphologyBases(t *testing.T) {
+	m := DefaultMorphology()
+
+	if got := m.Bases("went"); !reflect.DeepEqual(got, []string{"go"}) {
+		t.Errorf("irregular forms should have a single base, got %v", got)
+	}
+	if got := m.Bases("building"); got != nil {
+		t.Errorf("lexicalized headwords should have no bases, got %v", got)
+	}
+	if got := m.Bases("ephemerals"); !slices.Contains(got, "ephemeral") {
+		t.Errorf("expected ephemeral among bases, got %v", got)
+	}
+}
+
+func TestLoadMorphologyRejectsMalformedLines(t *testing.T) {
+	if _, err := LoadMorphology(strings.NewReader("went go\nbroken\n")); err == nil {
+		t.Error("expected an error for a line without a lemma")
+	}
+}
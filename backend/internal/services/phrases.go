@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+)
+
+//go:embed data/phrases.tsv
+var bundledPhrases string
+
+const sourcePhrases = "phrases"
+
+// PhraseList is a DictionaryProvider for multi-word expressions, such as
+// phrasal verbs ("give up") and idioms ("break the ice"), that the upstream
+// dictionaries cover poorly. It is loaded into memory, so lookups of words
+// it doesn't have are free.
+type PhraseList struct {
+	language string
+	entries  map[string]*models.DictionaryEntry
+	phrases  []string
+}
+
+// LoadPhraseList reads "phrase<TAB>part of speech<TAB>definition<TAB>example"
+// lines, the example being optional. Blank lines and lines starting with #
+// are ignored, and the senses of a repeated phrase are collected in order.
+func LoadPhraseList(language string, r io.Reader) (*PhraseList, error) {
+	l := &PhraseList{language: language, entries: make(map[string]*models.DictionaryEntry)}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, fmt.Errorf("line %d: expected \"phrase<TAB>part of speech<TAB>definition<TAB>example\", got %q", n, line)
+		}
+		phrase, err := normalize.Word(language, fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		def := models.Definition{Definition: strings.TrimSpace(fields[2])}
+		if len(fields) == 4 {
+			def.Example = strings.TrimSpace(fields[3])
+		}
+		l.add(phrase, strings.TrimSpace(fields[1]), def)
+	}
+
+	return l, scanner.Err()
+}
+
+func (l *PhraseList) add(phrase, partOfSpeech string, def models.Definition) {
+	entry, ok := l.entries[phrase]
+	if !ok {
+		entry = &models.DictionaryEntry{
+			Word:      phrase,
+			Phonetics: make([]models.Phonetic, 0),
+			Meanings:  make([]models.Meaning, 0),
+			SourceURL: wiktionaryPageURL + wiktionaryTitle(phrase),
+		}
+		l.entries[phrase] = entry
+		l.phrases = append(l.phrases, phrase)
+	}
+
+	for i := range entry.Meanings {
+		if entry.Meanings[i].PartOfSpeech == partOfSpeech {
+			entry.Meanings[i].Definitions = append(entry.Meanings[i].Definitions, def)
+			return
+		}
+	}
+	entry.Meanings = append(entry.Meanings, models.Meaning{
+		PartOfSpeech: partOfSpeech,
+		Definitions:  []models.Definition{def},
+	})
+}
+
+var (
+	defaultPhrasesOnce sync.Once
+	defaultPhrases     *PhraseList
+)
+
+// DefaultPhraseList returns the English phrase list bundled with the binary.
+func DefaultPhraseList() *PhraseList {
+	defaultPhrasesOnce.Do(func() {
+		var err error
+		if defaultPhrases, err = LoadPhraseList(DefaultLanguage, strings.NewReader(bundledPhrases)); err != nil {
+			panic("bundled phrase list: " + err.Error())
+		}
+	})
+	return defaultPhrases
+}
+
+// Source implements DictionaryProvider.
+func (l *PhraseList) Source() string { return sourcePhrases }
+
+// Fetch implements DictionaryProvider.
+func (l *PhraseList) Fetch(ctx context.Context, word string) (*models.DictionaryEntry, error) {
+	// Phrases are listed by key, while providers are asked for a word as typed
+	key, err := normalize.Word(l.language, word)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrWordNotFound, word)
+	}
+	entry, ok := l.entries[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWordNotFound, word)
+	}
+
+	// Hand out a copy, since callers annotate the entry they get
+	copied := *entry
+	copied.Meanings = make([]models.Meaning, len(entry.Meanings))
+	for i, m := range entry.Meanings {
+		m.Definitions = append([]models.Definition(nil), m.Definitions...)
+		copied.Meanings[i] = m
+	}
+	return &copied, nil
+}
+
+// Phrases returns the listed phrases in file order.
+func (l *PhraseList) Phrases() []string {
+	return l.phrases
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadPhraseList(t *testing.T) {
+	input := "# comment\nGive  Up\tverb\tto stop trying\tDon't give up.\ngive up\tverb\tto stop a habit\nbreak the ice\tidiom\tto ease tension\n"
+	l, err := LoadPhraseList("en", strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := l.Phrases(); len(got) != 2 || got[0] != "give up" {
+		t.Fatalf("unexpected phrases %v", got)
+	}
+
+	entry, err := l.Fetch(context.Background(), "give up")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entry.Meanings) != 1 || len(entry.Meanings[0].Definitions) != 2 {
+		t.Fatalf("expected two verb senses, got %+v", entry.Meanings)
+	}
+	if entry.Meanings[0].Definitions[0].Example != "Don't give up." {
+		t.Errorf("unexpected example %q", entry.Meanings[0].Definitions[0].Example)
+	}
+	if entry.SourceURL != wiktionaryPageURL+"give_up" {
+		t.Errorf("unexpected source URL %q", entry.SourceURL)
+	}
+
+	// Entries are handed out as copies
+	entry.Meanings[0].Definitions[0].Definition = "changed"
+	if again, _ := l.Fetch(context.Background(), "give up"); again.Meanings[0].Definitions[0].Definition != "to stop trying" {
+		t.Error("modifying a fetched entry changed the list")
+	}
+
+	if _, err := l.Fetch(context.Background(), "give"); !errors.Is(err, ErrWordNotFound) {
+		t.Errorf("expected ErrWordNotFound, got %v", err)
+	}
+	if _, err := LoadPhraseList("en", strings.NewReader("give up\tverb\n")); err == nil {
+		t.Error("expected an error for a line without a definition")
+	}
+}
+
+func TestDefaultPhraseList(t *testing.T) {
+	l := DefaultPhraseList()
+	for _, phrase := range []string{"give up", "break the ice", "and/or", "look forward to"} {
+		if _, err := l.Fetch(context.Background(), phrase); err != nil {
+			t.Errorf("expected %q in the bundled list: %v", phrase, err)
+		}
+	}
+}
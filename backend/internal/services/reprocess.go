@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/repository"
+)
+
+// ErrNoPayload is returned when normalizing again a cache row that has no
+// upstream payload, or whose source no longer has a provider
+var ErrNoPayload = errors.New("no upstream payload to normalize")
+
+// CacheReprocess counts the cache rows rewritten by Reprocess
+type CacheReprocess struct {
+	Scanned int
+	Updated int
+	// Failed rows couldn't be normalized, e.g. when their payload is no
+	// longer understood; their entries are left as they were
+	Failed    int
+	LastError string
+}
+
+// Reprocess normalizes the stored upstream payloads of the cache rows
+// matching filter again, expired or not, rewriting their entries in the
+// current schema version without going upstream; for instance after a fix
+// to a provider's normalization. Rows without a payload are skipped.
+// progress, if not nil, is called after each batch.
+func (s *DictionaryService) Reprocess(ctx context.Context, filter repository.CacheFilter, progress func(CacheReprocess)) (CacheReprocess, error) {
+	var r CacheReprocess
+	var after repository.WordKey
+	for {
+		rows, err := s.repo.GetRawCacheRows(ctx, filter, after, cacheMigrationBatch)
+		if err != nil {
+			return r, err
+		}
+		if len(rows) == 0 {
+			return r, nil
+		}
+
+		for i := range rows {
+			if err := ctx.Err(); err != nil {
+				return r, err
+			}
+			cached := &rows[i]
+			after = repository.WordKey{Language: cached.Language, Word: cached.Word}
+			r.Scanned++
+
+			if _, err := s.renormalize(ctx, cached); err != nil {
+				r.Failed++
+				r.LastError = fmt.Sprintf("%s: %v", cached.Word, err)
+				continue
+			}
+			r.Updated++
+		}
+
+		if progress != nil {
+			progress(r)
+		}
+	}
+}
+
+// renormalize rebuilds the entry of a cache row from its upstream payload
+// with the provider of its source, and stores it in the current schema
+// version, keeping the row's expiry.
+func (s *DictionaryService) renormalize(ctx context.Context, cached *models.DictionaryCache) (*models.DictionaryEntry, error) {
+	if cached.Raw == nil {
+		return nil, ErrNoPayload
+	}
+	provider := s.rawProvider(cached.Language, cached.Source)
+	if provider == nil {
+		return nil, fmt.Errorf("%w: no provider for source %q", ErrNoPayload, cached.Source)
+	}
+
+	entry, err := provider.Normalize(cachedHeadword(cached), cached.Raw)
+	if err != nil {
+		return nil, err
+	}
+	ensureHomographs(entry)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal entry: %w", err)
+	}
+	if _, err := s.repo.UpgradeCachedDictionary(ctx, cached.Language, cached.Word, cached.SchemaVersion, data); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// renormalizeCached rebuilds the cached entry of word from its upstream
+// payload, which lookups don't read otherwise.
+func (s *DictionaryService) renormalizeCached(ctx context.Context, language, word string) (*models.DictionaryEntry, error) {
+	cached, err := s.repo.GetCacheRow(ctx, language, word)
+	if err != nil {
+		return nil, err
+	}
+	return s.renormalize(ctx, cached)
+}
+
+// rawProvider returns the provider of a language's source that normalizes
+// upstream payloads, or nil.
+func (s *DictionaryService) rawProvider(language, source string) RawDictionaryProvider {
+	for _, p := range s.providers[language] {
+		if rp, ok := p.(RawDictionaryProvider); ok && p.Source() == source {
+			return rp
+		}
+	}
+	return nil
+}
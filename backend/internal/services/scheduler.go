@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/repository"
+)
+
+const (
+	// schedulerPoll is how often a replica checks whether a job is due, so
+	// a job whose leader went away is picked up by another replica soon
+	schedulerPoll = time.Minute
+
+	// jobHistoryRetention is how long job runs are kept
+	jobHistoryRetention = 30 * 24 * time.Hour
+
+	// refreshWindow is how long before expiry a popular word is refetched
+	refreshWindow = 24 * time.Hour
+	// refreshLookupWindow is the period lookups count towards a word's
+	// popularity
+	refreshLookupWindow = 30 * 24 * time.Hour
+	// refreshMinUses is how many lookups and wordbook saves make a word
+	// popular enough to refresh
+	refreshMinUses = 2
+	// refreshBatch caps the words refreshed per run, sparing the upstream
+	// dictionaries
+	refreshBatch = 100
+)
+
+var (
+	// ErrUnknownJob is returned for a job name the scheduler doesn't have
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrJobRunning is returned when a job is already running on some replica
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// Job is a periodic task run by the Scheduler
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Run does one pass of the job and summarizes what it did
+	Run func(ctx context.Context) (string, error)
+}
+
+// Scheduler runs maintenance jobs in the background. Every replica of the
+// server runs one, and they elect a leader per run through a Postgres
+// advisory lock: a job runs on whichever replica first finds it due while
+// holding its lock, so each run happens once however many replicas there
+// are. Runs are recorded in the job history.
+type Scheduler struct {
+	repo *repository.Repository
+	jobs []Job
+	wg   sync.WaitGroup
+}
+
+func NewScheduler(repo *repository.Repository, jobs ...Job) *Scheduler {
+	return &Scheduler{repo: repo, jobs: jobs}
+}
+
+// MaintenanceJobs returns the standard jobs: purging expired cache entries,
+// refreshing popular words before they expire and reminding users of due
+// reviews.
+func MaintenanceJobs(repo *repository.Repository, dictSvc *DictionaryService) []Job {
+	return []Job{
+		{
+			Name:     "purge-expired-cache",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) (string, error) {
+				purged, err := repo.CleanExpiredCache(ctx)
+				if err != nil {
+					return "", err
+				}
+				pruned, err := repo.PruneJobRuns(ctx, time.Now().Add(-jobHistoryRetention))
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("purged %d expired entries and %d old job runs", purged, pruned), nil
+			},
+		},
+		{
+			Name:     "refresh-popular-words",
+			Interval: 6 * time.Hour,
+			Run: func(ctx context.Context) (string, error) {
+				return refreshPopularWords(ctx, repo, dictSvc)
+			},
+		},
+		{
+			Name:     "review-reminders",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) (string, error) {
+				n, err := repo.CreateReviewReminders(ctx)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("reminded %d users of due reviews", n), nil
+			},
+		},
+	}
+}
+
+// refreshPopularWords refetches the most used cached words that are about to
+// expire. Words failing to refresh keep their entry until it expires; the
+// run fails only if none could be refreshed.
+func refreshPopularWords(ctx context.Context, repo *repository.Repository, dictSvc *DictionaryService) (string, error) {
+	keys, err := repo.GetExpiringPopularWords(ctx, refreshWindow, refreshLookupWindow, refreshMinUses, refreshBatch)
+	if err != nil {
+		return "", err
+	}
+
+	refreshed, gone := 0, 0
+	var lastErr error
+	for _, key := range keys {
+		err := dictSvc.Refresh(ctx, key.Language, key.Word)
+		switch {
+		case err == nil:
+			refreshed++
+		case errors.Is(err, ErrWordNotFound):
+			gone++
+		default:
+			lastErr = fmt.Errorf("%s: %w", key.Word, err)
+		}
+	}
+
+	result := fmt.Sprintf("refreshed %d of %d expiring words, %d no longer found", refreshed, len(keys), gone)
+	if lastErr != nil && refreshed == 0 {
+		return result, lastErr
+	}
+	return result, nil
+}
+
+// Start runs each job in its own goroutine until ctx is cancelled. Jobs are
+// first considered right away, so a job that hasn't run within its interval
+// runs on startup.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+}
+
+// Wait blocks until the goroutines started by Start return.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(min(job.Interval, schedulerPoll))
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.run(ctx, job, false); err != nil && ctx.Err() == nil {
+			log.Printf("Warning: failed to schedule job %s: %v", job.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunNow runs the named job immediately, whenever it last ran, and returns
+// the run. A failure of the job itself is recorded in the run rather than
+// returned.
+func (s *Scheduler) RunNow(ctx context.Context, name string) (*models.JobRun, error) {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			run, err := s.run(ctx, job, true)
+			if err == nil && run == nil {
+				return nil, fmt.Errorf("%w: %s", ErrJobRunning, name)
+			}
+			return run, err
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+}
+
+// run runs job if this replica wins its lock and, unless forced, the job is
+// due. It returns the run, or nil if the job didn't run.
+func (s *Scheduler) run(ctx context.Context, job Job, force bool) (*models.JobRun, error) {
+	var run *models.JobRun
+	_, err := s.repo.RunExclusive(ctx, "job:"+job.Name, func(ctx context.Context) error {
+		// Checked under the lock, so a replica that just finished the job
+		// keeps the others from running it again
+		if !force {
+			last, err := s.repo.GetLastJobRun(ctx, job.Name)
+			if err != nil {
+				return err
+			}
+			if !jobDue(last, job.Interval, time.Now()) {
+				return nil
+			}
+		}
+
+		var err error
+		if run, err = s.repo.StartJobRun(ctx, job.Name); err != nil {
+			return err
+		}
+		result, runErr := job.Run(ctx)
+		if runErr != nil {
+			log.Printf("Warning: job %s failed: %v", job.Name, runErr)
+		}
+		return s.repo.FinishJobRun(context.WithoutCancel(ctx), run, result, runErr)
+	})
+	return run, err
+}
+
+// jobDue reports whether a job with the given interval is due at now, given
+// its last run.
+func jobDue(last *models.JobRun, interval time.Duration, now time.Time) bool {
+	return last == nil || now.Sub(last.StartedAt) >= interval
+}
+
+// Status returns the jobs with their last run and last failure.
+func (s *Scheduler) Status(ctx context.Context) ([]models.JobStatus, error) {
+	statuses := make([]models.JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		status := models.JobStatus{Name: job.Name, Interval: job.Interval.String()}
+		var err error
+		if status.LastRun, err = s.repo.GetLastJobRun(ctx, job.Name); err != nil {
+			return nil, err
+		}
+		if status.LastError, err = s.repo.GetLastFailedJobRun(ctx, job.Name); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// History returns up to limit runs of the named job, newest first.
+func (s *Scheduler) History(ctx context.Context, name string, limit int) ([]models.JobRun, error) {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return s.repo.GetJobRuns(ctx, name, limit)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestJobDue(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		last *models.JobRun
+		want bool
+	}{
+		{"never ran", nil, true},
+		{"ran within the interval", &models.JobRun{StartedAt: now.Add(-59 * time.Minute)}, false},
+		{"ran an interval ago", &models.JobRun{StartedAt: now.Add(-time.Hour)}, true},
+		{"interrupted long ago", &models.JobRun{StartedAt: now.Add(-3 * time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		if got := jobDue(tt.last, time.Hour, now); got != tt.want {
+			t.Errorf("%s: jobDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSchedulerUnknownJob(t *testing.T) {
+	s := NewScheduler(nil, MaintenanceJobs(nil, nil)...)
+	ctx := context.Background()
+
+	if _, err := s.RunNow(ctx, "nope"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("RunNow: expected ErrUnknownJob, got %v", err)
+	}
+	if _, err := s.History(ctx, "nope", 10); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("History: expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestMaintenanceJobs(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, job := range MaintenanceJobs(nil, nil) {
+		if job.Name == "" || len(job.Name) > 64 || seen[job.Name] {
+			t.Errorf("invalid or duplicate job name %q", job.Name)
+		}
+		seen[job.Name] = true
+		if job.Interval <= 0 || job.Run == nil {
+			t.Errorf("job %s is missing its interval or function", job.Name)
+		}
+	}
+	if !seen["purge-expired-cache"] {
+		t.Error("expected the expired cache to be purged")
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+const (
+	// SuggestionMatchSpelling and SuggestionMatchPhonetic tell how a
+	// suggestion was found
+	SuggestionMatchSpelling = "spelling"
+	SuggestionMatchPhonetic = "phonetic"
+
+	// maxPhoneticDistance drops sound-alikes too far from the query to be
+	// plausible misspellings of it
+	maxPhoneticDistance = 4
+)
+
+// Speller suggests corrections for misspelled words from a dictionary of
+// known words, indexed by edit distance in a BK-tree and by sound in a
+// Double Metaphone index. It is safe for concurrent use, so words can be
+// added as they are cached while lookups run.
+type Speller struct {
+	freq *FrequencyList
+
+	mu       sync.RWMutex
+	root     *bkNode
+	words    map[string]bool
+	phonetic map[string][]string
+}
+
+// NewSpeller creates a speller that knows words and ranks equally close
+// suggestions by their frequency in freq.
+func NewSpeller(freq *FrequencyList, words []string) *Speller {
+	s := &Speller{freq: freq, words: make(map[string]bool), phonetic: make(map[string][]string)}
+	s.Add(words...)
+	return s
+}
+
+// Add teaches the speller new words. Words it already knows are ignored.
+func (s *Speller) Add(words ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" || s.words[word] {
+			continue
+		}
+		s.words[word] = true
+
+		if s.root == nil {
+			s.root = &bkNode{word: word}
+		} else {
+			s.root.add(word)
+		}
+
+		primary, alternate := DoubleMetaphone(word)
+		s.phonetic[primary] = append(s.phonetic[primary], word)
+		if alternate != primary {
+			s.phonetic[alternate] = append(s.phonetic[alternate], word)
+		}
+	}
+}
+
+// Suggest returns up to limit known words close to word, nearest first.
+// Words within a small edit distance come first; if there are fewer than
+// limit of those, words that sound the same follow.
+func (s *Speller) Suggest(word string, limit int) []models.SpellingSuggestion {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" || limit <= 0 {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	maxDistance := 2
+	if len([]rune(word)) <= 4 {
+		maxDistance = 1
+	}
+
+	seen := map[string]bool{word: true}
+	var spelling []models.SpellingSuggestion
+	if s.root != nil {
+		s.root.search(word, maxDistance, func(candidate string, distance int) {
+			if !seen[candidate] {
+				seen[candidate] = true
+				spelling = append(spelling, models.SpellingSuggestion{Word: candidate, Distance: distance, Match: SuggestionMatchSpelling})
+			}
+		})
+	}
+	s.rank(spelling)
+	if len(spelling) >= limit {
+		return spelling[:limit]
+	}
+
+	var phonetic []models.SpellingSuggestion
+	primary, alternate := DoubleMetaphone(word)
+	for _, code := range []string{primary, alternate} {
+		for _, candidate := range s.phonetic[code] {
+			if seen[candidate] {
+				continue
+			}
+			seen[candidate] = true
+			if distance := editDistance(word, candidate); distance <= maxPhoneticDistance {
+				phonetic = append(phonetic, models.SpellingSuggestion{Word: candidate, Distance: distance, Match: SuggestionMatchPhonetic})
+			}
+		}
+	}
+	s.rank(phonetic)
+
+	suggestions := append(spelling, phonetic...)
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// rank orders suggestions by edit distance, then by how common the word is.
+func (s *Speller) rank(suggestions []models.SpellingSuggestion) {
+	rank := func(word string) int {
+		if r := s.freq.Rank(word); r > 0 {
+			return r
+		}
+		return math.MaxInt
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if ra, rb := rank(a.Word), rank(b.Word); ra != rb {
+			return ra < rb
+		}
+		return a.Word < b.Word
+	})
+}
+
+// bkNode is a node of a BK-tree: every child sits at the edit distance from
+// its parent that keys it, which lets searches skip whole subtrees.
+type bkNode struct {
+	word     string
+	children map[int]*bkNode
+}
+
+func (n *bkNode) add(word string) {
+	for {
+		d := editDistance(word, n.word)
+		if d == 0 {
+			return
+		}
+		child, ok := n.children[d]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[int]*bkNode)
+			}
+			n.children[d] = &bkNode{word: word}
+			return
+		}
+		n = child
+	}
+}
+
+func (n *bkNode) search(word string, maxDistance int, fn func(string, int)) {
+	stack := []*bkNode{n}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		d := editDistance(word, node.word)
+		if d <= maxDistance {
+			fn(node.word, d)
+		}
+		for key, child := range node.children {
+			if key >= d-maxDistance && key <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// the number of insertions, deletions, substitutions and transpositions of
+// adjacent letters needed to turn one into the other.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Three rolling rows: two back, previous and current
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
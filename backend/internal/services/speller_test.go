@@ -0,0 +1,54 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"word", "word", 0},
+		{"recieve", "receive", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSpellerSuggest(t *testing.T) {
+	freq, _ := LoadFrequencyList(strings.NewReader("receive\nrecite\nphone\nrecipe\n"))
+	s := NewSpeller(freq, []string{"receive", "recite", "recipe", "phone", "deceive", "psychology"})
+
+	got := s.Suggest("recieve", 3)
+	if len(got) == 0 || got[0].Word != "receive" || got[0].Distance != 1 || got[0].Match != SuggestionMatchSpelling {
+		t.Fatalf("expected receive first, got %+v", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Distance < got[i-1].Distance {
+			t.Errorf("suggestions should be ordered by distance: %+v", got)
+		}
+	}
+
+	phonetic := s.Suggest("sykology", 3)
+	if len(phonetic) != 1 || phonetic[0].Word != "psychology" || phonetic[0].Match != SuggestionMatchPhonetic {
+		t.Errorf("expected a phonetic match for psychology, got %+v", phonetic)
+	}
+
+	if got := s.Suggest("receive", 3); len(got) > 0 && got[0].Word == "receive" {
+		t.Error("a word should not be suggested as its own correction")
+	}
+
+	s.Add("ephemeral")
+	if got := s.Suggest("ephemral", 1); len(got) != 1 || got[0].Word != "ephemeral" {
+		t.Errorf("added words should be suggested, got %+v", got)
+	}
+}
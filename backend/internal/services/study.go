@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/repository"
+)
+
+const (
+	initialEase     = 2.5
+	minEase         = 1.3
+	passingGrade    = 3
+	forecastDays    = 30
+	defaultStatDays = 30
+	maxStatDays     = 365
+)
+
+// ErrInvalidTimeZone is returned when a time zone is not a known IANA name
+var ErrInvalidTimeZone = errors.New("invalid time zone")
+
+type StudyService struct {
+	repo *repository.Repository
+	now  func() time.Time
+}
+
+func NewStudyService(repo *repository.Repository) *StudyService {
+	return &StudyService{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// ReviewWord grades a review of a wordbook entry, reschedules it and records
+// the review in the log.
+func (s *StudyService) ReviewWord(ctx context.Context, userID, language, word string, grade int) (*models.WordbookEntry, error) {
+	entry, err := s.repo.GetWordbookEntry(ctx, userID, language, word)
+	if err != nil {
+		return nil, err
+	}
+
+	next := NextReviewState(entry.ReviewState, grade, s.now())
+	return s.repo.RecordReview(ctx, userID, language, word, grade, next)
+}
+
+// GetSettings returns the user's settings.
+func (s *StudyService) GetSettings(ctx context.Context, userID string) (*models.UserSettings, error) {
+	return s.repo.GetUserSettings(ctx, userID)
+}
+
+// SetTimeZone validates an IANA time zone name and stores it for the user.
+func (s *StudyService) SetTimeZone(ctx context.Context, userID, timeZone string) (*models.UserSettings, error) {
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimeZone, timeZone)
+	}
+	return s.repo.SetUserTimeZone(ctx, userID, timeZone)
+}
+
+// GetStats aggregates the user's learning statistics over the last days,
+// bucketed by calendar day in the user's configured time zone.
+func (s *StudyService) GetStats(ctx context.Context, userID string, days int) (*models.LearningStats, error) {
+	if days <= 0 {
+		days = defaultStatDays
+	}
+	if days > maxStatDays {
+		days = maxStatDays
+	}
+
+	settings, err := s.repo.GetUserSettings(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	loc, err := time.LoadLocation(settings.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone: %s", settings.TimeZone)
+	}
+
+	today := startOfDay(s.now().In(loc))
+	since := today.AddDate(0, 0, -(days - 1))
+	stats := &models.LearningStats{TimeZone: settings.TimeZone}
+
+	if stats.TotalWords, err = s.repo.CountWordbookEntries(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	added, err := s.repo.CountWordsAddedPerDay(ctx, userID, settings.TimeZone, since)
+	if err != nil {
+		return nil, err
+	}
+	stats.WordsAdded = fillDays(added, since, days)
+
+	reviews, err := s.repo.CountReviewsPerDay(ctx, userID, settings.TimeZone, since)
+	if err != nil {
+		return nil, err
+	}
+	stats.Reviews = fillDays(reviews, since, days)
+
+	total, recalled, err := s.repo.GetReviewTotals(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	stats.TotalReviews = total
+	if total > 0 {
+		stats.RetentionRate = float64(recalled) / float64(total)
+	}
+
+	activity, err := s.repo.GetActivityDays(ctx, userID, settings.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	stats.CurrentStreak, stats.LongestStreak = ComputeStreaks(activity, today)
+
+	forecast, err := s.repo.CountDueForecast(ctx, userID, settings.TimeZone, today, today.AddDate(0, 0, forecastDays))
+	if err != nil {
+		return nil, err
+	}
+	stats.DueForecast = fillDays(forecast, today, forecastDays)
+
+	if stats.PartsOfSpeech, err = s.repo.CountPartsOfSpeech(ctx, userID); err != nil {
+		return nil, err
+	}
+	if stats.PartsOfSpeech == nil {
+		stats.PartsOfSpeech = []models.PartOfSpeechCount{}
+	}
+
+	return stats, nil
+}
+
+// NextReviewState applies the SM-2 algorithm to a review graded 0-5.
+// Grades below 3 count as a lapse and restart the repetition sequence.
+func NextReviewState(state models.ReviewState, grade int, now time.Time) models.ReviewState {
+	ease := state.Ease
+	if ease == 0 {
+		ease = initialEase
+	}
+
+	next := models.ReviewState{Ease: ease}
+	if grade < passingGrade {
+		next.Repetitions = 0
+		next.IntervalDays = 1
+	} else {
+		next.Repetitions = state.Repetitions + 1
+		switch next.Repetitions {
+		case 1:
+			next.IntervalDays = 1
+		case 2:
+			next.IntervalDays = 6
+		default:
+			next.IntervalDays = int(math.Round(float64(state.IntervalDays) * ease))
+		}
+	}
+
+	q := float64(5 - grade)
+	next.Ease = math.Max(minEase, ease+0.1-q*(0.08+q*0.02))
+	next.DueAt = now.AddDate(0, 0, next.IntervalDays)
+	return next
+}
+
+// ComputeStreaks returns the current and longest runs of consecutive active
+// days. days must be sorted ascending. The current streak is still alive if
+// the last activity was yesterday, so it doesn't reset before today's review.
+func ComputeStreaks(days []time.Time, today time.Time) (current, longest int) {
+	run := 0
+	var prev time.Time
+	for i, day := range days {
+		if i > 0 && sameDay(prev.AddDate(0, 0, 1), day) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+		prev = day
+	}
+
+	if len(days) > 0 && (sameDay(prev, today) || sameDay(prev.AddDate(0, 0, 1), today)) {
+		current = run
+	}
+	return current, longest
+}
+
+// fillDays expands sparse daily counts into one bucket per day starting at
+// from, so clients can chart the result without gap handling.
+func fillDays(counts []models.DailyCount, from time.Time, days int) []models.DailyCount {
+	byDate := make(map[string]int, len(counts))
+	for _, c := range counts {
+		byDate[c.Date] = c.Count
+	}
+
+	filled := make([]models.DailyCount, 0, days)
+	for i := 0; i < days; i++ {
+		date := from.AddDate(0, 0, i).Format(time.DateOnly)
+		filled = append(filled, models.DailyCount{Date: date, Count: byDate[date]})
+	}
+	return filled
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestNextReviewState(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	state := NextReviewState(models.ReviewState{}, 4, now)
+	if state.Repetitions != 1 || state.IntervalDays != 1 {
+		t.Errorf("first review: got reps=%d interval=%d, want 1/1", state.Repetitions, state.IntervalDays)
+	}
+	if state.Ease != initialEase {
+		t.Errorf("grade 4 should keep ease at %.2f, got %.2f", initialEase, state.Ease)
+	}
+
+	state = NextReviewState(state, 5, now)
+	if state.IntervalDays != 6 {
+		t.Errorf("second review: got interval %d, want 6", state.IntervalDays)
+	}
+
+	state = NextReviewState(state, 5, now)
+	if state.IntervalDays != 16 {
+		t.Errorf("third review: got interval %d, want 16", state.IntervalDays)
+	}
+	if !state.DueAt.Equal(now.AddDate(0, 0, 16)) {
+		t.Errorf("due date mismatch: got %v", state.DueAt)
+	}
+
+	lapsed := NextReviewState(state, 1, now)
+	if lapsed.Repetitions != 0 || lapsed.IntervalDays != 1 {
+		t.Errorf("lapse: got reps=%d interval=%d, want 0/1", lapsed.Repetitions, lapsed.IntervalDays)
+	}
+	if lapsed.Ease >= state.Ease {
+		t.Errorf("lapse should lower ease: got %.2f from %.2f", lapsed.Ease, state.Ease)
+	}
+
+	floor := models.ReviewState{Ease: minEase}
+	if got := NextReviewState(floor, 0, now).Ease; got != minEase {
+		t.Errorf("ease should not drop below %.2f, got %.2f", minEase, got)
+	}
+}
+
+func TestComputeStreaks(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	today := time.Date(2024, 3, 10, 0, 0, 0, 0, time.FixedZone("CST", 8*3600))
+
+	tests := []struct {
+		name        string
+		days        []time.Time
+		wantCurrent int
+		wantLongest int
+	}{
+		{"no activity", nil, 0, 0},
+		{"active today", []time.Time{day(8), day(9), day(10)}, 3, 3},
+		{"active until yesterday", []time.Time{day(8), day(9)}, 2, 2},
+		{"broken streak", []time.Time{day(1), day(2), day(3), day(4), day(7)}, 0, 4},
+		{"longest in the past", []time.Time{day(1), day(2), day(3), day(9), day(10)}, 2, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, longest := ComputeStreaks(tt.days, today)
+			if current != tt.wantCurrent || longest != tt.wantLongest {
+				t.Errorf("got current=%d longest=%d, want %d/%d", current, longest, tt.wantCurrent, tt.wantLongest)
+			}
+		})
+	}
+}
+
+func TestFillDays(t *testing.T) {
+	from := time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC)
+	counts := []models.DailyCount{{Date: "2024-02-29", Count: 3}}
+
+	filled := fillDays(counts, from, 3)
+	want := []models.DailyCount{
+		{Date: "2024-02-28", Count: 0},
+		{Date: "2024-02-29", Count: 3},
+		{Date: "2024-03-01", Count: 0},
+	}
+	if len(filled) != len(want) {
+		t.Fatalf("expected %d buckets, got %d", len(want), len(filled))
+	}
+	for i := range want {
+		if filled[i] != want[i] {
+			t.Errorf("bucket %d: got %+v, want %+v", i, filled[i], want[i])
+		}
+	}
+}
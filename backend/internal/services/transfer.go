@@ -0,0 +1,445 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+	"github.com/warriorguo/vocabulary/internal/repository"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+
+	exportVersion = 1
+	// maxLanguageLength matches the wordbook's language column
+	maxLanguageLength = 16
+	maxLocation       = 64
+	tagSeparator      = ";"
+)
+
+var (
+	// ErrUnsupportedFormat is returned for formats a transfer doesn't support
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	// ErrInvalidPolicy is returned for unknown duplicate-handling policies
+	ErrInvalidPolicy = errors.New("invalid duplicate policy")
+	// ErrMalformedImport is returned when an import file can't be parsed at all
+	ErrMalformedImport = errors.New("malformed import file")
+)
+
+// csvColumns is the column layout written by CSV exports. Imports match
+// columns by header name, so only word and short_definition are required.
+var csvColumns = []string{
+	"word", "language", "short_definition", "notes", "tags", "contexts",
+	"created_at", "ease", "interval_days", "repetitions", "due_at",
+}
+
+type TransferService struct {
+	repo    *repository.Repository
+	dictSvc *DictionaryService
+	audio   *AudioStore
+}
+
+// NewTransferService creates the import and export service. audio may be
+// nil, leaving Anki exports without recordings.
+func NewTransferService(repo *repository.Repository, dictSvc *DictionaryService, audio *AudioStore) *TransferService {
+	return &TransferService{repo: repo, dictSvc: dictSvc, audio: audio}
+}
+
+// importRow is a parsed row of an import file, with its 1-based position and
+// any validation error that keeps it from being applied. skip marks rows that
+// are deliberately left out, with detail explaining why.
+type importRow struct {
+	row    int
+	entry  models.WordbookEntry
+	err    error
+	skip   bool
+	detail string
+}
+
+// Export writes all of a user's wordbook entries to w in the given format,
+// streaming them from the database one at a time.
+func (s *TransferService) Export(ctx context.Context, userID, format string, w io.Writer) error {
+	switch format {
+	case FormatCSV:
+		return s.exportCSV(ctx, userID, w)
+	case FormatJSON:
+		return s.exportJSON(ctx, userID, w)
+	case FormatAnki:
+		return s.ExportAnki(ctx, userID, w)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+func (s *TransferService) exportCSV(ctx context.Context, userID string, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+
+	err := s.repo.StreamWordbookEntries(ctx, userID, func(e *models.WordbookEntry) error {
+		contexts, err := json.Marshal(exportContexts(e.Contexts))
+		if err != nil {
+			return err
+		}
+		return cw.Write([]string{
+			e.Word,
+			e.Language,
+			e.ShortDefinition,
+			e.Notes,
+			strings.Join(e.Tags, tagSeparator),
+			string(contexts),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(e.Ease, 'f', -1, 64),
+			strconv.Itoa(e.IntervalDays),
+			strconv.Itoa(e.Repetitions),
+			e.DueAt.UTC().Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (s *TransferService) exportJSON(ctx context.Context, userID string, w io.Writer) error {
+	header := fmt.Sprintf(`{"version":%d,"exported_at":%q,"entries":[`, exportVersion, time.Now().UTC().Format(time.RFC3339))
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+
+	first := true
+	err := s.repo.StreamWordbookEntries(ctx, userID, func(e *models.WordbookEntry) error {
+		e.ID, e.UserID = 0, ""
+		e.Contexts = exportContexts(e.Contexts)
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if !first {
+			data = append([]byte{','}, data...)
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}")
+	return err
+}
+
+// exportContexts strips database IDs so exported contexts are portable.
+func exportContexts(contexts []models.WordContext) []models.WordContext {
+	out := make([]models.WordContext, len(contexts))
+	for i, wc := range contexts {
+		wc.ID = 0
+		out[i] = wc
+	}
+	return out
+}
+
+// Import parses r in the given format and applies the valid rows in a single
+// transaction. Rows that fail validation are reported and left out; they
+// never abort the rest of the import. With lemmatize, inflected words are
+// stored under their lemma.
+func (s *TransferService) Import(ctx context.Context, userID, format string, r io.Reader, policy string, dryRun, lemmatize bool) (*models.ImportReport, error) {
+	policy, err := resolvePolicy(policy, models.ImportSkip)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := parseImport(format, r)
+	if err != nil {
+		return nil, err
+	}
+	s.checkLanguages(rows)
+	s.annotateRows(rows, lemmatize)
+
+	return s.applyImport(ctx, userID, rows, policy, dryRun)
+}
+
+// resolvePolicy validates a duplicate-handling policy, substituting def when
+// none was given.
+func resolvePolicy(policy, def string) (string, error) {
+	if policy == "" {
+		return def, nil
+	}
+	switch policy {
+	case models.ImportSkip, models.ImportOverwrite, models.ImportMerge:
+		return policy, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidPolicy, policy)
+}
+
+// checkLanguages rejects rows in languages without a dictionary, since
+// their words could never be looked up or reviewed.
+func (s *TransferService) checkLanguages(rows []importRow) {
+	for i := range rows {
+		if rows[i].err == nil {
+			rows[i].entry.Language, rows[i].err = s.dictSvc.Language(rows[i].entry.Language)
+		}
+	}
+}
+
+// annotateRows sets the word levels of each row's word. With lemmatize,
+// inflected words are stored under their lemma first, so an import can't add
+// "running" next to an existing "run"; without it words are kept as given,
+// since a form such as "saw" can be a word of its own.
+func (s *TransferService) annotateRows(rows []importRow, lemmatize bool) {
+	for i := range rows {
+		if rows[i].err == nil {
+			e := &rows[i].entry
+			if lemmatize {
+				e.Word = s.dictSvc.Lemma(e.Language, e.Word)
+			}
+			e.WordLevels = s.dictSvc.Levels(e.Language, e.Word)
+		}
+	}
+}
+
+// applyImport writes the rows that have no error and aren't skipped, then
+// reports the outcome of every row in its original order.
+func (s *TransferService) applyImport(ctx context.Context, userID string, rows []importRow, policy string, dryRun bool) (*models.ImportReport, error) {
+	var valid []models.WordbookEntry
+	for _, row := range rows {
+		if row.err == nil && !row.skip {
+			valid = append(valid, row.entry)
+		}
+	}
+
+	actions, err := s.repo.ImportWordbookEntries(ctx, userID, valid, policy, dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("import failed: %w", err)
+	}
+
+	report := &models.ImportReport{DryRun: dryRun, Policy: policy, Rows: []models.ImportRowResult{}}
+	next := 0
+	for _, row := range rows {
+		result := models.ImportRowResult{Row: row.row, Word: row.entry.Word, Detail: row.detail}
+		switch {
+		case row.err != nil:
+			result.Action = models.ImportActionFailed
+			result.Error = row.err.Error()
+		case row.skip:
+			result.Action = models.ImportActionSkipped
+		default:
+			result.Action = actions[next]
+			next++
+		}
+		report.Add(result)
+	}
+
+	return report, nil
+}
+
+// parseImport reads an import file into rows. A file that can't be parsed at
+// all yields ErrMalformedImport; problems in individual rows are recorded on
+// the rows themselves.
+func parseImport(format string, r io.Reader) ([]importRow, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSVImport(r)
+	case FormatJSON:
+		return parseJSONImport(r)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+func parseCSVImport(r io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header: %v", ErrMalformedImport, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["word"]; !ok {
+		return nil, fmt.Errorf("%w: header has no word column", ErrMalformedImport)
+	}
+
+	var rows []importRow
+	for n := 1; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, importRow{row: n, err: err})
+				continue
+			}
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := importRow{row: n}
+		row.entry, row.err = parseCSVRecord(field)
+		if row.err == nil {
+			row.err = validateImportEntry(&row.entry)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseCSVRecord(field func(string) string) (models.WordbookEntry, error) {
+	e := models.WordbookEntry{
+		Word:            field("word"),
+		Language:        field("language"),
+		ShortDefinition: field("short_definition"),
+		Notes:           field("notes"),
+	}
+
+	if tags := field("tags"); tags != "" {
+		for _, tag := range strings.Split(tags, tagSeparator) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				e.Tags = append(e.Tags, tag)
+			}
+		}
+	}
+
+	if v := field("contexts"); v != "" {
+		if err := json.Unmarshal([]byte(v), &e.Contexts); err != nil {
+			return e, fmt.Errorf("invalid contexts: %v", err)
+		}
+	}
+
+	var err error
+	if e.CreatedAt, err = parseOptionalTime(field("created_at")); err != nil {
+		return e, fmt.Errorf("invalid created_at: %v", err)
+	}
+	if e.DueAt, err = parseOptionalTime(field("due_at")); err != nil {
+		return e, fmt.Errorf("invalid due_at: %v", err)
+	}
+	if v := field("ease"); v != "" {
+		if e.Ease, err = strconv.ParseFloat(v, 64); err != nil {
+			return e, fmt.Errorf("invalid ease: %s", v)
+		}
+	}
+	if v := field("interval_days"); v != "" {
+		if e.IntervalDays, err = strconv.Atoi(v); err != nil {
+			return e, fmt.Errorf("invalid interval_days: %s", v)
+		}
+	}
+	if v := field("repetitions"); v != "" {
+		if e.Repetitions, err = strconv.Atoi(v); err != nil {
+			return e, fmt.Errorf("invalid repetitions: %s", v)
+		}
+	}
+
+	return e, nil
+}
+
+func parseJSONImport(r io.Reader) ([]importRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accept both the export document and a bare array of entries
+	var raw []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &raw)
+	} else {
+		var doc struct {
+			Entries []json.RawMessage `json:"entries"`
+		}
+		err = json.Unmarshal(trimmed, &doc)
+		raw = doc.Entries
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedImport, err)
+	}
+
+	rows := make([]importRow, 0, len(raw))
+	for i, item := range raw {
+		row := importRow{row: i + 1}
+		if err := json.Unmarshal(item, &row.entry); err != nil {
+			row.err = fmt.Errorf("invalid entry: %v", err)
+		} else {
+			row.entry.ID, row.entry.UserID = 0, ""
+			row.err = validateImportEntry(&row.entry)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// validateImportEntry normalizes and checks the fields the database
+// constrains.
+func validateImportEntry(e *models.WordbookEntry) error {
+	e.ShortDefinition = strings.TrimSpace(e.ShortDefinition)
+	if e.Language = normalize.Language(e.Language); e.Language == "" {
+		e.Language = DefaultLanguage
+	}
+	if strings.TrimSpace(e.Word) == "" {
+		return errors.New("word is required")
+	}
+	word, err := normalize.Word(e.Language, e.Word)
+	if err != nil {
+		return err
+	}
+	e.Word = word
+
+	switch {
+	case len(e.Language) > maxLanguageLength:
+		return fmt.Errorf("language exceeds %d bytes", maxLanguageLength)
+	case e.ShortDefinition == "":
+		return errors.New("short_definition is required")
+	case e.Ease < 0 || e.IntervalDays < 0 || e.Repetitions < 0:
+		return errors.New("review state must not be negative")
+	case e.Snapshot != nil && e.SelectedHomograph != nil && !e.Snapshot.HasHomograph(*e.SelectedHomograph):
+		return errors.New("selected_homograph does not match any homograph")
+	case e.Snapshot != nil && e.SelectedSense != nil && !e.SelectedSnapshot().HasSense(*e.SelectedSense):
+		return errors.New("selected_sense does not match any definition")
+	}
+
+	for i := range e.Contexts {
+		e.Contexts[i].ID = 0
+		if strings.TrimSpace(e.Contexts[i].Sentence) == "" {
+			return fmt.Errorf("context %d has no sentence", i+1)
+		}
+		if len(e.Contexts[i].Location) > maxLocation {
+			return fmt.Errorf("context %d location exceeds %d bytes", i+1, maxLocation)
+		}
+	}
+	return nil
+}
+
+func parseOptionalTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
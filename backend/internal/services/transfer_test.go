@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestParseCSVImport(t *testing.T) {
+	input := strings.Join([]string{
+		"Word,Short_Definition,tags,contexts,ease,interval_days,due_at",
+		`hello,a greeting,basics; greetings,"[{""sentence"":""Hello there."",""source_title"":""Film""}]",2.6,6,2024-01-07T00:00:00Z`,
+		`,missing word,,,,,`,
+		`world,,,,,,`,
+		`bad,ease,,,lots,,`,
+		`plain,just a definition`,
+	}, "\n")
+
+	rows, err := parseImport(FormatCSV, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseImport failed: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("expected 5 rows, got %d", len(rows))
+	}
+
+	first := rows[0]
+	if first.err != nil {
+		t.Fatalf("row 1 unexpected error: %v", first.err)
+	}
+	if first.entry.Word != "hello" || first.entry.IntervalDays != 6 || first.entry.Ease != 2.6 {
+		t.Errorf("row 1 parsed incorrectly: %+v", first.entry)
+	}
+	if len(first.entry.Tags) != 2 || first.entry.Tags[1] != "greetings" {
+		t.Errorf("row 1 tags: got %v", first.entry.Tags)
+	}
+	if len(first.entry.Contexts) != 1 || first.entry.Contexts[0].SourceTitle != "Film" {
+		t.Errorf("row 1 contexts: got %+v", first.entry.Contexts)
+	}
+
+	for i, want := range []string{"word is required", "short_definition is required", "invalid ease"} {
+		row := rows[i+1]
+		if row.err == nil || !strings.Contains(row.err.Error(), want) {
+			t.Errorf("row %d: expected error containing %q, got %v", row.row, want, row.err)
+		}
+	}
+
+	if rows[4].err != nil || rows[4].entry.Word != "plain" {
+		t.Errorf("short record should parse: %+v, %v", rows[4].entry, rows[4].err)
+	}
+}
+
+func TestParseCSVImportMissingWordColumn(t *testing.T) {
+	_, err := parseImport(FormatCSV, strings.NewReader("term,definition\nhello,a greeting\n"))
+	if !errors.Is(err, ErrMalformedImport) {
+		t.Errorf("expected ErrMalformedImport, got %v", err)
+	}
+}
+
+func TestParseJSONImport(t *testing.T) {
+	t.Run("export document", func(t *testing.T) {
+		input := `{"version":1,"entries":[
+			{"word":" hello ","short_definition":"a greeting","notes":"say it","tags":["basics"]},
+			{"word":"bad","short_definition":"x","ease":"high"},
+			{"word":"sense","short_definition":"x","selected_sense":{"meaning_index":3,"definition_index":0},"snapshot":{"word":"sense","phonetics":[],"meanings":[]}}
+		]}`
+
+		rows, err := parseImport(FormatJSON, strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("parseImport failed: %v", err)
+		}
+		if len(rows) != 3 {
+			t.Fatalf("expected 3 rows, got %d", len(rows))
+		}
+		if rows[0].err != nil || rows[0].entry.Word != "hello" || rows[0].entry.Notes != "say it" {
+			t.Errorf("row 1: got %+v, %v", rows[0].entry, rows[0].err)
+		}
+		if rows[1].err == nil {
+			t.Error("row 2: expected type error")
+		}
+		if rows[2].err == nil {
+			t.Error("row 3: expected selected_sense error")
+		}
+	})
+
+	t.Run("bare array", func(t *testing.T) {
+		rows, err := parseImport(FormatJSON, strings.NewReader(`[{"word":"hello","short_definition":"a greeting"}]`))
+		if err != nil || len(rows) != 1 || rows[0].err != nil {
+			t.Errorf("unexpected result: %+v, %v", rows, err)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := parseImport(FormatJSON, strings.NewReader(`{"entries":`))
+		if !errors.Is(err, ErrMalformedImport) {
+			t.Errorf("expected ErrMalformedImport, got %v", err)
+		}
+	})
+}
+
+func TestParseImportUnsupportedFormat(t *testing.T) {
+	_, err := parseImport("xml", strings.NewReader(""))
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestValidateImportEntryContexts(t *testing.T) {
+	e := models.WordbookEntry{
+		Word:            "hello",
+		ShortDefinition: "a greeting",
+		Contexts:        []models.WordContext{{ID: 7, Sentence: "Hello."}, {Sentence: "  "}},
+	}
+	if err := validateImportEntry(&e); err == nil || !strings.Contains(err.Error(), "context 2") {
+		t.Errorf("expected context 2 error, got %v", err)
+	}
+	if e.Contexts[0].ID != 0 {
+		t.Error("expected imported context IDs to be cleared")
+	}
+}
+
+func TestCheckLanguages(t *testing.T) {
+	s := &TransferService{dictSvc: NewDictionaryService(nil)}
+	rows, err := parseImport(FormatCSV, strings.NewReader("word,short_definition,language\nhaus,house,de-AT\nblorp,nothing,zz\nhello,a greeting,\n"))
+	if err != nil {
+		t.Fatalf("parseImport failed: %v", err)
+	}
+
+	s.checkLanguages(rows)
+	if rows[0].err != nil || rows[0].entry.Language != "de" {
+		t.Errorf("expected de-AT accepted as de, got %q, %v", rows[0].entry.Language, rows[0].err)
+	}
+	if !errors.Is(rows[1].err, ErrUnsupportedLanguage) {
+		t.Errorf("expected ErrUnsupportedLanguage for zz, got %v", rows[1].err)
+	}
+	if rows[2].err != nil || rows[2].entry.Language != DefaultLanguage {
+		t.Errorf("expected a missing language to mean %s, got %q, %v", DefaultLanguage, rows[2].entry.Language, rows[2].err)
+	}
+}
+
+func TestAnnotateRows(t *testing.T) {
+	s := &TransferService{dictSvc: NewDictionaryService(nil)}
+	rows := func() []importRow {
+		return []importRow{
+			{entry: models.WordbookEntry{Language: "en", Word: "running"}},
+			{entry: models.WordbookEntry{Language: "en", Word: "saw"}},
+		}
+	}
+
+	kept := rows()
+	s.annotateRows(kept, false)
+	if kept[0].entry.Word != "running" || kept[1].entry.Word != "saw" {
+		t.Errorf("expected words kept as given, got %q and %q", kept[0].entry.Word, kept[1].entry.Word)
+	}
+
+	// A form that is a headword of its own isn't reduced even when asked to
+	lemmatized := rows()
+	s.annotateRows(lemmatized, true)
+	if lemmatized[0].entry.Word != "run" || lemmatized[1].entry.Word != "saw" {
+		t.Errorf("expected run and saw, got %q and %q", lemmatized[0].entry.Word, lemmatized[1].entry.Word)
+	}
+	if lemmatized[0].entry.FrequencyRank == 0 {
+		t.Error("expected the lemma's word levels")
+	}
+}
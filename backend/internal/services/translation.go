@@ -0,0 +1,331 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/repository"
+	"golang.org/x/text/language"
+)
+
+const (
+	// TranslationAuto negotiates the translation language from the request's
+	// Accept-Language header, and TranslationOff never translates
+	TranslationAuto = ""
+	TranslationOff  = "off"
+
+	// maxGlosses caps the translations shown for one headword
+	maxGlosses = 5
+)
+
+// ErrUnsupportedLanguage is returned for a language no dictionary or
+// translation provider covers
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// TranslationProvider glosses English headwords in another language
+type TranslationProvider interface {
+	// Language returns the target language as a lowercase ISO 639-1 code
+	Language() string
+	// Source names the dictionary the glosses come from
+	Source() string
+	// Translate returns the glosses of a lowercased headword, best first,
+	// or nil if it has none
+	Translate(word string) []string
+}
+
+// OfflineTranslator is a TranslationProvider backed by a dictionary file
+// loaded into memory.
+type OfflineTranslator struct {
+	language string
+	source   string
+	glosses  map[string][]string
+}
+
+func (t *OfflineTranslator) Language() string { return t.language }
+func (t *OfflineTranslator) Source() string   { return t.source }
+
+func (t *OfflineTranslator) Translate(word string) []string {
+	return t.glosses[word]
+}
+
+func (t *OfflineTranslator) add(word, gloss string) {
+	if gloss == "" || len(t.glosses[word]) == maxGlosses || slices.Contains(t.glosses[word], gloss) {
+		return
+	}
+	t.glosses[word] = append(t.glosses[word], gloss)
+}
+
+// LoadFreeDict reads an English-to-language dictionary in FreeDict's
+// tab-separated export: a headword, a tab, and translations separated by
+// semicolons. Blank lines and lines starting with # are ignored.
+func LoadFreeDict(language, source string, r io.Reader) (*OfflineTranslator, error) {
+	t := &OfflineTranslator{language: language, source: source, glosses: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		headword, translations, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"headword<TAB>translations\", got %q", n, line)
+		}
+		word := strings.ToLower(strings.TrimSpace(headword))
+		for _, gloss := range strings.Split(translations, ";") {
+			t.add(word, strings.TrimSpace(gloss))
+		}
+	}
+
+	return t, scanner.Err()
+}
+
+// cedictPattern matches a CC-CEDICT line: traditional and simplified
+// headwords, the pinyin reading in brackets, and slash-delimited glosses
+var cedictPattern = regexp.MustCompile(`^(\S+) (\S+) \[([^\]]*)\] /(.*)/$`)
+
+// cedictQualifier matches a parenthesized remark within a gloss
+var cedictQualifier = regexp.MustCompile(`\s*\([^)]*\)`)
+
+// LoadCEDICT reads a CC-CEDICT file and inverts it into an English-to-Chinese
+// dictionary: every gloss that names a plain word ("to eat", "a dog",
+// "cake") translates to the simplified headword. Lines that don't parse, as
+// well as classifier and cross-reference glosses, are skipped, since the
+// upstream file carries occasional odd entries.
+func LoadCEDICT(r io.Reader) (*OfflineTranslator, error) {
+	t := &OfflineTranslator{language: "zh", source: "CC-CEDICT", glosses: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := cedictPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		simplified := m[2]
+		for _, gloss := range strings.Split(m[4], "/") {
+			if word := cedictHeadword(gloss); word != "" {
+				t.add(word, simplified)
+			}
+		}
+	}
+
+	return t, scanner.Err()
+}
+
+// cedictHeadword reduces a CC-CEDICT gloss to the English word it
+// translates, or "" if the gloss is a description rather than a word.
+func cedictHeadword(gloss string) string {
+	if strings.HasPrefix(gloss, "CL:") || strings.Contains(gloss, "|") {
+		return ""
+	}
+	gloss = strings.ToLower(strings.TrimSpace(cedictQualifier.ReplaceAllString(gloss, "")))
+	for _, prefix := range []string{"to ", "a ", "an ", "the "} {
+		gloss = strings.TrimPrefix(gloss, prefix)
+	}
+	if gloss == "" || strings.ContainsAny(gloss, " ,;.!?0123456789") {
+		return ""
+	}
+	return gloss
+}
+
+// cedictFile is the name LoadTranslationDir reads CC-CEDICT from
+const cedictFile = "cedict_ts.u8"
+
+// LoadTranslationDir loads the offline dictionaries found in dir: CC-CEDICT
+// as cedict_ts.u8 for Chinese, and FreeDict's English dictionaries as
+// freedict-eng-<language>.tsv, named by the target's ISO 639-3 code (deu,
+// spa). Neither is distributed with the server, since FreeDict is licensed
+// under the GNU GPL and CC-CEDICT under CC BY-SA 4.0; operators download
+// them and must follow those licenses. An empty dir loads nothing.
+func LoadTranslationDir(dir string) ([]TranslationProvider, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	var providers []TranslationProvider
+	load := func(path string, parse func(io.Reader) (*OfflineTranslator, error)) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		t, err := parse(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		providers = append(providers, t)
+		return nil
+	}
+
+	if err := load(filepath.Join(dir, cedictFile), LoadCEDICT); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "freedict-eng-*.tsv"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		code := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "freedict-eng-"), ".tsv")
+		base, err := language.ParseBase(code)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unknown language %q", path, code)
+		}
+		err = load(path, func(r io.Reader) (*OfflineTranslator, error) {
+			return LoadFreeDict(base.String(), "FreeDict", r)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return providers, nil
+}
+
+// TranslationService picks a translation provider for each user and
+// annotates dictionary entries with its glosses
+type TranslationService struct {
+	repo      *repository.Repository
+	providers map[string]TranslationProvider
+}
+
+// NewTranslationService creates a service over providers. A later provider
+// for the same language replaces an earlier one.
+func NewTranslationService(repo *repository.Repository, providers ...TranslationProvider) *TranslationService {
+	s := &TranslationService{repo: repo, providers: make(map[string]TranslationProvider, len(providers))}
+	for _, p := range providers {
+		s.providers[p.Language()] = p
+	}
+	return s
+}
+
+// Languages returns the languages that can be translated to, sorted.
+func (s *TranslationService) Languages() []string {
+	languages := make([]string, 0, len(s.providers))
+	for language := range s.providers {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// SetLanguage stores the user's translation preference: a supported
+// language, TranslationOff, or TranslationAuto to follow Accept-Language.
+func (s *TranslationService) SetLanguage(ctx context.Context, userID, language string) (*models.UserSettings, error) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if _, ok := s.providers[language]; !ok && language != TranslationAuto && language != TranslationOff {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+	return s.repo.SetUserTranslationLanguage(ctx, userID, language)
+}
+
+// Language resolves the language to translate into for a request: the
+// user's stored preference if any, otherwise the best supported language of
+// the Accept-Language header. It returns "" when nothing should be
+// translated.
+func (s *TranslationService) Language(ctx context.Context, userID, acceptLanguage string) (string, error) {
+	settings, err := s.repo.GetUserSettings(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return s.negotiate(settings.TranslationLanguage, acceptLanguage), nil
+}
+
+// negotiate applies a stored preference, falling back to Accept-Language
+// when the preference is automatic.
+func (s *TranslationService) negotiate(preference, acceptLanguage string) string {
+	switch preference {
+	case TranslationOff:
+		return ""
+	case TranslationAuto:
+	default:
+		if _, ok := s.providers[preference]; ok {
+			return preference
+		}
+		return ""
+	}
+
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if tag == DefaultLanguage {
+			// The reader prefers English over any translation
+			return ""
+		}
+		if _, ok := s.providers[tag]; ok {
+			return tag
+		}
+	}
+	return ""
+}
+
+// Annotate sets entry.Translation to the glosses of its headword in
+// language, leaving it unset when language is "" or has no glosses. Only
+// English entries are glossed.
+func (s *TranslationService) Annotate(entry *models.DictionaryEntry, language string) {
+	p, ok := s.providers[language]
+	if !ok || (entry.Language != "" && entry.Language != DefaultLanguage) {
+		return
+	}
+	glosses := p.Translate(strings.ToLower(entry.Word))
+	if len(glosses) == 0 {
+		return
+	}
+	entry.Translation = &models.Translation{
+		Language: language,
+		Glosses:  slices.Clone(glosses),
+		Source:   p.Source(),
+	}
+}
+
+// parseAcceptLanguage returns the primary language subtags of an
+// Accept-Language header, most preferred first. Wildcards and tags with a
+// quality of zero are dropped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	languages := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if !slices.Contains(languages, t.tag) {
+			languages = append(languages, t.tag)
+		}
+	}
+	return languages
+}
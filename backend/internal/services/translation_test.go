@@ -0,0 +1,130 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+)
+
+func TestLoadFreeDict(t *testing.T) {
+	input := "# comment\nHouse\tHaus; Gebäude\n\nhouse\tHaus\nbank\tBank;Ufer;\n"
+	tr, err := LoadFreeDict("de", "FreeDict", strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := tr.Translate("house"); !reflect.DeepEqual(got, []string{"Haus", "Gebäude"}) {
+		t.Errorf("unexpected glosses for house: %v", got)
+	}
+	if got := tr.Translate("bank"); !reflect.DeepEqual(got, []string{"Bank", "Ufer"}) {
+		t.Errorf("unexpected glosses for bank: %v", got)
+	}
+
+	if _, err := LoadFreeDict("de", "FreeDict", strings.NewReader("house Haus\n")); err == nil {
+		t.Error("expected an error for a line without a tab")
+	}
+}
+
+func TestLoadCEDICT(t *testing.T) {
+	input := `# CC-CEDICT
+吃 吃 [chi1] /to eat/to consume/
+狗 狗 [gou3] /dog/CL:隻|只[zhi1],條|条[tiao2]/
+米飯 米饭 [mi3 fan4] /(cooked) rice/
+八 八 [ba1] /eight/8/
+broken line
+`
+	tr, err := LoadCEDICT(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string][]string{
+		"eat":     {"吃"},
+		"consume": {"吃"},
+		"dog":     {"狗"},
+		"rice":    {"米饭"},
+		"eight":   {"八"},
+		"8":       nil,
+	}
+	for word, want := range tests {
+		if got := tr.Translate(word); !reflect.DeepEqual(got, want) {
+			t.Errorf("Translate(%q) = %v, want %v", word, got, want)
+		}
+	}
+}
+
+// testTranslators loads the dictionary excerpts in testdata
+func testTranslators(t *testing.T) []TranslationProvider {
+	t.Helper()
+	providers, err := LoadTranslationDir("testdata/translations")
+	if err != nil {
+		t.Fatalf("failed to load translations: %v", err)
+	}
+	return providers
+}
+
+func TestLoadTranslationDir(t *testing.T) {
+	s := NewTranslationService(nil, testTranslators(t)...)
+	if got := s.Languages(); !reflect.DeepEqual(got, []string{"de", "es", "zh"}) {
+		t.Fatalf("unexpected languages %v", got)
+	}
+
+	for language, want := range map[string]string{"de": "Haus", "es": "casa", "zh": "房子"} {
+		entry := &models.DictionaryEntry{Word: "House"}
+		s.Annotate(entry, language)
+		if entry.Translation == nil || entry.Translation.Glosses[0] != want {
+			t.Errorf("expected %s translation %q, got %+v", language, want, entry.Translation)
+		}
+	}
+}
+
+func TestTranslationNegotiate(t *testing.T) {
+	s := NewTranslationService(nil, testTranslators(t)...)
+
+	tests := []struct {
+		preference, accept, want string
+	}{
+		{TranslationAuto, "de-DE,de;q=0.9,en;q=0.8", "de"},
+		{TranslationAuto, "en-US,en;q=0.9,de;q=0.8", ""},
+		{TranslationAuto, "fr;q=0.9, es;q=0.8, zh-CN", "zh"},
+		{TranslationAuto, "fr, *;q=0.5", ""},
+		{TranslationAuto, "de;q=0, es;q=0.1", "es"},
+		{TranslationAuto, "", ""},
+		{"es", "de", "es"},
+		{TranslationOff, "de", ""},
+	}
+	for _, tt := range tests {
+		if got := s.negotiate(tt.preference, tt.accept); got != tt.want {
+			t.Errorf("negotiate(%q, %q) = %q, want %q", tt.preference, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestAnnotateWithoutGlosses(t *testing.T) {
+	s := NewTranslationService(nil, testTranslators(t)...)
+	entry := &models.DictionaryEntry{Word: "zyzzyva"}
+	s.Annotate(entry, "de")
+	s.Annotate(entry, "")
+	if entry.Translation != nil {
+		t.Errorf("expected no translation, got %+v", entry.Translation)
+	}
+}
+
+func TestLoadTranslationDirOptional(t *testing.T) {
+	for _, dir := range []string{"", t.TempDir()} {
+		if providers, err := LoadTranslationDir(dir); err != nil || len(providers) != 0 {
+			t.Errorf("LoadTranslationDir(%q) = %v, %v, want nothing", dir, providers, err)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "freedict-eng-xyzzy.tsv"), []byte("house\tHaus\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTranslationDir(dir); err == nil {
+		t.Error("expected an error for a file of an unknown language")
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	TTSFormatWAV = "wav"
+	TTSFormatOGG = "ogg"
+
+	// ttsTimeout bounds one run of the text-to-speech command
+	ttsTimeout = 10 * time.Second
+)
+
+// ErrInvalidTTSCommand is returned for a text-to-speech configuration that
+// can't be run
+var ErrInvalidTTSCommand = errors.New("invalid text-to-speech command")
+
+// Synthesizer generates pronunciations with a locally installed
+// text-to-speech engine, such as espeak-ng, run as a command. The command
+// is a template whose arguments may contain these placeholders:
+//
+//	{text}   the word to speak
+//	{ipa}    its IPA transcription without slashes, or the word if it has none
+//	{lang}   the word's language code, e.g. to pick a voice
+//	{output} a file the engine writes the audio to
+//
+// Without {output}, the audio is read from the command's standard output.
+// Arguments are split on whitespace and passed directly, with no shell in
+// between, so words can't inject commands; a word starting with "-" could
+// still be read as an option unless the template ends options with "--".
+type Synthesizer struct {
+	args   []string
+	format string
+}
+
+// NewSynthesizer parses a command template producing audio in format,
+// TTSFormatWAV or TTSFormatOGG. For example:
+//
+//	espeak-ng -v {lang} -w {output} -- {text}
+func NewSynthesizer(command, format string) (*Synthesizer, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: empty command", ErrInvalidTTSCommand)
+	}
+	if format != TTSFormatWAV && format != TTSFormatOGG {
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidTTSCommand, format)
+	}
+	return &Synthesizer{args: args, format: format}, nil
+}
+
+// ContentType returns the media type of the generated audio.
+func (s *Synthesizer) ContentType() string {
+	return audioContentTypes["."+s.format]
+}
+
+// Synthesize speaks word, or its IPA transcription when the template asks
+// for one, and returns the audio.
+func (s *Synthesizer) Synthesize(ctx context.Context, language, word, ipa string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, ttsTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "tts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "speech."+s.format)
+
+	ipa = strings.Trim(strings.TrimSpace(ipa), "/[]")
+	if ipa == "" {
+		ipa = word
+	}
+	placeholders := strings.NewReplacer("{text}", word, "{ipa}", ipa, "{lang}", language, "{output}", output)
+
+	toFile := false
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		toFile = toFile || strings.Contains(arg, "{output}")
+		args[i] = placeholders.Replace(arg)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("text-to-speech failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	data := stdout.Bytes()
+	if toFile {
+		if data, err = os.ReadFile(output); err != nil {
+			return nil, fmt.Errorf("text-to-speech wrote no audio: %w", err)
+		}
+	}
+	if len(data) == 0 {
+		return nil, errors.New("text-to-speech produced no audio")
+	}
+	if len(data) > maxAudioSize {
+		return nil, fmt.Errorf("audio exceeds %d bytes", maxAudioSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeEngine writes a shell script standing in for a text-to-speech engine
+func fakeEngine(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	name := filepath.Join(t.TempDir(), "tts")
+	if err := os.WriteFile(name, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatalf("failed to write engine: %v", err)
+	}
+	return name
+}
+
+func TestNewSynthesizer(t *testing.T) {
+	if _, err := NewSynthesizer("  ", TTSFormatWAV); !errors.Is(err, ErrInvalidTTSCommand) {
+		t.Errorf("expected ErrInvalidTTSCommand for an empty command, got %v", err)
+	}
+	if _, err := NewSynthesizer("espeak-ng", "mp3"); !errors.Is(err, ErrInvalidTTSCommand) {
+		t.Errorf("expected ErrInvalidTTSCommand for an unsupported format, got %v", err)
+	}
+
+	s, err := NewSynthesizer("espeak-ng --stdout {text}", TTSFormatOGG)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.ContentType() != "audio/ogg" {
+		t.Errorf("unexpected content type %q", s.ContentType())
+	}
+}
+
+func TestSynthesize(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("output file", func(t *testing.T) {
+		// Writes its arguments as the audio, so the substitutions can be checked
+		engine := fakeEngine(t, `out=$1; shift; printf '%s|' "$@" > "$out"`)
+		s, err := NewSynthesizer(engine+" {output} -v {lang} -- {text} [[{ipa}]]", TTSFormatWAV)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data, err := s.Synthesize(ctx, "en", "give up; rm -rf", "/ɡɪv ʌp/")
+		if err != nil {
+			t.Fatalf("Synthesize failed: %v", err)
+		}
+		if got, want := string(data), "-v|en|--|give up; rm -rf|[[ɡɪv ʌp]]|"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		engine := fakeEngine(t, `printf 'RIFF %s' "$1"`)
+		s, _ := NewSynthesizer(engine+" {ipa}", TTSFormatWAV)
+
+		data, err := s.Synthesize(ctx, "en", "hello", "")
+		if err != nil {
+			t.Fatalf("Synthesize failed: %v", err)
+		}
+		if got := string(data); got != "RIFF hello" {
+			t.Errorf("expected the word in place of a missing transcription, got %q", got)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		engine := fakeEngine(t, `echo "unknown voice" >&2; exit 1`)
+		s, _ := NewSynthesizer(engine+" {text}", TTSFormatWAV)
+		if _, err := s.Synthesize(ctx, "xx", "hello", ""); err == nil {
+			t.Error("expected an error from a failing engine")
+		}
+	})
+
+	t.Run("no audio", func(t *testing.T) {
+		engine := fakeEngine(t, `exit 0`)
+		s, _ := NewSynthesizer(engine+" {text}", TTSFormatWAV)
+		if _, err := s.Synthesize(ctx, "en", "hello", ""); err == nil {
+			t.Error("expected an error when no audio is produced")
+		}
+	})
+}
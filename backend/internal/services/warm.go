@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"github.com/warriorguo/vocabulary/internal/normalize"
+	"github.com/warriorguo/vocabulary/internal/repository"
+)
+
+const (
+	DefaultWarmConcurrency = 4
+	MaxWarmConcurrency     = 16
+	// DefaultWarmRate is the default number of words looked up upstream
+	// per second
+	DefaultWarmRate = 5
+
+	// warmBatchSize is how many words are looked up between progress saves;
+	// resuming an interrupted run repeats at most one batch
+	warmBatchSize = 50
+)
+
+var (
+	// ErrEmptyWordList is returned for a cache warming run with no valid words
+	ErrEmptyWordList = errors.New("word list is empty")
+	// ErrWarmRunning is returned when a cache warming run is already in
+	// progress, in this process or another replica
+	ErrWarmRunning = errors.New("cache warming run is already in progress")
+)
+
+// WarmOptions bounds the load a cache warming run puts on upstream
+// dictionaries
+type WarmOptions struct {
+	// Concurrency is the number of words looked up at once
+	Concurrency int
+	// Rate is the number of words looked up upstream per second, or 0 for
+	// no limit. Words already cached don't count.
+	Rate float64
+}
+
+// CacheWarmer fills the dictionary cache by looking up a word list, so the
+// first users after a deploy or purge don't wait on upstream for common
+// words.
+type CacheWarmer struct {
+	repo    *repository.Repository
+	dictSvc *DictionaryService
+}
+
+func NewCacheWarmer(repo *repository.Repository, dictSvc *DictionaryService) *CacheWarmer {
+	return &CacheWarmer{repo: repo, dictSvc: dictSvc}
+}
+
+// TopWords returns the n most frequent words of the bundled frequency list,
+// which only covers DefaultLanguage.
+func (w *CacheWarmer) TopWords(language string, n int) ([]string, error) {
+	language, err := w.dictSvc.Language(language)
+	if err != nil {
+		return nil, err
+	}
+	if language != DefaultLanguage {
+		return nil, fmt.Errorf("%w: no bundled word list for %s", ErrEmptyWordList, language)
+	}
+	return w.dictSvc.freq.Top(max(n, 0)), nil
+}
+
+// Create records a run warming the cache with words of language, dropping
+// invalid and repeated words. The run starts when passed to Run.
+func (w *CacheWarmer) Create(ctx context.Context, language string, words []string) (*models.WarmRun, error) {
+	language, err := w.dictSvc.Language(language)
+	if err != nil {
+		return nil, err
+	}
+	list := warmWordList(language, words)
+	if len(list) == 0 {
+		return nil, ErrEmptyWordList
+	}
+
+	return w.repo.CreateWarmRun(ctx, language, list)
+}
+
+// warmWordList normalizes words, dropping invalid and repeated ones.
+func warmWordList(language string, words []string) []string {
+	seen := make(map[string]bool, len(words))
+	list := make([]string, 0, len(words))
+	for _, word := range words {
+		word, err := normalize.Word(language, word)
+		if err != nil || seen[word] {
+			continue
+		}
+		seen[word] = true
+		list = append(list, word)
+	}
+	return list
+}
+
+// Get returns a run, or repository.ErrNotFound.
+func (w *CacheWarmer) Get(ctx context.Context, id int64) (*models.WarmRun, error) {
+	return w.repo.GetWarmRun(ctx, id)
+}
+
+// List returns up to limit runs, newest first.
+func (w *CacheWarmer) List(ctx context.Context, limit int) ([]models.WarmRun, error) {
+	return w.repo.GetWarmRuns(ctx, limit)
+}
+
+// warmOutcome is what looking up one word did
+type warmOutcome int
+
+const (
+	warmFetched warmOutcome = iota
+	warmCached
+	warmNotFound
+	warmFailed
+)
+
+// Run looks up the words of a run from where it left off, saving progress
+// after every batch and calling progress, if not nil, after each save. When
+// ctx is cancelled the run stops and is marked interrupted, to be resumed by
+// calling Run again. It returns ErrWarmRunning if the run is being processed
+// elsewhere.
+func (w *CacheWarmer) Run(ctx context.Context, id int64, opts WarmOptions, progress func(*models.WarmRun)) (*models.WarmRun, error) {
+	var run *models.WarmRun
+	locked, err := w.repo.RunExclusive(ctx, fmt.Sprintf("cache-warm:%d", id), func(ctx context.Context) error {
+		var err error
+		if run, err = w.repo.GetWarmRun(ctx, id); err != nil {
+			return err
+		}
+		if run.Status == models.WarmStatusFinished {
+			return nil
+		}
+		return w.run(ctx, run, opts, progress)
+	})
+	if err != nil {
+		return run, err
+	}
+	if !locked {
+		return nil, fmt.Errorf("%w: %d", ErrWarmRunning, id)
+	}
+	return run, nil
+}
+
+func (w *CacheWarmer) run(ctx context.Context, run *models.WarmRun, opts WarmOptions, progress func(*models.WarmRun)) error {
+	words, err := w.repo.GetWarmRunWords(ctx, run.ID, run.Position)
+	if err != nil {
+		return err
+	}
+
+	concurrency := min(max(opts.Concurrency, 1), MaxWarmConcurrency)
+	var limit <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+
+	run.Status = models.WarmStatusRunning
+	if err := w.repo.SaveWarmRunProgress(ctx, run); err != nil {
+		return err
+	}
+	for len(words) > 0 && ctx.Err() == nil {
+		batch := words[:min(warmBatchSize, len(words))]
+		outcomes := make([]warmOutcome, len(batch))
+		errs := make([]error, len(batch))
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+		for i, word := range batch {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				outcomes[i], errs[i] = w.warm(ctx, run.Language, word, limit)
+			}()
+		}
+		wg.Wait()
+
+		// A batch cut short is looked up again on resume
+		if ctx.Err() != nil {
+			break
+		}
+		for i, outcome := range outcomes {
+			switch outcome {
+			case warmFetched:
+				run.Fetched++
+			case warmCached:
+				run.Cached++
+			case warmNotFound:
+				run.NotFound = append(run.NotFound, batch[i])
+			case warmFailed:
+				run.Failed++
+				run.LastError = fmt.Sprintf("%s: %v", batch[i], errs[i])
+			}
+		}
+		run.Position += len(batch)
+		words = words[len(batch):]
+
+		if len(words) == 0 {
+			run.Status = models.WarmStatusFinished
+		}
+		if err := w.repo.SaveWarmRunProgress(context.WithoutCancel(ctx), run); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(run)
+		}
+	}
+
+	if run.Status != models.WarmStatusFinished {
+		run.Status = models.WarmStatusInterrupted
+		if err := w.repo.SaveWarmRunProgress(context.WithoutCancel(ctx), run); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(run)
+		}
+	}
+	return nil
+}
+
+// warm fetches one word into the cache unless it is already there, waiting
+// for limit before going upstream.
+func (w *CacheWarmer) warm(ctx context.Context, language, word string, limit <-chan time.Time) (warmOutcome, error) {
+	cached, err := w.repo.GetCachedDictionary(ctx, language, word)
+	if err != nil {
+		return warmFailed, err
+	}
+	if cached != nil {
+		// Outdated entries are fetched again by the lookup below
+		if _, _, err := entryCodec.decode(cached); !errors.Is(err, ErrCacheOutdated) {
+			return warmCached, nil
+		}
+	}
+
+	if limit != nil {
+		select {
+		case <-limit:
+		case <-ctx.Done():
+			return warmFailed, ctx.Err()
+		}
+	}
+
+	// The word itself is fetched, not the lemma a lookup would resolve it to,
+	// so it counts as cached when a resumed run reaches it again
+	err = w.dictSvc.Refresh(ctx, language, word)
+	switch {
+	case err == nil:
+		return warmFetched, nil
+	case errors.Is(err, ErrWordNotFound):
+		return warmNotFound, nil
+	default:
+		return warmFailed, err
+	}
+}
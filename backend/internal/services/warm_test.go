@@ -0,0 +1,34 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWarmWordList(t *testing.T) {
+	got := warmWordList("en", []string{"The", "the", "  give   up ", "", "don’t", "don't"})
+	want := []string{"the", "give up", "don't"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("warmWordList = %q, want %q", got, want)
+	}
+}
+
+func TestTopWords(t *testing.T) {
+	w := NewCacheWarmer(nil, NewDictionaryService(nil))
+
+	words, err := w.TopWords("EN", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(words) != 3 || words[0] != "the" {
+		t.Errorf("expected the 3 most frequent words, got %q", words)
+	}
+
+	if _, err := w.TopWords("de", 3); !errors.Is(err, ErrEmptyWordList) {
+		t.Errorf("expected ErrEmptyWordList for a language without a bundled list, got %v", err)
+	}
+	if _, err := w.TopWords("xx", 3); !errors.Is(err, ErrUnsupportedLanguage) {
+		t.Errorf("expected ErrUnsupportedLanguage, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/warriorguo/vocabulary/internal/models"
+	"golang.org/x/net/html"
+)
+
+const (
+	wiktionaryAPIURL  = "https://en.wiktionary.org/api/rest_v1/page/definition/"
+	wiktionaryPageURL = "https://en.wiktionary.org/wiki/"
+	sourceWiktionary  = "wiktionary"
+)
+
+// Wiktionary is a DictionaryProvider backed by the English Wiktionary's REST
+// definition endpoint, which describes words of many languages in English.
+type Wiktionary struct {
+	client   *http.Client
+	baseURL  string
+	language string
+}
+
+// NewWiktionary creates a provider for the words of one language, using the
+// definition endpoint at baseURL, such as wiktionaryAPIURL.
+func NewWiktionary(client *http.Client, baseURL, language string) *Wiktionary {
+	return &Wiktionary{client: client, baseURL: baseURL, language: language}
+}
+
+// Source implements DictionaryProvider.
+func (w *Wiktionary) Source() string { return sourceWiktionary }
+
+// wiktionaryResponse maps language codes to the word's usages in that
+// language, one per part of speech
+type wiktionaryResponse map[string][]struct {
+	PartOfSpeech string `json:"partOfSpeech"`
+	Definitions  []struct {
+		Definition string   `json:"definition"`
+		Examples   []string `json:"examples"`
+	} `json:"definitions"`
+}
+
+// Fetch implements DictionaryProvider.
+func (w *Wiktionary) Fetch(ctx context.Context, word string) (*models.DictionaryEntry, error) {
+	body, err := w.FetchRaw(ctx, word)
+	if err != nil {
+		return nil, err
+	}
+	return w.Normalize(word, body)
+}
+
+// FetchRaw implements RawDictionaryProvider. The payload covers the word in
+// every language Wiktionary has it in.
+func (w *Wiktionary) FetchRaw(ctx context.Context, word string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.baseURL+wiktionaryTitle(word), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrWordNotFound, word)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return body, nil
+}
+
+// Normalize implements RawDictionaryProvider.
+func (w *Wiktionary) Normalize(word string, body []byte) (*models.DictionaryEntry, error) {
+	var apiResp wiktionaryResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	entry := w.normalizeResponse(word, apiResp)
+	if len(entry.Meanings) == 0 {
+		// The page exists, but only for other languages
+		return nil, fmt.Errorf("%w: %s", ErrWordNotFound, word)
+	}
+	return entry, nil
+}
+
+func (w *Wiktionary) normalizeResponse(word string, apiResp wiktionaryResponse) *models.DictionaryEntry {
+	entry := &models.DictionaryEntry{
+		Word:      word,
+		Phonetics: make([]models.Phonetic, 0),
+		Meanings:  make([]models.Meaning, 0),
+		SourceURL: wiktionaryPageURL + wiktionaryTitle(word),
+	}
+
+	for _, usage := range apiResp[w.language] {
+		meaning := models.Meaning{
+			PartOfSpeech: strings.ToLower(usage.PartOfSpeech),
+			Definitions:  make([]models.Definition, 0),
+		}
+		for _, d := range usage.Definitions {
+			def := models.Definition{Definition: htmlText(d.Definition)}
+			if def.Definition == "" {
+				// Empty after markup is stripped, e.g. a bare category link
+				continue
+			}
+			if len(d.Examples) > 0 {
+				def.Example = htmlText(d.Examples[0])
+			}
+			meaning.Definitions = append(meaning.Definitions, def)
+		}
+		if len(meaning.Definitions) > 0 {
+			entry.Meanings = append(entry.Meanings, meaning)
+		}
+	}
+
+	return entry
+}
+
+// wiktionaryTitle returns the escaped page title of a word, which spells
+// spaces as underscores.
+func wiktionaryTitle(word string) string {
+	return url.PathEscape(strings.ReplaceAll(word, " ", "_"))
+}
+
+// htmlText returns the text of an HTML fragment with tags dropped and
+// whitespace collapsed.
+func htmlText(fragment string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.TextToken:
+			b.Write(z.Text())
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWiktionaryFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/definition/Haus":
+			w.Write([]byte(`{
+				"de": [{
+					"partOfSpeech": "Noun",
+					"definitions": [
+						{"definition": "<a href=\"/wiki/house\">house</a>, building", "examples": ["Das <b>Haus</b> ist alt."]},
+						{"definition": "<span></span>"}
+					]
+				}],
+				"en": [{"partOfSpeech": "Noun", "definitions": [{"definition": "unrelated"}]}]
+			}`))
+		case "/definition/gift":
+			w.Write([]byte(`{"en": [{"partOfSpeech": "Noun", "definitions": [{"definition": "a present"}]}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	w := NewWiktionary(server.Client(), server.URL+"/definition/", "de")
+
+	entry, err := w.Fetch(context.Background(), "Haus")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entry.Meanings) != 1 || entry.Meanings[0].PartOfSpeech != "noun" {
+		t.Fatalf("unexpected meanings: %+v", entry.Meanings)
+	}
+	defs := entry.Meanings[0].Definitions
+	if len(defs) != 1 || defs[0].Definition != "house, building" || defs[0].Example != "Das Haus ist alt." {
+		t.Errorf("unexpected definitions: %+v", defs)
+	}
+
+	// A page with only other languages' entries doesn't count
+	if _, err := w.Fetch(context.Background(), "gift"); !errors.Is(err, ErrWordNotFound) {
+		t.Errorf("expected ErrWordNotFound, got %v", err)
+	}
+	if _, err := w.Fetch(context.Background(), "missing"); !errors.Is(err, ErrWordNotFound) {
+		t.Errorf("expected ErrWordNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+-- +migrate Up
+-- spaced-repetition state on wordbook entries
+ALTER TABLE wordbook_entries
+    ADD COLUMN IF NOT EXISTS ease DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+    ADD COLUMN IF NOT EXISTS interval_days INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS repetitions INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+CREATE INDEX IF NOT EXISTS idx_wordbook_user_due ON wordbook_entries(user_id, due_at);
+
+-- review_logs table
+CREATE TABLE IF NOT EXISTS review_logs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    word VARCHAR(128) NOT NULL,
+    grade SMALLINT NOT NULL,
+    interval_days INTEGER NOT NULL,
+    reviewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_review_logs_user_reviewed ON review_logs(user_id, reviewed_at);
+
+-- user_settings table
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id VARCHAR(64) PRIMARY KEY,
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS user_settings;
+DROP TABLE IF EXISTS review_logs;
+DROP INDEX IF EXISTS idx_wordbook_user_due;
+ALTER TABLE wordbook_entries
+    DROP COLUMN IF EXISTS due_at,
+    DROP COLUMN IF EXISTS repetitions,
+    DROP COLUMN IF EXISTS interval_days,
+    DROP COLUMN IF EXISTS ease;
@@ -0,0 +1,14 @@
+-- +migrate Up
+-- lookup_history table
+CREATE TABLE IF NOT EXISTS lookup_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    word VARCHAR(128) NOT NULL,
+    found BOOLEAN NOT NULL,
+    looked_up_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_lookup_history_user_time ON lookup_history(user_id, looked_up_at DESC);
+CREATE INDEX IF NOT EXISTS idx_lookup_history_user_word ON lookup_history(user_id, word);
+
+-- +migrate Down
+DROP TABLE IF EXISTS lookup_history;
@@ -0,0 +1,12 @@
+-- +migrate Up
+-- full dictionary snapshot and chosen sense on wordbook entries
+ALTER TABLE wordbook_entries
+    ADD COLUMN IF NOT EXISTS snapshot JSONB,
+    ADD COLUMN IF NOT EXISTS selected_meaning INTEGER,
+    ADD COLUMN IF NOT EXISTS selected_definition INTEGER;
+
+-- +migrate Down
+ALTER TABLE wordbook_entries
+    DROP COLUMN IF EXISTS selected_definition,
+    DROP COLUMN IF EXISTS selected_meaning,
+    DROP COLUMN IF EXISTS snapshot;
@@ -0,0 +1,15 @@
+-- +migrate Up
+-- wordbook_contexts table: where a saved word was encountered
+CREATE TABLE IF NOT EXISTS wordbook_contexts (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES wordbook_entries(id) ON DELETE CASCADE,
+    sentence TEXT NOT NULL,
+    source_title TEXT NOT NULL DEFAULT '',
+    source_url TEXT NOT NULL DEFAULT '',
+    location VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_wordbook_contexts_entry ON wordbook_contexts(entry_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS wordbook_contexts;
@@ -0,0 +1,10 @@
+-- +migrate Up
+-- user notes and tags on wordbook entries
+ALTER TABLE wordbook_entries
+    ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- +migrate Down
+ALTER TABLE wordbook_entries
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS notes;
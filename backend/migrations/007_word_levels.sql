@@ -0,0 +1,16 @@
+-- +migrate Up
+-- frequency and CEFR annotations of wordbook entries; a NULL frequency_rank
+-- marks rows saved before annotation, which the server backfills at startup
+ALTER TABLE wordbook_entries
+    ADD COLUMN IF NOT EXISTS frequency_rank INTEGER,
+    ADD COLUMN IF NOT EXISTS zipf DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS cefr_level VARCHAR(2) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_wordbook_entries_cefr ON wordbook_entries(user_id, cefr_level);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_wordbook_entries_cefr;
+ALTER TABLE wordbook_entries
+    DROP COLUMN IF EXISTS cefr_level,
+    DROP COLUMN IF EXISTS zipf,
+    DROP COLUMN IF EXISTS frequency_rank;
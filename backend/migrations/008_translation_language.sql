@@ -0,0 +1,8 @@
+-- +migrate Up
+-- language dictionary entries are glossed in; empty follows Accept-Language
+ALTER TABLE user_settings
+    ADD COLUMN IF NOT EXISTS translation_language VARCHAR(16) NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE user_settings
+    DROP COLUMN IF EXISTS translation_language;
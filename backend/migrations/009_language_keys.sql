@@ -0,0 +1,37 @@
+-- +migrate Up
+-- wordbook entries, cached dictionary entries, review logs and lookup history
+-- are keyed by (language, word); existing rows are English
+ALTER TABLE wordbook_entries
+    ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en';
+ALTER TABLE wordbook_entries DROP CONSTRAINT IF EXISTS wordbook_entries_user_id_word_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wordbook_user_language_word ON wordbook_entries(user_id, language, word);
+
+ALTER TABLE dictionary_cache
+    ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en';
+ALTER TABLE dictionary_cache DROP CONSTRAINT IF EXISTS dictionary_cache_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cache_language_word ON dictionary_cache(language, word);
+
+ALTER TABLE review_logs
+    ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en';
+
+ALTER TABLE lookup_history
+    ADD COLUMN IF NOT EXISTS language VARCHAR(16) NOT NULL DEFAULT 'en';
+DROP INDEX IF EXISTS idx_lookup_history_user_word;
+CREATE INDEX IF NOT EXISTS idx_lookup_history_user_language_word ON lookup_history(user_id, language, word);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_lookup_history_user_language_word;
+CREATE INDEX IF NOT EXISTS idx_lookup_history_user_word ON lookup_history(user_id, word);
+ALTER TABLE lookup_history DROP COLUMN IF EXISTS language;
+
+ALTER TABLE review_logs DROP COLUMN IF EXISTS language;
+
+DROP INDEX IF EXISTS idx_cache_language_word;
+DELETE FROM dictionary_cache WHERE language <> 'en';
+ALTER TABLE dictionary_cache ADD PRIMARY KEY (word);
+ALTER TABLE dictionary_cache DROP COLUMN IF EXISTS language;
+
+DROP INDEX IF EXISTS idx_wordbook_user_language_word;
+DELETE FROM wordbook_entries WHERE language <> 'en';
+ALTER TABLE wordbook_entries ADD CONSTRAINT wordbook_entries_user_id_word_key UNIQUE (user_id, word);
+ALTER TABLE wordbook_entries DROP COLUMN IF EXISTS language;
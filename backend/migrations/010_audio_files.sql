@@ -0,0 +1,17 @@
+-- +migrate Up
+-- pronunciation recordings downloaded by the audio proxy; the files are kept
+-- in the audio store under their checksum
+CREATE TABLE IF NOT EXISTS audio_files (
+    language VARCHAR(16) NOT NULL,
+    word VARCHAR(128) NOT NULL,
+    accent VARCHAR(8) NOT NULL DEFAULT '',
+    source_url TEXT NOT NULL,
+    content_type VARCHAR(128) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (language, word, accent)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS audio_files;
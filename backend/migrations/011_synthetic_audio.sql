@@ -0,0 +1,8 @@
+-- +migrate Up
+-- recordings generated by the local text-to-speech engine rather than fetched
+ALTER TABLE audio_files
+    ADD COLUMN IF NOT EXISTS synthetic BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +migrate Down
+ALTER TABLE audio_files
+    DROP COLUMN IF EXISTS synthetic;
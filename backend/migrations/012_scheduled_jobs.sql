@@ -0,0 +1,27 @@
+-- +migrate Up
+-- history of the background maintenance jobs, for monitoring
+CREATE TABLE IF NOT EXISTS job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job VARCHAR(64) NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    result TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_started ON job_runs(job, started_at DESC);
+
+-- at most one reminder of due reviews per user and day
+CREATE TABLE IF NOT EXISTS review_reminders (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    day DATE NOT NULL,
+    due_count INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dismissed_at TIMESTAMPTZ,
+    UNIQUE(user_id, day)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS review_reminders;
+DROP TABLE IF EXISTS job_runs;
@@ -0,0 +1,21 @@
+-- +migrate Up
+-- passes filling the dictionary cache from a word list, resumable from
+-- position
+CREATE TABLE IF NOT EXISTS cache_warm_runs (
+    id BIGSERIAL PRIMARY KEY,
+    language VARCHAR(16) NOT NULL,
+    words TEXT[] NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    fetched INTEGER NOT NULL DEFAULT 0,
+    cached INTEGER NOT NULL DEFAULT 0,
+    not_found TEXT[] NOT NULL DEFAULT '{}',
+    failed INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'running',
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMPTZ
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS cache_warm_runs;
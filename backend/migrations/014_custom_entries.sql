@@ -0,0 +1,31 @@
+-- +migrate Up
+-- admin-curated dictionary entries and overrides, consulted before the
+-- cache and upstream dictionaries
+CREATE TABLE IF NOT EXISTS custom_entries (
+    language VARCHAR(16) NOT NULL,
+    word VARCHAR(128) NOT NULL,
+    entry JSONB,
+    overrides JSONB NOT NULL DEFAULT '[]',
+    updated_by VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (language, word)
+);
+
+-- audit trail of custom entry changes, with the entry before and after
+CREATE TABLE IF NOT EXISTS custom_entry_changes (
+    id BIGSERIAL PRIMARY KEY,
+    language VARCHAR(16) NOT NULL,
+    word VARCHAR(128) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    actor VARCHAR(64) NOT NULL,
+    before JSONB,
+    after JSONB,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_custom_entry_changes_word ON custom_entry_changes(language, word, changed_at DESC);
+
+-- +migrate Down
+DROP TABLE IF EXISTS custom_entry_changes;
+DROP TABLE IF EXISTS custom_entries;
@@ -0,0 +1,7 @@
+-- +migrate Up
+-- version of the entry JSON in each cache row; rows cached before versioning
+-- are version 1
+ALTER TABLE dictionary_cache ADD COLUMN IF NOT EXISTS schema_version INTEGER NOT NULL DEFAULT 1;
+
+-- +migrate Down
+ALTER TABLE dictionary_cache DROP COLUMN IF EXISTS schema_version;
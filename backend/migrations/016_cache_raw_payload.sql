@@ -0,0 +1,7 @@
+-- +migrate Up
+-- gzipped upstream payload each cached entry was normalized from, so entries
+-- can be normalized again without refetching
+ALTER TABLE dictionary_cache ADD COLUMN IF NOT EXISTS raw BYTEA;
+
+-- +migrate Down
+ALTER TABLE dictionary_cache DROP COLUMN IF EXISTS raw;
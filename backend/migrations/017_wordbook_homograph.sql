@@ -0,0 +1,6 @@
+-- +migrate Up
+-- homograph of the snapshot a wordbook entry is pinned to
+ALTER TABLE wordbook_entries ADD COLUMN IF NOT EXISTS selected_homograph INTEGER;
+
+-- +migrate Down
+ALTER TABLE wordbook_entries DROP COLUMN IF EXISTS selected_homograph;